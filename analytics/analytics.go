@@ -0,0 +1,288 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/history"
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+	"github.com/ByChanderZap/exile-tracker/repository"
+)
+
+// Category is one of the things usage is counted for.
+type Category string
+
+const (
+	Ascendancies       Category = "ascendancies"
+	MainSkills         Category = "main_skills"
+	Uniques            Category = "uniques"
+	SkillGems          Category = "skill_gems"
+	Supports           Category = "supports"
+	Keystones          Category = "keystones"
+	AscendancyNotables Category = "ascendancy_notables"
+)
+
+// Categories lists every category in display order.
+var Categories = []Category{Ascendancies, MainSkills, Uniques, SkillGems, Supports, Keystones, AscendancyNotables}
+
+// ParseCategory returns the category with the given name.
+func ParseCategory(s string) (Category, bool) {
+	for _, c := range Categories {
+		if string(c) == s {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// Filter restricts which characters are counted. Empty fields match every
+// character and matching is case insensitive.
+type Filter struct {
+	League     string `json:"league,omitempty"`
+	Class      string `json:"class,omitempty"`
+	Ascendancy string `json:"ascendancy,omitempty"`
+}
+
+func (f Filter) matches(s history.BuildSummary) bool {
+	return matchField(f.League, s.League) &&
+		matchField(f.Class, s.Class) &&
+		matchField(f.Ascendancy, s.Ascendancy)
+}
+
+func matchField(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+// Usage is how many characters use something and which share of the
+// counted characters that is.
+type Usage struct {
+	Name       string  `json:"name"`
+	Characters int     `json:"characters"`
+	Share      float64 `json:"share"`
+}
+
+// Overview is the usage of every category among a set of characters.
+type Overview struct {
+	Characters int                  `json:"characters"`
+	Usage      map[Category][]Usage `json:"usage"`
+}
+
+// Top returns a copy of the overview keeping only the n most used entries
+// of each category. n <= 0 keeps everything.
+func (o Overview) Top(n int) Overview {
+	if n <= 0 {
+		return o
+	}
+	top := Overview{Characters: o.Characters, Usage: make(map[Category][]Usage, len(o.Usage))}
+	for c, usage := range o.Usage {
+		if len(usage) > n {
+			usage = usage[:n]
+		}
+		top.Usage[c] = usage
+	}
+	return top
+}
+
+// MaxSnapshots is how many of the newest snapshots a report reads, besides
+// the latest one of every character. Overviews only look at those latest
+// ones and stay exact; trends and upgrades only see the recent history once
+// there are more snapshots than that.
+const MaxSnapshots = 2000
+
+// LoadSummaries summarizes the snapshots reports read, see MaxSnapshots.
+func LoadSummaries(repo *repository.Repository, trees *passivetree.Registry) ([]Summary, error) {
+	data, err := repo.GetRecentSnapshotData(MaxSnapshots)
+	if err != nil {
+		return nil, err
+	}
+	return Summaries(data, trees), nil
+}
+
+// Summaries summarizes every snapshot, keeping the order of data.
+func Summaries(data []models.SnapshotData, trees *passivetree.Registry) []Summary {
+	summaries := make([]Summary, 0, len(data))
+	for _, d := range data {
+		summaries = append(summaries, Summary{
+			CharacterId:  d.CharacterId,
+			TakenAt:      d.CreatedAt,
+			BuildSummary: history.Summarize(d, trees),
+		})
+	}
+	return summaries
+}
+
+// Summary is the build of a character at one snapshot.
+type Summary struct {
+	CharacterId string    `json:"character_id"`
+	TakenAt     time.Time `json:"taken_at"`
+	history.BuildSummary
+}
+
+// Leagues lists every league seen in the summaries.
+func Leagues(summaries []Summary) []string {
+	seen := make(map[string]bool)
+	var leagues []string
+	for _, s := range summaries {
+		if s.League != "" && !seen[s.League] {
+			seen[s.League] = true
+			leagues = append(leagues, s.League)
+		}
+	}
+	sort.Strings(leagues)
+	return leagues
+}
+
+// Latest counts usage over the latest snapshot of every character matching
+// the filter.
+func Latest(summaries []Summary, filter Filter) Overview {
+	return count(latestBefore(summaries, filter, time.Time{}))
+}
+
+// Period is the usage among characters as of the end of a time window.
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Overview
+}
+
+// Timeline splits the time covered by the summaries into windows of the
+// given length and counts usage over each character's latest snapshot as of
+// the end of every window, so characters that were not fetched in a window
+// keep counting with their last known build.
+func Timeline(summaries []Summary, filter Filter, window time.Duration) []Period {
+	if len(summaries) == 0 || window <= 0 {
+		return nil
+	}
+	first, last := summaries[0].TakenAt, summaries[0].TakenAt
+	for _, s := range summaries {
+		if s.TakenAt.Before(first) {
+			first = s.TakenAt
+		}
+		if s.TakenAt.After(last) {
+			last = s.TakenAt
+		}
+	}
+
+	var periods []Period
+	for start := first; !start.After(last); start = start.Add(window) {
+		end := start.Add(window)
+		o := count(latestBefore(summaries, filter, end))
+		if o.Characters == 0 {
+			continue
+		}
+		periods = append(periods, Period{Start: start, End: end, Overview: o})
+	}
+	return periods
+}
+
+// Trend is the share of one entry of a category in every period.
+type Trend struct {
+	Name   string    `json:"name"`
+	Shares []float64 `json:"shares"`
+}
+
+// Trends follows the n entries of a category that are most used in the
+// last period through every period.
+func Trends(periods []Period, category Category, n int) []Trend {
+	if len(periods) == 0 {
+		return nil
+	}
+	latest := periods[len(periods)-1].Usage[category]
+	if n > 0 && len(latest) > n {
+		latest = latest[:n]
+	}
+
+	trends := make([]Trend, 0, len(latest))
+	for _, u := range latest {
+		t := Trend{Name: u.Name, Shares: make([]float64, len(periods))}
+		for i, p := range periods {
+			for _, pu := range p.Usage[category] {
+				if pu.Name == u.Name {
+					t.Shares[i] = pu.Share
+					break
+				}
+			}
+		}
+		trends = append(trends, t)
+	}
+	return trends
+}
+
+// latestBefore returns the latest summary of every character taken before
+// end that matches the filter. A zero end means no limit.
+func latestBefore(summaries []Summary, filter Filter, end time.Time) []Summary {
+	latest := make(map[string]Summary)
+	for _, s := range summaries {
+		if !end.IsZero() && !s.TakenAt.Before(end) {
+			continue
+		}
+		if cur, ok := latest[s.CharacterId]; ok && cur.TakenAt.After(s.TakenAt) {
+			continue
+		}
+		latest[s.CharacterId] = s
+	}
+
+	var matched []Summary
+	for _, s := range latest {
+		if filter.matches(s.BuildSummary) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+func count(summaries []Summary) Overview {
+	counts := make(map[Category]map[string]int, len(Categories))
+	for _, c := range Categories {
+		counts[c] = make(map[string]int)
+	}
+	for _, s := range summaries {
+		ascendancy := s.Ascendancy
+		if ascendancy == "" {
+			ascendancy = s.Class
+		}
+		if ascendancy != "" {
+			counts[Ascendancies][ascendancy]++
+		}
+		if s.MainSkill != "" {
+			counts[MainSkills][s.MainSkill]++
+		}
+		for _, name := range s.Uniques {
+			counts[Uniques][name]++
+		}
+		for _, name := range s.SkillGems {
+			counts[SkillGems][name]++
+		}
+		for _, name := range s.Supports {
+			counts[Supports][name]++
+		}
+		for _, name := range s.Keystones {
+			counts[Keystones][name]++
+		}
+		for _, name := range s.AscendancyNotables {
+			counts[AscendancyNotables][name]++
+		}
+	}
+
+	o := Overview{Characters: len(summaries), Usage: make(map[Category][]Usage, len(Categories))}
+	for _, c := range Categories {
+		usage := make([]Usage, 0, len(counts[c]))
+		for name, n := range counts[c] {
+			usage = append(usage, Usage{
+				Name:       name,
+				Characters: n,
+				Share:      float64(n) / float64(len(summaries)),
+			})
+		}
+		sort.Slice(usage, func(i, j int) bool {
+			if usage[i].Characters != usage[j].Characters {
+				return usage[i].Characters > usage[j].Characters
+			}
+			return usage[i].Name < usage[j].Name
+		})
+		o.Usage[c] = usage
+	}
+	return o
+}
@@ -0,0 +1,398 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ByChanderZap/exile-tracker/history"
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+	"github.com/ByChanderZap/exile-tracker/repository"
+)
+
+var (
+	ErrNoSnapshots = errors.New("character has no snapshot data")
+	// ErrNoReferenceStat is returned when the threshold stat isn't stored
+	// for the latest snapshot of the reference character.
+	ErrNoReferenceStat = errors.New("reference character has no value for the stat")
+)
+
+// MatchBy is what a tracked character must share with the reference
+// character to be compared with it.
+type MatchBy string
+
+const (
+	MatchMainSkill  MatchBy = "main_skill"
+	MatchAscendancy MatchBy = "ascendancy"
+)
+
+type UpgradeKind string
+
+const (
+	UpgradeItem              UpgradeKind = "item"
+	UpgradeGem               UpgradeKind = "gem"
+	UpgradeSupport           UpgradeKind = "support"
+	UpgradeKeystone          UpgradeKind = "keystone"
+	UpgradeNotable           UpgradeKind = "notable"
+	UpgradeAscendancyNotable UpgradeKind = "ascendancy_notable"
+)
+
+// Upgrade is something tracked characters picked up after reaching the
+// threshold. Rare items are grouped by base type since their names are
+// random. Level is the median level it was picked up at.
+type Upgrade struct {
+	Kind    UpgradeKind `json:"kind"`
+	Name    string      `json:"name"`
+	Slot    string      `json:"slot,omitempty"`
+	Players int         `json:"players"`
+	Share   float64     `json:"share"`
+	Level   int         `json:"level"`
+}
+
+type UpgradeParams struct {
+	// Reference are the snapshots of the character the report is for,
+	// oldest first.
+	Reference []models.SnapshotData
+	// Tracked are the snapshots of every tracked character, grouped by
+	// character and oldest first. Snapshots of the reference character are
+	// ignored.
+	Tracked []models.SnapshotData
+	// Stats are the PoB stats of the snapshots, by snapshot id. Only needed
+	// when Stat is set.
+	Stats   map[string]models.SnapshotStats
+	MatchBy MatchBy
+	// Stat is the stat used as threshold, one of the snapshot stat names.
+	// Empty means the character level.
+	Stat string
+	// Levels is how many levels after the threshold upgrades are counted.
+	Levels int
+}
+
+type UpgradeReport struct {
+	CharacterId string    `json:"character_id"`
+	Level       int       `json:"level"`
+	MainSkill   string    `json:"main_skill"`
+	Ascendancy  string    `json:"ascendancy"`
+	MatchBy     MatchBy   `json:"match_by"`
+	Stat        string    `json:"stat"`
+	Threshold   float64   `json:"threshold"`
+	Players     int       `json:"players"`
+	Upgrades    []Upgrade `json:"upgrades"`
+}
+
+// Upgrades compares the reference character with tracked characters that
+// share its main skill or ascendancy. For every one of them it takes the
+// last snapshot where they were at or below the reference's level (or stat)
+// and collects what they changed in the following levels, leaving out what
+// the reference character already has. Characters first seen past the
+// threshold are skipped. Upgrades are ordered by how many players
+// made them.
+func Upgrades(p UpgradeParams, trees *passivetree.Registry) (UpgradeReport, error) {
+	if len(p.Reference) == 0 {
+		return UpgradeReport{}, ErrNoSnapshots
+	}
+	if p.Levels <= 0 {
+		p.Levels = 5
+	}
+	if p.Stat == "" {
+		p.Stat = "level"
+	}
+
+	ref := p.Reference[len(p.Reference)-1]
+	refBuild := history.Summarize(ref, trees)
+	threshold, ok := thresholdValue(ref, p.Stats, p.Stat)
+	if !ok {
+		return UpgradeReport{}, fmt.Errorf("%w '%s'", ErrNoReferenceStat, p.Stat)
+	}
+
+	report := UpgradeReport{
+		CharacterId: ref.CharacterId,
+		Level:       refBuild.Level,
+		MainSkill:   refBuild.MainSkill,
+		Ascendancy:  refBuild.Ascendancy,
+		MatchBy:     p.MatchBy,
+		Stat:        p.Stat,
+		Threshold:   threshold,
+	}
+	owned := ownedUpgrades(ref, refBuild, trees)
+
+	levels := make(map[upgradeKey][]int)
+	for _, snapshots := range byCharacter(p.Tracked) {
+		if snapshots[0].CharacterId == ref.CharacterId {
+			continue
+		}
+
+		start := -1
+		for i, d := range snapshots {
+			v, ok := thresholdValue(d, p.Stats, p.Stat)
+			if !ok {
+				continue
+			}
+			if v > threshold {
+				break
+			}
+			start = i
+		}
+		if start < 0 || start == len(snapshots)-1 {
+			continue
+		}
+		if !matchesBuild(history.Summarize(snapshots[start], trees), refBuild, p.MatchBy) {
+			continue
+		}
+
+		end := start + 1
+		limit := snapshots[start].Items.Character.Level + p.Levels
+		for end < len(snapshots)-1 && snapshots[end].Items.Character.Level < limit {
+			end++
+		}
+
+		report.Players++
+		seen := make(map[upgradeKey]bool)
+		for i := start + 1; i <= end; i++ {
+			for _, k := range stepUpgrades(snapshots[i-1], snapshots[i], trees) {
+				if seen[k] || owned[k] {
+					continue
+				}
+				seen[k] = true
+				levels[k] = append(levels[k], snapshots[i].Items.Character.Level)
+			}
+		}
+	}
+
+	for k, lv := range levels {
+		sort.Ints(lv)
+		report.Upgrades = append(report.Upgrades, Upgrade{
+			Kind:    k.kind,
+			Name:    k.name,
+			Slot:    k.slot,
+			Players: len(lv),
+			Share:   float64(len(lv)) / float64(report.Players),
+			Level:   lv[len(lv)/2],
+		})
+	}
+	sort.Slice(report.Upgrades, func(i, j int) bool {
+		a, b := report.Upgrades[i], report.Upgrades[j]
+		if a.Players != b.Players {
+			return a.Players > b.Players
+		}
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+type upgradeKey struct {
+	kind UpgradeKind
+	name string
+	slot string
+}
+
+// byCharacter splits snapshots grouped by character into one slice per
+// character.
+func byCharacter(data []models.SnapshotData) [][]models.SnapshotData {
+	var groups [][]models.SnapshotData
+	for i, d := range data {
+		if i == 0 || d.CharacterId != data[i-1].CharacterId {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], d)
+	}
+	return groups
+}
+
+func matchesBuild(s, ref history.BuildSummary, by MatchBy) bool {
+	if by == MatchAscendancy {
+		return ref.Ascendancy != "" && s.Ascendancy == ref.Ascendancy
+	}
+	return ref.MainSkill != "" && s.MainSkill == ref.MainSkill
+}
+
+func thresholdValue(d models.SnapshotData, stats map[string]models.SnapshotStats, stat string) (float64, bool) {
+	if stat == "level" {
+		return float64(d.Items.Character.Level), true
+	}
+	s, ok := stats[d.SnapshotId]
+	if !ok {
+		return 0, false
+	}
+	v := repository.StatValue(s, stat)
+	if v == nil {
+		return 0, false
+	}
+	return *v, true
+}
+
+// stepUpgrades lists the items equipped, gems added and key passives
+// allocated between two consecutive snapshots.
+func stepUpgrades(prev, cur models.SnapshotData, trees *passivetree.Registry) []upgradeKey {
+	var keys []upgradeKey
+
+	before := gearKeys(prev.Items)
+	for slot, k := range gearKeys(cur.Items) {
+		if b, ok := before[slot]; !ok || b.id != k.id {
+			keys = append(keys, k.key)
+		}
+	}
+
+	prevBuild, curBuild := history.Summarize(prev, trees), history.Summarize(cur, trees)
+	keys = append(keys, addedNames(UpgradeGem, prevBuild.SkillGems, curBuild.SkillGems)...)
+	keys = append(keys, addedNames(UpgradeSupport, prevBuild.Supports, curBuild.Supports)...)
+
+	allocated := make(map[int]bool, len(prev.Passives.Hashes))
+	for _, h := range prev.Passives.Hashes {
+		allocated[h] = true
+	}
+	tree := trees.ForLeague(cur.Items.Character.League)
+	for _, h := range cur.Passives.Hashes {
+		if allocated[h] {
+			continue
+		}
+		if k, ok := passiveKey(tree, h); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+type gearKey struct {
+	id  string
+	key upgradeKey
+}
+
+// gearKeys indexes the rare and unique gear of a character by slot.
+// Uniques are keyed by name and rares by base type.
+func gearKeys(items models.ItemsResponse) map[string]gearKey {
+	keys := make(map[string]gearKey)
+	for _, item := range items.Items {
+		if !isUpgradeSlot(item.InventoryID) {
+			continue
+		}
+		k := upgradeKey{kind: UpgradeItem, slot: upgradeSlot(item.InventoryID)}
+		switch item.Rarity {
+		case "Unique":
+			k.name = history.ItemLabel(item)
+		case "Rare":
+			k.name = "Rare " + item.BaseType
+		default:
+			continue
+		}
+		slot := item.InventoryID
+		if slot == "Flask" {
+			slot += strconv.Itoa(item.X)
+		}
+		keys[slot] = gearKey{id: item.ID, key: k}
+	}
+	return keys
+}
+
+func isUpgradeSlot(inventoryId string) bool {
+	for _, s := range history.GearSlots {
+		if s == inventoryId {
+			return true
+		}
+	}
+	return inventoryId == "Flask"
+}
+
+// upgradeSlot merges both ring slots so ring upgrades are counted together.
+func upgradeSlot(inventoryId string) string {
+	if inventoryId == "Ring2" {
+		return "Ring"
+	}
+	return inventoryId
+}
+
+func addedNames(kind UpgradeKind, before, after []string) []upgradeKey {
+	had := make(map[string]bool, len(before))
+	for _, n := range before {
+		had[n] = true
+	}
+	var keys []upgradeKey
+	for _, n := range after {
+		if !had[n] {
+			keys = append(keys, upgradeKey{kind: kind, name: n})
+		}
+	}
+	return keys
+}
+
+func passiveKey(tree *passivetree.Tree, hash int) (upgradeKey, bool) {
+	n, ok := tree.Node(hash)
+	if !ok {
+		return upgradeKey{}, false
+	}
+	switch n.Type() {
+	case passivetree.NodeKeystone:
+		return upgradeKey{kind: UpgradeKeystone, name: n.Name}, true
+	case passivetree.NodeNotable:
+		return upgradeKey{kind: UpgradeNotable, name: n.Name}, true
+	case passivetree.NodeAscendancy:
+		if n.IsNotable {
+			return upgradeKey{kind: UpgradeAscendancyNotable, name: n.Name}, true
+		}
+	}
+	return upgradeKey{}, false
+}
+
+// ownedUpgrades is everything the reference character already has, so it
+// is left out of the report.
+func ownedUpgrades(d models.SnapshotData, build history.BuildSummary, trees *passivetree.Registry) map[upgradeKey]bool {
+	owned := make(map[upgradeKey]bool)
+	for _, k := range gearKeys(d.Items) {
+		owned[k.key] = true
+	}
+	for _, n := range build.SkillGems {
+		owned[upgradeKey{kind: UpgradeGem, name: n}] = true
+	}
+	for _, n := range build.Supports {
+		owned[upgradeKey{kind: UpgradeSupport, name: n}] = true
+	}
+	tree := trees.ForLeague(d.Items.Character.League)
+	for _, h := range d.Passives.Hashes {
+		if k, ok := passiveKey(tree, h); ok {
+			owned[k] = true
+		}
+	}
+	return owned
+}
+
+// BuildUpgradeReport loads the snapshots the report needs and builds it.
+// Stats are only loaded when the threshold is a PoB stat.
+func BuildUpgradeReport(repo *repository.Repository, trees *passivetree.Registry, characterId string, params UpgradeParams) (UpgradeReport, error) {
+	reference, err := repo.GetSnapshotDataByCharacter(characterId)
+	if err != nil {
+		return UpgradeReport{}, err
+	}
+	params.Reference = reference
+
+	params.Tracked, err = repo.GetRecentSnapshotData(MaxSnapshots)
+	if err != nil {
+		return UpgradeReport{}, err
+	}
+
+	if params.Stat != "" && params.Stat != "level" {
+		stats, err := repo.GetRecentSnapshotStats(MaxSnapshots)
+		if err != nil {
+			return UpgradeReport{}, err
+		}
+		params.Stats = make(map[string]models.SnapshotStats, len(stats)+1)
+		for _, s := range stats {
+			params.Stats[s.SnapshotId] = s
+		}
+		if len(reference) > 0 {
+			latest := reference[len(reference)-1].SnapshotId
+			s, err := repo.GetSnapshotStats(latest)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return UpgradeReport{}, err
+			}
+			if err == nil {
+				params.Stats[latest] = s
+			}
+		}
+	}
+	return Upgrades(params, trees)
+}
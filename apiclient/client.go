@@ -0,0 +1,302 @@
+// Package apiclient is a typed client for the /api/v1 routes described in
+// openapi/openapi.json, for scripts that drive a running tracker.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/idempotency"
+	"github.com/ByChanderZap/exile-tracker/models"
+	apimodels "github.com/ByChanderZap/exile-tracker/models/api"
+	"github.com/ByChanderZap/exile-tracker/roster"
+)
+
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// New returns a client for the tracker at baseURL, e.g.
+// "http://localhost:3000/api/v1". apiKey may be empty when only public reads
+// are made.
+func New(baseURL string, apiKey string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is a non 2xx answer from the API. Code is one of the apierror
+// codes, like "not_found" or "validation_failed".
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+	Details    []apierror.FieldError
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("api error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	for _, d := range e.Details {
+		msg += fmt.Sprintf("; %s %s", d.Field, d.Message)
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" [request %s]", e.RequestID)
+	}
+	return msg
+}
+
+// ListParams pages and filters list calls. Filters holds the extra query
+// parameters of the endpoint, like league, died or class.
+type ListParams struct {
+	Limit   int
+	Cursor  string
+	Sort    string
+	Desc    bool
+	Filters url.Values
+}
+
+func (p ListParams) query() url.Values {
+	q := url.Values{}
+	for k, v := range p.Filters {
+		q[k] = v
+	}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.Desc {
+		q.Set("order", "desc")
+	}
+	return q
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey sends key as the Idempotency-Key of the POSTs made
+// with ctx. Reuse the same key when retrying a create to get the resource
+// created by the first attempt instead of a duplicate.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// Message is the body of writes that don't return a resource.
+type Message struct {
+	Message string `json:"message"`
+}
+
+func (c *Client) ListAccounts(ctx context.Context, params ListParams) (models.Page[models.Account], error) {
+	var page models.Page[models.Account]
+	err := c.do(ctx, http.MethodGet, "/accounts", params.query(), nil, &page)
+	return page, err
+}
+
+func (c *Client) GetAccount(ctx context.Context, id string) (models.Account, error) {
+	var acc models.Account
+	err := c.do(ctx, http.MethodGet, "/accounts/"+url.PathEscape(id), nil, nil, &acc)
+	return acc, err
+}
+
+func (c *Client) CreateAccount(ctx context.Context, input apimodels.CreateAccountInput) (models.Account, error) {
+	var acc models.Account
+	err := c.do(ctx, http.MethodPost, "/accounts", nil, input, &acc)
+	return acc, err
+}
+
+func (c *Client) UpdateAccount(ctx context.Context, id string, input apimodels.UpdateAccountInput) (Message, error) {
+	var msg Message
+	err := c.do(ctx, http.MethodPut, "/accounts/"+url.PathEscape(id), nil, input, &msg)
+	return msg, err
+}
+
+func (c *Client) ListCharacters(ctx context.Context, params ListParams) (models.Page[models.Character], error) {
+	var page models.Page[models.Character]
+	err := c.do(ctx, http.MethodGet, "/characters", params.query(), nil, &page)
+	return page, err
+}
+
+func (c *Client) ListAccountCharacters(ctx context.Context, accountId string, params ListParams) (models.Page[models.Character], error) {
+	var page models.Page[models.Character]
+	err := c.do(ctx, http.MethodGet, "/characters/account/"+url.PathEscape(accountId), params.query(), nil, &page)
+	return page, err
+}
+
+func (c *Client) GetCharacter(ctx context.Context, id string) (models.Character, error) {
+	var char models.Character
+	err := c.do(ctx, http.MethodGet, "/characters/"+url.PathEscape(id), nil, nil, &char)
+	return char, err
+}
+
+func (c *Client) CreateCharacter(ctx context.Context, input apimodels.CreateCharacterInput) (models.Character, error) {
+	var char models.Character
+	err := c.do(ctx, http.MethodPost, "/characters", nil, input, &char)
+	return char, err
+}
+
+func (c *Client) UpdateCharacter(ctx context.Context, id string, input apimodels.UpdateCharacterInput) (Message, error) {
+	var msg Message
+	err := c.do(ctx, http.MethodPut, "/characters/"+url.PathEscape(id), nil, input, &msg)
+	return msg, err
+}
+
+func (c *Client) KillCharacter(ctx context.Context, id string) (Message, error) {
+	var msg Message
+	err := c.do(ctx, http.MethodPatch, "/characters/"+url.PathEscape(id)+"/kill", nil, nil, &msg)
+	return msg, err
+}
+
+func (c *Client) ListCharactersToFetch(ctx context.Context, league string) ([]models.CharactersToFetch, error) {
+	var ctf []models.CharactersToFetch
+	q := url.Values{}
+	if league != "" {
+		q.Set("league", league)
+	}
+	err := c.do(ctx, http.MethodGet, "/characters/to-fetch", q, nil, &ctf)
+	return ctf, err
+}
+
+// AddCharacterToFetch enrolls a character in the fetcher, or returns its
+// enrollment when it already is.
+func (c *Client) AddCharacterToFetch(ctx context.Context, characterId string) (models.CharactersToFetch, error) {
+	var ctf models.CharactersToFetch
+	input := apimodels.AddCharacterToFetchInput{CharacterId: characterId}
+	err := c.do(ctx, http.MethodPost, "/characters/to-fetch", nil, input, &ctf)
+	return ctf, err
+}
+
+func (c *Client) GetCharacterToFetch(ctx context.Context, characterId string) (models.CharactersToFetch, error) {
+	var ctf models.CharactersToFetch
+	err := c.do(ctx, http.MethodGet, "/characters/to-fetch/"+url.PathEscape(characterId), nil, nil, &ctf)
+	return ctf, err
+}
+
+func (c *Client) ListSnapshots(ctx context.Context, characterId string, params ListParams) (models.Page[models.POBSnapshot], error) {
+	var page models.Page[models.POBSnapshot]
+	err := c.do(ctx, http.MethodGet, "/pobsnapshots/character/"+url.PathEscape(characterId), params.query(), nil, &page)
+	return page, err
+}
+
+func (c *Client) GetLatestSnapshot(ctx context.Context, characterId string) (models.POBSnapshot, error) {
+	var snap models.POBSnapshot
+	err := c.do(ctx, http.MethodGet, "/pobsnapshots/character/"+url.PathEscape(characterId)+"/latest", nil, nil, &snap)
+	return snap, err
+}
+
+func (c *Client) GetSnapshot(ctx context.Context, id string) (models.POBSnapshot, error) {
+	var snap models.POBSnapshot
+	err := c.do(ctx, http.MethodGet, "/pobsnapshots/"+url.PathEscape(id), nil, nil, &snap)
+	return snap, err
+}
+
+// ListSnapshotStats returns the stats of every snapshot of a character, or
+// only the ones where the dropped stat went down when it is set.
+func (c *Client) ListSnapshotStats(ctx context.Context, characterId string, dropped string) ([]models.SnapshotStats, error) {
+	var stats []models.SnapshotStats
+	q := url.Values{}
+	if dropped != "" {
+		q.Set("dropped", dropped)
+	}
+	err := c.do(ctx, http.MethodGet, "/pobsnapshots/character/"+url.PathEscape(characterId)+"/stats", q, nil, &stats)
+	return stats, err
+}
+
+func (c *Client) GetSnapshotStats(ctx context.Context, id string) (models.SnapshotStats, error) {
+	var stats models.SnapshotStats
+	err := c.do(ctx, http.MethodGet, "/pobsnapshots/"+url.PathEscape(id)+"/stats", nil, nil, &stats)
+	return stats, err
+}
+
+// Import creates or updates the accounts and characters of entries. CSV
+// lists can be sent to /import as text/csv directly.
+func (c *Client) Import(ctx context.Context, entries []models.RosterEntry) (roster.Report, error) {
+	var report roster.Report
+	err := c.do(ctx, http.MethodPost, "/import", nil, roster.Document{Entries: entries}, &report)
+	return report, err
+}
+
+// Export lists every account with its characters, of realm when set.
+func (c *Client) Export(ctx context.Context, realm string) ([]models.RosterEntry, error) {
+	query := url.Values{}
+	if realm != "" {
+		query.Set("realm", realm)
+	}
+	var doc roster.Document
+	err := c.do(ctx, http.MethodGet, "/export", query, nil, &doc)
+	return doc.Entries, err
+}
+
+// do sends a request and decodes a 2xx answer into out, anything else into
+// an *Error.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, in any, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok && method == http.MethodPost {
+		req.Header.Set(idempotency.Header, key)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var body struct {
+			Error apierror.Error `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil || body.Error.Message == "" {
+			body.Error.Message = http.StatusText(res.StatusCode)
+		}
+		return &Error{
+			StatusCode: res.StatusCode,
+			Code:       body.Error.Code,
+			Message:    body.Error.Message,
+			RequestID:  body.Error.RequestID,
+			Details:    body.Error.Details,
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
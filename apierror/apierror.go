@@ -0,0 +1,130 @@
+// Package apierror is the error body every /api/v1 route answers with:
+//
+//	{"error": {"code": "not_found", "message": "character not found", "request_id": "...", "details": [...]}}
+//
+// code is stable and meant for programs, message for people. details lists
+// the fields of a rejected request body.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeValidationFailed = "validation_failed"
+	CodeInvalidReference = "invalid_reference"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	// CodeIdempotencyKeyReused is an Idempotency-Key sent again with a
+	// different request.
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeInternal             = "internal"
+)
+
+var log = utils.ChildLogger("api")
+
+// FieldError is one way a request body doesn't match what the route
+// expects. Field is the JSON path of the offending value, empty for the
+// body itself.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type response struct {
+	Error *Error `json:"error"`
+}
+
+func New(status int, code string, format string, args ...any) *Error {
+	return &Error{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// BadRequest is a request body that couldn't be read or decoded.
+func BadRequest(err error) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: err.Error()}
+}
+
+// InvalidParameter is a query parameter, path parameter or field value
+// the route doesn't accept.
+func InvalidParameter(err error) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidParameter, Message: err.Error()}
+}
+
+// InvalidReference is a field pointing to a record that doesn't exist.
+func InvalidReference(format string, args ...any) *Error {
+	return New(http.StatusBadRequest, CodeInvalidReference, format, args...)
+}
+
+func NotFound(format string, args ...any) *Error {
+	return New(http.StatusNotFound, CodeNotFound, format, args...)
+}
+
+func Conflict(format string, args ...any) *Error {
+	return New(http.StatusConflict, CodeConflict, format, args...)
+}
+
+// ValidationFailed is a request body that doesn't match its schema.
+func ValidationFailed(details []FieldError) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidationFailed,
+		Message: "request body doesn't match the API schema",
+		Details: details,
+	}
+}
+
+// From maps err to the error answered to the client. Errors already of
+// this package are kept, the repository sentinels get their status and
+// anything else is an internal error whose cause is only logged.
+func From(err error) *Error {
+	var apiErr *Error
+	switch {
+	case errors.As(err, &apiErr):
+		e := *apiErr
+		return &e
+	case errors.Is(err, repository.ErrNotFound):
+		return NotFound("%s", err.Error())
+	case errors.Is(err, repository.ErrConflict):
+		return Conflict("%s", err.Error())
+	case errors.Is(err, repository.ErrInvalidReference):
+		return InvalidReference("%s", err.Error())
+	case repository.IsInvalidPage(err):
+		return InvalidParameter(err)
+	default:
+		return New(http.StatusInternalServerError, CodeInternal, "internal server error")
+	}
+}
+
+// Write answers the request with err mapped by From, tagged with the ID of
+// the request so it can be found in the logs.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+	e.RequestID = middleware.GetReqID(r.Context())
+	if e.Status > 499 {
+		log.Error().Err(err).Str("request_id", e.RequestID).Str("method", r.Method).Str("url", r.URL.String()).Msg("Request failed")
+	}
+	utils.WriteJSON(w, e.Status, response{Error: e})
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Scope is what an API key is allowed to do. Admin keys can do anything a
+// read key can.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeAdmin Scope = "admin"
+)
+
+// KeyPrefix starts every generated key so they are easy to spot in configs
+// and logs.
+const KeyPrefix = "et_"
+
+func ParseScope(s string) (Scope, error) {
+	switch Scope(s) {
+	case ScopeRead, ScopeAdmin:
+		return Scope(s), nil
+	}
+	return "", fmt.Errorf("invalid scope %q, expected %q or %q", s, ScopeRead, ScopeAdmin)
+}
+
+// Allows reports whether a key with scope s can be used where required is
+// needed.
+func (s Scope) Allows(required Scope) bool {
+	return s == ScopeAdmin || s == required
+}
+
+// GenerateKey returns a new random key, the short prefix stored to identify
+// it and the hash stored in its place.
+func GenerateKey() (key string, prefix string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = KeyPrefix + hex.EncodeToString(b)
+	return key, key[:len(KeyPrefix)+8], HashKey(key), nil
+}
+
+// HashKey hashes a key for storage. Keys are long random strings, so a
+// plain SHA-256 is enough to make a leaked table useless.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/rs/zerolog"
+)
+
+// Middleware requires an admin API key on every request that isn't a read
+// (GET, HEAD or OPTIONS). Reads are open unless requireKeyForReads is set,
+// in which case they need at least a read key. Keys are sent as
+// "Authorization: Bearer <key>" or in the X-API-Key header.
+func Middleware(repo *repository.Repository, requireKeyForReads bool, log zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := ScopeAdmin
+			if isRead(r.Method) {
+				if !requireKeyForReads {
+					next.ServeHTTP(w, r)
+					return
+				}
+				required = ScopeRead
+			}
+
+			key := keyFromRequest(r)
+			if key == "" {
+				apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "missing API key"))
+				return
+			}
+
+			apiKey, err := repo.GetActiveAPIKeyByHash(HashKey(key))
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "invalid API key"))
+					return
+				}
+				apierror.Write(w, r, err)
+				return
+			}
+
+			if !Scope(apiKey.Scope).Allows(required) {
+				apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "API key scope %q can't be used for this request", apiKey.Scope))
+				return
+			}
+
+			if err := repo.TouchAPIKey(apiKey.ID); err != nil {
+				log.Warn().Err(err).Str("api_key", apiKey.Prefix).Msg("Failed to update API key last use")
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)))
+		})
+	}
+}
+
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the API key Middleware checked for the request.
+// Reads don't carry one unless keys are required for them.
+func APIKeyFromContext(ctx context.Context) (models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(models.APIKey)
+	return key, ok
+}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func keyFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinPasswordLength = 8
+	// bcrypt ignores anything past 72 bytes.
+	MaxPasswordLength = 72
+)
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a hash made by
+// HashPassword.
+func CheckPassword(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// ValidateCredentials checks a username and password picked at registration.
+func ValidateCredentials(username string, password string) error {
+	if n := utf8.RuneCountInString(username); n < 3 || n > 32 {
+		return fmt.Errorf("username must be between 3 and 32 characters")
+	}
+	if strings.ContainsFunc(username, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}) {
+		return fmt.Errorf("username can only contain letters, digits, '_' and '-'")
+	}
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("password must be between %d and %d characters", MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
+}
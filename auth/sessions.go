@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/rs/zerolog"
+)
+
+const (
+	SessionCookie   = "et_session"
+	SessionDuration = 30 * 24 * time.Hour
+)
+
+type contextKey struct{}
+
+// UserFromContext returns the user SessionMiddleware found for the request.
+func UserFromContext(ctx context.Context) (models.User, bool) {
+	user, ok := ctx.Value(contextKey{}).(models.User)
+	return user, ok
+}
+
+// StartSession creates a session for a user and sets its cookie. Only the
+// hash of the token is stored, like API keys. Expired sessions are pruned on
+// the way.
+func StartSession(w http.ResponseWriter, r *http.Request, repo *repository.Repository, userId string) error {
+	if err := repo.DeleteExpiredSessions(); err != nil {
+		return err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+	expiresAt := time.Now().Add(SessionDuration)
+
+	err := repo.CreateSession(repository.CreateSessionParams{
+		TokenHash: HashKey(token),
+		UserId:    userId,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// EndSession deletes the request's session, if any, and clears its cookie.
+func EndSession(w http.ResponseWriter, r *http.Request, repo *repository.Repository) error {
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		if err := repo.DeleteSession(HashKey(c.Value)); err != nil {
+			return err
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// SessionMiddleware puts the user of a valid session cookie in the request
+// context. Requests without one go through anonymously.
+func SessionMiddleware(repo *repository.Repository, log zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, err := r.Cookie(SessionCookie)
+			if err != nil || c.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := repo.GetUserBySession(HashKey(c.Value))
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					log.Error().Err(err).Msg("Failed to load session")
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, user)))
+		})
+	}
+}
+
+// RequireUser redirects anonymous requests to the login page, htmx requests
+// are told to do the redirect themselves.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := UserFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		target := "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", target)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+	})
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -4,29 +4,50 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/ByChanderZap/exile-tracker/auth"
+	"github.com/ByChanderZap/exile-tracker/idempotency"
+	"github.com/ByChanderZap/exile-tracker/openapi"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
 	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/services"
 	"github.com/ByChanderZap/exile-tracker/services/accounts"
+	analyticsroutes "github.com/ByChanderZap/exile-tracker/services/analytics"
 	"github.com/ByChanderZap/exile-tracker/services/characters"
 	"github.com/ByChanderZap/exile-tracker/services/frontend"
+	"github.com/ByChanderZap/exile-tracker/services/history"
+	ladderroutes "github.com/ByChanderZap/exile-tracker/services/ladder"
+	"github.com/ByChanderZap/exile-tracker/services/leagues"
+	passivetreeroutes "github.com/ByChanderZap/exile-tracker/services/passivetree"
 	"github.com/ByChanderZap/exile-tracker/services/pobsnapshots"
+	rosterroutes "github.com/ByChanderZap/exile-tracker/services/roster"
 	"github.com/ByChanderZap/exile-tracker/utils"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 )
 
 type APIServer struct {
-	addr       string
-	server     *http.Server
-	repository *repository.Repository
-	log        zerolog.Logger
+	addr               string
+	server             *http.Server
+	repository         *repository.Repository
+	trees              *passivetree.Registry
+	ladder             *services.LadderImporter
+	requireKeyForReads bool
+	allowRegistration  bool
+	watchFetchLimit    int
+	log                zerolog.Logger
 }
 
-func NewAPIServer(addr string, db *repository.Repository) *APIServer {
+func NewAPIServer(addr string, db *repository.Repository, trees *passivetree.Registry, ladder *services.LadderImporter, requireKeyForReads bool, allowRegistration bool, watchFetchLimit int) *APIServer {
 	utils.BaseLogger.Info().Msg(addr)
 	return &APIServer{
-		addr:       addr,
-		repository: db,
-		log:        utils.ChildLogger("api"),
+		addr:               addr,
+		repository:         db,
+		trees:              trees,
+		ladder:             ladder,
+		requireKeyForReads: requireKeyForReads,
+		allowRegistration:  allowRegistration,
+		watchFetchLimit:    watchFetchLimit,
+		log:                utils.ChildLogger("api"),
 	}
 }
 
@@ -36,6 +57,22 @@ func (s *APIServer) Start() error {
 	v1Router := chi.NewRouter()
 	frontendRouter := chi.NewRouter()
 
+	// every request gets an ID, echoed in X-Request-Id and in API errors
+	router.Use(utils.RequestIDMiddleware)
+
+	// writes need an admin API key, reads a read key when configured
+	v1Router.Use(auth.Middleware(s.repository, s.requireKeyForReads, s.log))
+
+	// request bodies are checked against the OpenAPI document
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		return err
+	}
+	v1Router.Use(validator.Middleware("/api/v1"))
+	// POSTs sent with an Idempotency-Key are only run once
+	v1Router.Use(idempotency.Middleware(s.repository, s.log))
+	v1Router.Get("/openapi.json", openapi.Handler)
+
 	// character endpoints
 	cHandler := characters.NewHandler(s.repository, s.log)
 	cHandler.RegisterRoutes(v1Router)
@@ -44,12 +81,36 @@ func (s *APIServer) Start() error {
 	aHandler := accounts.NewHandler(s.repository, s.log)
 	aHandler.RegisterRoutes(v1Router)
 
+	// leagues endpoints
+	lHandler := leagues.NewHandler(s.repository, s.log)
+	lHandler.RegisterRoutes(v1Router)
+
+	// ladder import endpoints
+	ldHandler := ladderroutes.NewHandler(s.repository, s.ladder, s.log)
+	ldHandler.RegisterRoutes(v1Router)
+
+	// bulk import and export endpoints
+	rHandler := rosterroutes.NewHandler(s.repository, s.log)
+	rHandler.RegisterRoutes(v1Router)
+
 	// pobsnapshots endpoints
 	poeHandler := pobsnapshots.NewHandler(s.repository)
 	poeHandler.RegisterRoutes(v1Router)
 
+	// history endpoints
+	hHandler := history.NewHandler(s.repository, s.trees, s.log)
+	hHandler.RegisterRoutes(v1Router)
+
+	// analytics endpoints
+	anHandler := analyticsroutes.NewHandler(s.repository, s.trees, s.log)
+	anHandler.RegisterRoutes(v1Router)
+
+	// passive tree endpoints
+	ptHandler := passivetreeroutes.NewHandler(s.trees)
+	ptHandler.RegisterRoutes(v1Router)
+
 	// frontend endpoints
-	fHandler := frontend.NewHandler(s.repository, s.log)
+	fHandler := frontend.NewHandler(s.repository, s.trees, s.allowRegistration, s.requireKeyForReads, s.watchFetchLimit, s.log)
 	fHandler.RegisterRoutes(frontendRouter)
 
 	router.Mount("/api/v1", v1Router)
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/auth"
+	"github.com/ByChanderZap/exile-tracker/poeclient"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/roster"
+)
+
+const usage = `usage:
+  exile-tracker                                         run the API server and fetcher
+  exile-tracker apikey create -name <name> [-scope read|admin]
+  exile-tracker apikey list
+  exile-tracker apikey revoke <id or prefix>
+  exile-tracker dedupe [-dry-run]                       merge duplicate accounts and characters
+  exile-tracker import [-format json|csv] <file|->      create or update accounts and characters from a list
+  exile-tracker export [-format json|csv] [-realm <realm>] [-o <file>]`
+
+// runCommand runs a CLI subcommand instead of the server.
+func runCommand(repo *repository.Repository, args []string) error {
+	switch args[0] {
+	case "apikey":
+		return runAPIKeyCommand(repo, args[1:])
+	case "dedupe":
+		return runDedupeCommand(repo, args[1:])
+	case "import":
+		return runImportCommand(repo, args[1:])
+	case "export":
+		return runExportCommand(repo, args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+}
+
+func runAPIKeyCommand(repo *repository.Repository, args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := fs.String("name", "", "who or what the key is for")
+		scopeFlag := fs.String("scope", string(auth.ScopeRead), "read or admin")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *name == "" {
+			return errors.New("-name is required")
+		}
+		scope, err := auth.ParseScope(*scopeFlag)
+		if err != nil {
+			return err
+		}
+
+		key, prefix, hash, err := auth.GenerateKey()
+		if err != nil {
+			return err
+		}
+		created, err := repo.CreateAPIKey(repository.CreateAPIKeyParams{
+			Name:    *name,
+			Prefix:  prefix,
+			KeyHash: hash,
+			Scope:   string(scope),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %s key %q (%s)\n", created.Scope, created.Name, created.ID)
+		fmt.Println("Store it now, it can't be shown again:")
+		fmt.Println(key)
+		return nil
+
+	case "list":
+		keys, err := repo.GetAllAPIKeys()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tPREFIX\tNAME\tSCOPE\tCREATED\tLAST USED\tREVOKED")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				k.ID, k.Prefix, k.Name, k.Scope, k.CreatedAt.Format(time.DateTime), formatOptional(k.LastUsedAt), formatOptional(k.RevokedAt))
+		}
+		return w.Flush()
+
+	case "revoke":
+		if len(args) < 2 {
+			return errors.New("usage: exile-tracker apikey revoke <id or prefix>")
+		}
+		err := repo.RevokeAPIKey(args[1])
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no active key matches %q", args[1])
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("Revoked", args[1])
+		return nil
+	}
+	return fmt.Errorf("unknown apikey command %q\n%s", args[0], usage)
+}
+
+// runDedupeCommand merges the duplicates left from before the unique
+// indexes existed. It has to run before migrating to them.
+func runDedupeCommand(repo *repository.Repository, args []string) error {
+	fs := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be merged")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := repo.MergeDuplicates(*dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Println("Dry run, nothing was changed:")
+	}
+	fmt.Printf("Merged %d duplicate accounts and %d duplicate characters\n", report.Accounts, report.Characters)
+	fmt.Printf("Moved %d snapshots, removed %d repeated snapshots\n", report.SnapshotsMoved, report.SnapshotsRemoved)
+	fmt.Printf("Removed %d repeated ladder entries and %d repeated experience samples\n", report.LadderEntriesRemoved, report.ExperienceSamplesRemoved)
+	fmt.Printf("Removed %d repeated characters to fetch\n", report.FetchEntries)
+	return nil
+}
+
+// runImportCommand imports a list the way POST /api/v1/import does. The
+// format is taken from the file extension unless -format is given.
+func runImportCommand(repo *repository.Repository, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatFlag := fs.String("format", "", "json or csv, from the file extension when omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: exile-tracker import [-format json|csv] <file|->")
+	}
+	path := fs.Arg(0)
+	if *formatFlag == "" && strings.EqualFold(filepath.Ext(path), ".csv") {
+		*formatFlag = roster.FormatCSV
+	}
+	format, err := roster.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	entries, err := roster.Read(in, format)
+	if err != nil {
+		return err
+	}
+	if errs := roster.Validate(entries); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", e.Field, e.Message)
+		}
+		return fmt.Errorf("%d invalid fields, nothing was imported", len(errs))
+	}
+
+	report, err := roster.Import(repo, entries)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %d accounts and %d characters, updated %d accounts and %d characters\n",
+		report.AccountsCreated, report.CharactersCreated, report.AccountsUpdated, report.CharactersUpdated)
+	fmt.Printf("Tracked %d characters, untracked %d, paused %d, resumed %d\n",
+		report.Tracked, report.Untracked, report.Paused, report.Resumed)
+	return nil
+}
+
+// runExportCommand writes the list GET /api/v1/export answers, to stdout
+// unless -o is given.
+func runExportCommand(repo *repository.Repository, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatFlag := fs.String("format", "", "json or csv, from the -o extension when omitted")
+	realm := fs.String("realm", "", "only export accounts of this realm")
+	output := fs.String("o", "", "file to write, stdout when omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *formatFlag == "" && strings.EqualFold(filepath.Ext(*output), ".csv") {
+		*formatFlag = roster.FormatCSV
+	}
+	format, err := roster.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	if *realm != "" && !poeclient.IsValidRealm(*realm) {
+		return fmt.Errorf("invalid realm %q, expected one of %v", *realm, poeclient.Realms)
+	}
+
+	entries, err := repo.GetRoster(*realm)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return roster.Write(os.Stdout, format, entries)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := roster.Write(f, format, entries); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d entries to %s\n", len(entries), *output)
+	return nil
+}
+
+func formatOptional(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
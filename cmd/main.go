@@ -11,6 +11,8 @@ import (
 	"github.com/ByChanderZap/exile-tracker/cmd/api"
 	"github.com/ByChanderZap/exile-tracker/config"
 	"github.com/ByChanderZap/exile-tracker/db"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+	"github.com/ByChanderZap/exile-tracker/pob"
 	"github.com/ByChanderZap/exile-tracker/poeclient"
 	"github.com/ByChanderZap/exile-tracker/repository"
 	"github.com/ByChanderZap/exile-tracker/services"
@@ -29,9 +31,28 @@ func main() {
 	initStorage(db, log)
 	repo := repository.NewRepository(db)
 
-	server := api.NewAPIServer(config.Envs.Port, repo)
-	poeClient := poeclient.NewPoeClient(10 * time.Second)
-	fetcher := services.NewFetcherService(repo, poeClient, 20*time.Minute)
+	if len(os.Args) > 1 {
+		if err := runCommand(repo, os.Args[1:]); err != nil {
+			log.Fatal().Err(err).Msg("Command failed")
+		}
+		return
+	}
+
+	trees := passivetree.NewRegistry(config.Envs.PassiveTreeDir)
+	if len(trees.Versions()) == 0 {
+		log.Warn().Str("dir", config.Envs.PassiveTreeDir).Msg("No passive tree data found, run make passivetree to download it")
+	}
+
+	poeClient := poeclient.NewPoeClient(10*time.Second, config.Envs.POEAPIBaseUrl)
+	ladder := services.NewLadderImporter(repo, poeClient)
+	server := api.NewAPIServer(config.Envs.Port, repo, trees, ladder, config.Envs.APIKeysRequiredForReads, config.Envs.AllowRegistration, int(config.Envs.WatchFetchLimit))
+	pobPool := pob.NewPool(pob.PoolConfig{
+		Size:             int(config.Envs.POBWorkers),
+		MaxJobsPerWorker: int(config.Envs.POBWorkerMaxJobs),
+		LuaJITPath:       config.Envs.LuaJITPath,
+		POBRoot:          config.Envs.POBRoot,
+	})
+	fetcher := services.NewFetcherService(repo, poeClient, pobPool, 20*time.Minute)
 
 	// Start server in a goroutine
 	go func() {
@@ -40,10 +61,20 @@ func main() {
 		}
 	}()
 
-	/* Start fetcher service in a goroutine */
-	go func() {
-		fetcher.Start(context.Background())
-	}()
+	if err := fetcher.Start(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start fetcher service")
+	}
+
+	// The ladder importer only runs on a schedule when a league is configured
+	if config.Envs.LadderLeague != "" {
+		ladder.Start(context.Background(), services.LadderImportParams{
+			League: config.Envs.LadderLeague,
+			Realm:  config.Envs.LadderRealm,
+			Top:    int(config.Envs.LadderTop),
+			Class:  config.Envs.LadderClass,
+			Skill:  config.Envs.LadderSkill,
+		}, time.Duration(config.Envs.LadderIntervalInHours)*time.Hour)
+	}
 
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
@@ -62,6 +93,9 @@ func main() {
 	// Stop fetcher gracefully
 	log.Info().Msg("Shutting down fetcher")
 	fetcher.Stop()
+	if config.Envs.LadderLeague != "" {
+		ladder.Stop()
+	}
 
 	log.Info().Msg("Application shutdown complete.")
 }
@@ -11,7 +11,7 @@ import templruntime "github.com/a-h/templ/runtime"
 import "github.com/ByChanderZap/exile-tracker/models"
 import "fmt"
 
-func CharactersByAccountId(characters []models.Character, accountId string, stringValue func(*string) string) templ.Component {
+func CharactersByAccountId(characters models.Page[models.Character], moreURL string, accountId string, leagues []models.League, accountWatched bool, watched map[string]bool, stringValue func(*string) string) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -32,7 +32,23 @@ func CharactersByAccountId(characters []models.Character, accountId string, stri
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html><head><link href=\"https://cdn.jsdelivr.net/npm/daisyui@4.4.18/dist/full.min.css\" rel=\"stylesheet\" type=\"text/css\"><script src=\"https://cdn.tailwindcss.com\"></script><script src=\"/static/htmx.min.js\"></script><link rel=\"icon\" type=\"image/x-icon\" href=\"/static/favicon.ico\"></head><body class=\"min-h-screen w-full bg-[#101014] text-white\" style=\"\n        background-image:\n          repeating-linear-gradient(0deg, rgba(255,255,255,0.04) 0, rgba(255,255,255,0.04) 1px, transparent 1px, transparent 40px),\n          repeating-linear-gradient(45deg, rgba(0,255,128,0.09) 0, rgba(0,255,128,0.09) 1px, transparent 1px, transparent 20px),\n          repeating-linear-gradient(-45deg, rgba(255,0,128,0.10) 0, rgba(255,0,128,0.10) 1px, transparent 1px, transparent 30px),\n          repeating-linear-gradient(90deg, rgba(255,255,255,0.03) 0, rgba(255,255,255,0.03) 1px, transparent 1px, transparent 80px),\n          radial-gradient(circle at 60% 40%, rgba(0,255,128,0.05) 0, transparent 60%);\n        background-size: 80px 80px, 40px 40px, 60px 60px, 80px 80px, 100% 100%;\n        background-position: 0 0, 0 0, 0 0, 40px 40px, center;\n      \"><div class=\"container mx-auto lg:py-8\"><div class=\"text-center mb-8\"><h1 class=\"text-3xl font-bold mb-2\">Exile Tracker</h1></div><div class=\"flex flex-col items-center\"><div class=\"flex flex-row gap-4 items-center justify-center mb-6\"><a href=\"#\" class=\"text-lg font-medium hover:text-pink-400 transition\">Accounts</a> <a href=\"#\" class=\"text-lg font-medium hover:text-pink-400 transition\">Characters</a> <a href=\"#\" class=\"text-lg font-medium hover:text-pink-400 transition\">Snapshots</a></div><label class=\"mb-1 text-sm text-gray-300\" for=\"search-accounts\">Search accounts</label> <input id=\"search-characters\" type=\"text\" placeholder=\"Search...\" class=\"input input-bordered w-64 text-black bg-transparent text-white\" hx-get=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html><head><link href=\"https://cdn.jsdelivr.net/npm/daisyui@4.4.18/dist/full.min.css\" rel=\"stylesheet\" type=\"text/css\"><script src=\"https://cdn.tailwindcss.com\"></script><script src=\"/static/htmx.min.js\"></script><link rel=\"icon\" type=\"image/x-icon\" href=\"/static/favicon.ico\"></head><body class=\"min-h-screen w-full bg-[#101014] text-white\" style=\"\n        background-image:\n          repeating-linear-gradient(0deg, rgba(255,255,255,0.04) 0, rgba(255,255,255,0.04) 1px, transparent 1px, transparent 40px),\n          repeating-linear-gradient(45deg, rgba(0,255,128,0.09) 0, rgba(0,255,128,0.09) 1px, transparent 1px, transparent 20px),\n          repeating-linear-gradient(-45deg, rgba(255,0,128,0.10) 0, rgba(255,0,128,0.10) 1px, transparent 1px, transparent 30px),\n          repeating-linear-gradient(90deg, rgba(255,255,255,0.03) 0, rgba(255,255,255,0.03) 1px, transparent 1px, transparent 80px),\n          radial-gradient(circle at 60% 40%, rgba(0,255,128,0.05) 0, transparent 60%);\n        background-size: 80px 80px, 40px 40px, 60px 60px, 80px 80px, 100% 100%;\n        background-position: 0 0, 0 0, 0 0, 40px 40px, center;\n      \"><div class=\"container mx-auto lg:py-8\"><div class=\"text-center mb-8\"><h1 class=\"text-3xl font-bold mb-2\">Exile Tracker</h1></div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = UserNav().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<div class=\"flex flex-col items-center\"><div class=\"flex flex-row gap-4 items-center justify-center mb-6\"><a href=\"#\" class=\"text-lg font-medium hover:text-pink-400 transition\">Accounts</a> <a href=\"#\" class=\"text-lg font-medium hover:text-pink-400 transition\">Characters</a> <a href=\"#\" class=\"text-lg font-medium hover:text-pink-400 transition\">Snapshots</a></div><div class=\"mb-4\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = WatchButton(fmt.Sprintf("/watchlist/accounts/%s", accountId), accountWatched).Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</div><form class=\"flex flex-col items-center\" hx-get=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -45,54 +61,29 @@ func CharactersByAccountId(characters []models.Character, accountId string, stri
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" hx-trigger=\"keyup changed delay:500ms\" hx-target=\"#characters-table\" hx-include=\"[name='q']\" name=\"q\"></div><div class=\"flex flex-col gap-4 justify-center items-center bg-transparent md:pt-8\"><div id=\"characters-table\" class=\"w-full max-w-4xl mx-auto border border-gray-600 rounded-lg overflow-hidden backdrop-blur-sm\"><div class=\"flex font-bold bg-gray-400 bg-opacity-15\"><div class=\"flex-1 px-4 py-3 border-r border-gray-600\">ID</div><div class=\"flex-1 px-4 py-3 border-r border-gray-600\">Character Name</div></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\" hx-trigger=\"input delay:500ms\" hx-target=\"#characters-table\" onsubmit=\"return false\"><label class=\"mb-1 text-sm text-gray-300\" for=\"search-characters\">Search characters</label> <input id=\"search-characters\" type=\"text\" placeholder=\"Search...\" class=\"input input-bordered w-64 text-black bg-transparent text-white\" name=\"q\"><div class=\"flex flex-row flex-wrap justify-center gap-2 mt-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = LeagueSelect(leagues, "", templ.Attributes{}).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		for _, c := range characters {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<a href=\"")
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			var templ_7745c5c3_Var3 templ.SafeURL
-			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinURLErrs(fmt.Sprintf("/snapshots/%s", c.ID))
-			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `cmd/web/templates/characters_by_account_id.templ`, Line: 68, Col: 57}
-			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\" class=\"flex hover:bg-gray-700/50 transition-colors border-b border-gray-600 last:border-b-0\"><div class=\"flex-1 px-4 py-3 border-r border-gray-600 text-white break-all\">")
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			var templ_7745c5c3_Var4 string
-			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(c.ID)
-			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `cmd/web/templates/characters_by_account_id.templ`, Line: 70, Col: 17}
-			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</div><div class=\"flex-1 px-4 py-3 border-r border-gray-600 text-white\">")
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			var templ_7745c5c3_Var5 string
-			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(c.CharacterName)
-			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `cmd/web/templates/characters_by_account_id.templ`, Line: 73, Col: 28}
-			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</div></a>")
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<input type=\"text\" name=\"class\" placeholder=\"Class\" class=\"input input-bordered input-sm w-32 bg-transparent text-white\"> <select name=\"died\" class=\"select select-bordered select-sm bg-transparent text-white\"><option value=\"\">Alive and dead</option> <option value=\"false\">Alive</option> <option value=\"true\">Dead</option></select> <select name=\"tracked\" class=\"select select-bordered select-sm bg-transparent text-white\"><option value=\"\">Tracked or not</option> <option value=\"true\">Tracked</option> <option value=\"false\">Not tracked</option></select>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = SortSelect(CharacterSorts, "", false).Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</div></form></div><div class=\"flex flex-col gap-4 justify-center items-center bg-transparent md:pt-8\"><div id=\"characters-table\" class=\"w-full max-w-4xl mx-auto border border-gray-600 rounded-lg overflow-hidden backdrop-blur-sm\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = CharactersTable(characters, watched, moreURL, stringValue).Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
 		}
 		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</div></div></div></body></html>")
 		if templ_7745c5c3_Err != nil {
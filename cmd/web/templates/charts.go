@@ -0,0 +1,163 @@
+package templates
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Charts are drawn as inline SVG on the server, so the pages showing them
+// load no charting script.
+const (
+	ChartWidth   = 720.0
+	ChartHeight  = 220.0
+	chartPadding = 40.0
+)
+
+// ChartSeries is a set of values over time. Nil values are skipped. Keys
+// identify each sample so annotations can be attached to it. Inverted
+// series draw lower values higher, e.g. ladder ranks.
+type ChartSeries struct {
+	Title    string
+	Keys     []string
+	Times    []time.Time
+	Values   []*float64
+	Inverted bool
+}
+
+// ChartAnnotation marks something that happened at the sample with Key.
+type ChartAnnotation struct {
+	Key   string
+	Label string
+}
+
+type ChartPoint struct {
+	X     float64
+	Y     float64
+	Label string
+}
+
+type ChartMarker struct {
+	X     float64
+	Label string
+}
+
+// LineChart holds the already scaled coordinates of an SVG line chart.
+type LineChart struct {
+	Title      string
+	Points     []ChartPoint
+	Markers    []ChartMarker
+	MinLabel   string
+	MaxLabel   string
+	StartLabel string
+	EndLabel   string
+}
+
+func NewLineChart(series ChartSeries, annotations []ChartAnnotation) LineChart {
+	chart := LineChart{Title: series.Title}
+	if len(series.Times) == 0 {
+		return chart
+	}
+
+	start, end := series.Times[0], series.Times[len(series.Times)-1]
+	xOf := func(t time.Time, i int) float64 {
+		span := end.Sub(start)
+		if span <= 0 {
+			if len(series.Times) == 1 {
+				return ChartWidth / 2
+			}
+			return chartPadding + float64(i)/float64(len(series.Times)-1)*(ChartWidth-2*chartPadding)
+		}
+		return chartPadding + float64(t.Sub(start))/float64(span)*(ChartWidth-2*chartPadding)
+	}
+
+	minV, maxV := 0.0, 0.0
+	first := true
+	for _, v := range series.Values {
+		if v == nil {
+			continue
+		}
+		if first || *v < minV {
+			minV = *v
+		}
+		if first || *v > maxV {
+			maxV = *v
+		}
+		first = false
+	}
+	yOf := func(v float64) float64 {
+		if maxV == minV {
+			return ChartHeight / 2
+		}
+		frac := (v - minV) / (maxV - minV)
+		if series.Inverted {
+			frac = 1 - frac
+		}
+		return ChartHeight - chartPadding - frac*(ChartHeight-2*chartPadding)
+	}
+
+	for i, t := range series.Times {
+		if i >= len(series.Values) || series.Values[i] == nil {
+			continue
+		}
+		v := *series.Values[i]
+		chart.Points = append(chart.Points, ChartPoint{
+			X:     xOf(t, i),
+			Y:     yOf(v),
+			Label: fmt.Sprintf("%s: %s", t.Format("2006-01-02 15:04"), FormatStat(v)),
+		})
+	}
+
+	for _, a := range annotations {
+		for i, key := range series.Keys {
+			if key == a.Key && i < len(series.Times) {
+				chart.Markers = append(chart.Markers, ChartMarker{X: xOf(series.Times[i], i), Label: a.Label})
+				break
+			}
+		}
+	}
+
+	if !first {
+		chart.MinLabel = FormatStat(minV)
+		chart.MaxLabel = FormatStat(maxV)
+		if series.Inverted {
+			chart.MinLabel, chart.MaxLabel = chart.MaxLabel, chart.MinLabel
+		}
+	}
+	chart.StartLabel = start.Format("Jan 2")
+	chart.EndLabel = end.Format("Jan 2")
+	return chart
+}
+
+// Path is the SVG path data connecting every point of the chart.
+func (c LineChart) Path() string {
+	var sb strings.Builder
+	for i, p := range c.Points {
+		if i == 0 {
+			sb.WriteString("M")
+		} else {
+			sb.WriteString(" L")
+		}
+		sb.WriteString(Coord(p.X))
+		sb.WriteString(" ")
+		sb.WriteString(Coord(p.Y))
+	}
+	return sb.String()
+}
+
+func Coord(f float64) string {
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
+
+// FormatStat shortens large numbers, e.g. 1250000 becomes "1.25M".
+func FormatStat(v float64) string {
+	switch {
+	case v >= 1_000_000 || v <= -1_000_000:
+		return strconv.FormatFloat(v/1_000_000, 'f', 2, 64) + "M"
+	case v >= 10_000 || v <= -10_000:
+		return strconv.FormatFloat(v/1_000, 'f', 1, 64) + "k"
+	}
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
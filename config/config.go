@@ -9,11 +9,24 @@ import (
 )
 
 type Config struct {
-	Port                   string
-	POEAPIBaseUrl          string
-	FetchIntervalInMinutes int64
-	DBPath                 string
-	POBRoot                string
+	Port                    string
+	POEAPIBaseUrl           string
+	FetchIntervalInMinutes  int64
+	DBPath                  string
+	POBRoot                 string
+	LuaJITPath              string
+	POBWorkers              int64
+	POBWorkerMaxJobs        int64
+	PassiveTreeDir          string
+	LadderLeague            string
+	LadderRealm             string
+	LadderTop               int64
+	LadderClass             string
+	LadderSkill             string
+	LadderIntervalInHours   int64
+	APIKeysRequiredForReads bool
+	AllowRegistration       bool
+	WatchFetchLimit         int64
 }
 
 var Envs = initConfig()
@@ -23,11 +36,24 @@ func initConfig() Config {
 		log.Println("Couldnt load env file")
 	}
 	return Config{
-		Port:                   getEnv("PORT", ":3000"),
-		POEAPIBaseUrl:          getEnv("POE_API_BASE_URL", "https://api.example.com"),
-		FetchIntervalInMinutes: getEnvAsInt("FETCH_INTERVAL_IN_MINUTES", 30),
-		DBPath:                 getEnv("DB_PATH", "./data.db"),
-		POBRoot:                getEnv("POB_ROOT", "/home/alexander/dev/goofing/PathOfBuilding"),
+		Port:                    getEnv("PORT", ":3000"),
+		POEAPIBaseUrl:           getEnv("POE_API_BASE_URL", "https://api.pathofexile.com"),
+		FetchIntervalInMinutes:  getEnvAsInt("FETCH_INTERVAL_IN_MINUTES", 30),
+		DBPath:                  getEnv("DB_PATH", "./data.db"),
+		POBRoot:                 getEnv("POB_ROOT", "/home/alexander/dev/goofing/PathOfBuilding"),
+		LuaJITPath:              getEnv("LUAJIT_PATH", "/usr/bin/luajit"),
+		POBWorkers:              getEnvAsInt("POB_WORKERS", 2),
+		POBWorkerMaxJobs:        getEnvAsInt("POB_WORKER_MAX_JOBS", 50),
+		PassiveTreeDir:          getEnv("PASSIVE_TREE_DIR", "./data/passivetree"),
+		LadderLeague:            getEnv("LADDER_LEAGUE", ""),
+		LadderRealm:             getEnv("LADDER_REALM", "pc"),
+		LadderTop:               getEnvAsInt("LADDER_TOP", 50),
+		LadderClass:             getEnv("LADDER_CLASS", ""),
+		LadderSkill:             getEnv("LADDER_SKILL", ""),
+		LadderIntervalInHours:   getEnvAsInt("LADDER_INTERVAL_IN_HOURS", 6),
+		APIKeysRequiredForReads: getEnvAsBool("API_KEYS_REQUIRED_FOR_READS", false),
+		AllowRegistration:       getEnvAsBool("ALLOW_REGISTRATION", false),
+		WatchFetchLimit:         getEnvAsInt("WATCH_FETCH_LIMIT", 10),
 	}
 }
 
@@ -48,3 +74,14 @@ func getEnvAsInt(key string, fallback int64) int64 {
 	}
 	return fallback
 }
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+		return b
+	}
+	return fallback
+}
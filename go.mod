@@ -9,6 +9,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/mattn/go-sqlite3 v1.14.31
 	github.com/rs/zerolog v1.34.0
+	golang.org/x/crypto v0.41.0
 )
 
 require (
@@ -0,0 +1,151 @@
+package history
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+)
+
+// ascendancyClasses maps every ascendancy to the class it belongs to. The
+// API reports the ascendancy as the character class once a character has
+// ascended.
+var ascendancyClasses = map[string]string{
+	"Slayer":       "Duelist",
+	"Gladiator":    "Duelist",
+	"Champion":     "Duelist",
+	"Juggernaut":   "Marauder",
+	"Berserker":    "Marauder",
+	"Chieftain":    "Marauder",
+	"Deadeye":      "Ranger",
+	"Raider":       "Ranger",
+	"Warden":       "Ranger",
+	"Pathfinder":   "Ranger",
+	"Necromancer":  "Witch",
+	"Elementalist": "Witch",
+	"Occultist":    "Witch",
+	"Inquisitor":   "Templar",
+	"Hierophant":   "Templar",
+	"Guardian":     "Templar",
+	"Assassin":     "Shadow",
+	"Trickster":    "Shadow",
+	"Saboteur":     "Shadow",
+	"Ascendant":    "Scion",
+}
+
+// SplitClass returns the base class and the ascendancy of a character class
+// as reported by the API. The ascendancy is empty for characters that have
+// not ascended yet.
+func SplitClass(class string) (base string, ascendancy string) {
+	if base, ok := ascendancyClasses[class]; ok {
+		return base, class
+	}
+	return class, ""
+}
+
+// ClassNames returns every class name the API may report for characters of
+// class: a base class also matches its ascendancies. Names are matched
+// ignoring case and returned as the API spells them.
+func ClassNames(class string) []string {
+	for asc, base := range ascendancyClasses {
+		if strings.EqualFold(asc, class) {
+			return []string{asc}
+		}
+		if strings.EqualFold(base, class) {
+			names := []string{base}
+			for a, b := range ascendancyClasses {
+				if b == base {
+					names = append(names, a)
+				}
+			}
+			sort.Strings(names[1:])
+			return names
+		}
+	}
+	return []string{class}
+}
+
+// BuildSummary is what a character was playing at one snapshot.
+type BuildSummary struct {
+	SnapshotId         string   `json:"snapshot_id"`
+	League             string   `json:"league"`
+	Class              string   `json:"class"`
+	Ascendancy         string   `json:"ascendancy"`
+	Level              int      `json:"level"`
+	MainSkill          string   `json:"main_skill"`
+	Uniques            []string `json:"uniques"`
+	SkillGems          []string `json:"skill_gems"`
+	Supports           []string `json:"supports"`
+	Keystones          []string `json:"keystones"`
+	AscendancyNotables []string `json:"ascendancy_notables"`
+}
+
+// Summarize lists the uniques, gems and key passives of a snapshot. Every
+// list is sorted and holds each name once.
+func Summarize(d models.SnapshotData, trees *passivetree.Registry) BuildSummary {
+	s := BuildSummary{
+		SnapshotId: d.SnapshotId,
+		League:     d.Items.Character.League,
+		Level:      d.Items.Character.Level,
+		MainSkill:  MainSkillName(d.Items),
+	}
+	s.Class, s.Ascendancy = SplitClass(d.Items.Character.Class)
+
+	uniques := make(map[string]bool)
+	for _, item := range d.Items.Items {
+		if item.Rarity == "Unique" && (isGearSlot(item.InventoryID) || item.InventoryID == "Flask") {
+			uniques[cleanName(item.Name)] = true
+		}
+	}
+	for _, item := range d.Passives.Items {
+		if item.Rarity == "Unique" {
+			uniques[cleanName(item.Name)] = true
+		}
+	}
+	s.Uniques = sortedKeys(uniques)
+
+	gems := make(map[string]bool)
+	supports := make(map[string]bool)
+	for _, g := range SkillGroups(d.Items) {
+		for _, gem := range g.Gems {
+			if gem.Support {
+				supports[gem.Name] = true
+			} else {
+				gems[gem.Name] = true
+			}
+		}
+	}
+	s.SkillGems = sortedKeys(gems)
+	s.Supports = sortedKeys(supports)
+
+	tree := trees.ForLeague(d.Items.Character.League)
+	keystones := make(map[string]bool)
+	ascendancy := make(map[string]bool)
+	for _, hash := range d.Passives.Hashes {
+		n, ok := tree.Node(hash)
+		if !ok {
+			continue
+		}
+		switch {
+		case n.IsKeystone:
+			keystones[n.Name] = true
+		case n.AscendancyName != "" && n.IsNotable:
+			ascendancy[n.Name] = true
+		}
+	}
+	s.Keystones = sortedKeys(keystones)
+	s.AscendancyNotables = sortedKeys(ascendancy)
+	return s
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+type ChangeKind string
+
+const (
+	ItemEquipped     ChangeKind = "item_equipped"
+	ItemRemoved      ChangeKind = "item_removed"
+	ItemReplaced     ChangeKind = "item_replaced"
+	GemAdded         ChangeKind = "gem_added"
+	GemRemoved       ChangeKind = "gem_removed"
+	MainSkillChanged ChangeKind = "main_skill_changed"
+	FlaskEquipped    ChangeKind = "flask_equipped"
+	FlaskRemoved     ChangeKind = "flask_removed"
+	FlaskChanged     ChangeKind = "flask_changed"
+	FlaskEnchanted   ChangeKind = "flask_enchanted"
+)
+
+// Change is a single difference between two snapshots of a character.
+type Change struct {
+	Kind   ChangeKind `json:"kind"`
+	Slot   string     `json:"slot,omitempty"`
+	Before string     `json:"before,omitempty"`
+	After  string     `json:"after,omitempty"`
+	Major  bool       `json:"major"`
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ItemEquipped:
+		return fmt.Sprintf("%s: equipped %s", c.Slot, c.After)
+	case ItemRemoved:
+		return fmt.Sprintf("%s: removed %s", c.Slot, c.Before)
+	case ItemReplaced:
+		return fmt.Sprintf("%s: %s -> %s", c.Slot, c.Before, c.After)
+	case GemAdded:
+		return fmt.Sprintf("added gem %s", c.After)
+	case GemRemoved:
+		return fmt.Sprintf("removed gem %s", c.Before)
+	case FlaskEquipped:
+		return fmt.Sprintf("%s: equipped %s", c.Slot, c.After)
+	case FlaskRemoved:
+		return fmt.Sprintf("%s: removed %s", c.Slot, c.Before)
+	case FlaskChanged:
+		return fmt.Sprintf("%s: %s -> %s", c.Slot, c.Before, c.After)
+	case FlaskEnchanted:
+		if c.Before == "" {
+			return fmt.Sprintf("%s: enchanted with %s", c.Slot, c.After)
+		}
+		return fmt.Sprintf("%s: enchant %s -> %s", c.Slot, c.Before, c.After)
+	case MainSkillChanged:
+		return fmt.Sprintf("main skill %s -> %s", c.Before, c.After)
+	}
+	return string(c.Kind)
+}
+
+// Diff lists what changed in a character's equipment, flasks and gems
+// between two snapshots.
+func Diff(prev, cur models.ItemsResponse) []Change {
+	var changes []Change
+	changes = append(changes, diffGear(prev, cur)...)
+	changes = append(changes, diffFlasks(prev, cur)...)
+	changes = append(changes, diffGems(prev, cur)...)
+
+	before, after := MainSkillName(prev), MainSkillName(cur)
+	if before != after {
+		changes = append(changes, Change{
+			Kind:   MainSkillChanged,
+			Before: before,
+			After:  after,
+			Major:  true,
+		})
+	}
+	return changes
+}
+
+// MajorChanges filters the changes worth highlighting on a timeline.
+func MajorChanges(changes []Change) []Change {
+	var major []Change
+	for _, c := range changes {
+		if c.Major {
+			major = append(major, c)
+		}
+	}
+	return major
+}
+
+func diffGear(prev, cur models.ItemsResponse) []Change {
+	before, after := equippedGear(prev), equippedGear(cur)
+
+	var changes []Change
+	for _, slot := range GearSlots {
+		b, hadBefore := before[slot]
+		a, hasAfter := after[slot]
+		switch {
+		case !hadBefore && hasAfter:
+			changes = append(changes, Change{Kind: ItemEquipped, Slot: slot, After: ItemLabel(a), Major: isNotable(a)})
+		case hadBefore && !hasAfter:
+			changes = append(changes, Change{Kind: ItemRemoved, Slot: slot, Before: ItemLabel(b)})
+		case hadBefore && hasAfter && b.ID != a.ID:
+			changes = append(changes, Change{
+				Kind:   ItemReplaced,
+				Slot:   slot,
+				Before: ItemLabel(b),
+				After:  ItemLabel(a),
+				Major:  isNotable(a) || isNotable(b),
+			})
+		}
+	}
+	return changes
+}
+
+// isNotable reports whether swapping this item is a real gear upgrade rather
+// than a levelling placeholder.
+func isNotable(item models.Item) bool {
+	return item.Rarity == "Rare" || item.Rarity == "Unique"
+}
+
+func diffGems(prev, cur models.ItemsResponse) []Change {
+	count := func(items models.ItemsResponse) map[string]int {
+		counts := make(map[string]int)
+		for _, g := range SkillGroups(items) {
+			for _, gem := range g.Gems {
+				counts[gem.Name]++
+			}
+		}
+		return counts
+	}
+	before, after := count(prev), count(cur)
+
+	mainGems := make(map[string]bool)
+	if g, ok := MainSkill(cur); ok {
+		for _, gem := range g.Gems {
+			mainGems[gem.Name] = true
+		}
+	}
+	if g, ok := MainSkill(prev); ok {
+		for _, gem := range g.Gems {
+			mainGems[gem.Name] = true
+		}
+	}
+
+	var changes []Change
+	for name, n := range after {
+		if n > before[name] {
+			changes = append(changes, Change{Kind: GemAdded, After: name, Major: mainGems[name]})
+		}
+	}
+	for name, n := range before {
+		if n > after[name] {
+			changes = append(changes, Change{Kind: GemRemoved, Before: name, Major: mainGems[name]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].String() < changes[j].String()
+	})
+	return changes
+}
@@ -0,0 +1,211 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// FlaskSlots is the number of flask slots on a character's belt.
+const FlaskSlots = 5
+
+// Flask is a flask in one belt slot. Prefix and Suffix are only set for
+// magic flasks, where the API folds them into the type line.
+type Flask struct {
+	ItemId       string   `json:"item_id"`
+	Slot         int      `json:"slot"`
+	Name         string   `json:"name"`
+	BaseType     string   `json:"base_type"`
+	Rarity       string   `json:"rarity"`
+	Prefix       string   `json:"prefix,omitempty"`
+	Suffix       string   `json:"suffix,omitempty"`
+	Quality      int      `json:"quality"`
+	EnchantMods  []string `json:"enchant_mods"`
+	ImplicitMods []string `json:"implicit_mods"`
+	ExplicitMods []string `json:"explicit_mods"`
+	CraftedMods  []string `json:"crafted_mods"`
+	UtilityMods  []string `json:"utility_mods"`
+}
+
+// Label is the display name of the flask, e.g. "Divine Life Flask" or
+// "Bubbling Divine Life Flask of Staunching".
+func (f Flask) Label() string {
+	if f.Name != "" {
+		return f.Name + " " + f.BaseType
+	}
+	parts := make([]string, 0, 3)
+	if f.Prefix != "" {
+		parts = append(parts, f.Prefix)
+	}
+	parts = append(parts, f.BaseType)
+	if f.Suffix != "" {
+		parts = append(parts, f.Suffix)
+	}
+	return strings.Join(parts, " ")
+}
+
+// setupKey identifies what a flask does, so re-rolling or enchanting a
+// flask counts as a change even though its item id stays the same.
+func (f Flask) setupKey() string {
+	return f.Label() + "|" + strings.Join(f.EnchantMods, "|")
+}
+
+// FlaskSlotName is the name used for a belt slot in diffs, e.g. "Flask 1".
+func FlaskSlotName(slot int) string {
+	return fmt.Sprintf("Flask %d", slot+1)
+}
+
+// Flasks returns the flasks a character has equipped, ordered by slot.
+func Flasks(items models.ItemsResponse) []Flask {
+	var flasks []Flask
+	for _, item := range items.Items {
+		if item.InventoryID != "Flask" {
+			continue
+		}
+		flasks = append(flasks, flaskFromItem(item))
+	}
+	sort.Slice(flasks, func(i, j int) bool {
+		return flasks[i].Slot < flasks[j].Slot
+	})
+	return flasks
+}
+
+func flaskFromItem(item models.Item) Flask {
+	f := Flask{
+		ItemId:       item.ID,
+		Slot:         item.X,
+		Name:         cleanName(item.Name),
+		BaseType:     cleanName(item.BaseType),
+		Rarity:       item.Rarity,
+		EnchantMods:  item.EnchantMods,
+		ImplicitMods: item.ImplicitMods,
+		ExplicitMods: item.ExplicitMods,
+		CraftedMods:  item.CraftedMods,
+		UtilityMods:  item.UtilityMods,
+	}
+	for _, p := range item.Properties {
+		if p.Name == "Quality" {
+			f.Quality = propertyInt(p)
+		}
+	}
+	typeLine := cleanName(item.TypeLine)
+	if f.BaseType == "" {
+		f.BaseType = typeLine
+	}
+	if item.Rarity == "Magic" {
+		if i := strings.Index(typeLine, f.BaseType); i >= 0 {
+			f.Prefix = strings.TrimSpace(typeLine[:i])
+			f.Suffix = strings.TrimSpace(typeLine[i+len(f.BaseType):])
+		}
+	}
+	return f
+}
+
+// FlaskPeriod is one flask setup held in a slot, from the first snapshot it
+// was seen in until the snapshot where it was swapped or re-rolled.
+type FlaskPeriod struct {
+	Flask
+	EquippedAt time.Time  `json:"equipped_at"`
+	ReplacedAt *time.Time `json:"replaced_at"`
+	SnapshotId string     `json:"snapshot_id"`
+}
+
+type FlaskSlotTimeline struct {
+	Slot    int           `json:"slot"`
+	Flasks  []FlaskPeriod `json:"flasks"`
+	Current *Flask        `json:"current"`
+}
+
+// FlaskTimeline builds the history of every flask slot from a character's
+// snapshots, which must be ordered oldest first.
+func FlaskTimeline(data []models.SnapshotData) []FlaskSlotTimeline {
+	periods := make([][]FlaskPeriod, FlaskSlots)
+
+	for _, d := range data {
+		bySlot := make(map[int]Flask)
+		for _, f := range Flasks(d.Items) {
+			bySlot[f.Slot] = f
+		}
+		for slot := 0; slot < FlaskSlots; slot++ {
+			current := periods[slot]
+			var last *FlaskPeriod
+			if len(current) > 0 && current[len(current)-1].ReplacedAt == nil {
+				last = &current[len(current)-1]
+			}
+
+			f, ok := bySlot[slot]
+			switch {
+			case !ok && last != nil:
+				at := d.CreatedAt
+				last.ReplacedAt = &at
+			case ok && last != nil && last.setupKey() == f.setupKey():
+				last.Flask = f
+			case ok:
+				if last != nil {
+					at := d.CreatedAt
+					last.ReplacedAt = &at
+				}
+				periods[slot] = append(periods[slot], FlaskPeriod{
+					Flask:      f,
+					EquippedAt: d.CreatedAt,
+					SnapshotId: d.SnapshotId,
+				})
+			}
+		}
+	}
+
+	var timelines []FlaskSlotTimeline
+	for slot, p := range periods {
+		if len(p) == 0 {
+			continue
+		}
+		t := FlaskSlotTimeline{Slot: slot, Flasks: p}
+		if last := p[len(p)-1]; last.ReplacedAt == nil {
+			t.Current = &last.Flask
+		}
+		timelines = append(timelines, t)
+	}
+	return timelines
+}
+
+func diffFlasks(prev, cur models.ItemsResponse) []Change {
+	before := make(map[int]Flask)
+	for _, f := range Flasks(prev) {
+		before[f.Slot] = f
+	}
+	after := make(map[int]Flask)
+	for _, f := range Flasks(cur) {
+		after[f.Slot] = f
+	}
+
+	var changes []Change
+	for slot := 0; slot < FlaskSlots; slot++ {
+		b, hadBefore := before[slot]
+		a, hasAfter := after[slot]
+		name := FlaskSlotName(slot)
+		switch {
+		case !hadBefore && hasAfter:
+			changes = append(changes, Change{Kind: FlaskEquipped, Slot: name, After: a.Label(), Major: a.Rarity == "Unique"})
+		case hadBefore && !hasAfter:
+			changes = append(changes, Change{Kind: FlaskRemoved, Slot: name, Before: b.Label(), Major: b.Rarity == "Unique"})
+		case hadBefore && hasAfter && b.setupKey() != a.setupKey():
+			c := Change{
+				Kind:   FlaskChanged,
+				Slot:   name,
+				Before: b.Label(),
+				After:  a.Label(),
+				Major:  a.Rarity == "Unique" || b.Rarity == "Unique",
+			}
+			if c.Before == c.After {
+				c.Before = strings.Join(b.EnchantMods, ", ")
+				c.After = strings.Join(a.EnchantMods, ", ")
+				c.Kind = FlaskEnchanted
+			}
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,66 @@
+package history
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// GearSlots are the inventory ids of equipped gear, in display order.
+var GearSlots = []string{
+	"Weapon",
+	"Offhand",
+	"Weapon2",
+	"Offhand2",
+	"Helm",
+	"BodyArmour",
+	"Gloves",
+	"Boots",
+	"Amulet",
+	"Ring",
+	"Ring2",
+	"Belt",
+}
+
+var markupRe = regexp.MustCompile(`<<[^>]*>>`)
+
+// cleanName removes the <<set:MS>> style markup the API adds to some names.
+func cleanName(s string) string {
+	return strings.TrimSpace(markupRe.ReplaceAllString(s, ""))
+}
+
+// ItemLabel is a short human readable name for an item, e.g.
+// "Tabula Rasa Simple Robe" or "Doom Hold Vaal Regalia".
+func ItemLabel(item models.Item) string {
+	name := cleanName(item.Name)
+	base := cleanName(item.TypeLine)
+	if base == "" {
+		base = cleanName(item.BaseType)
+	}
+	if name == "" {
+		return base
+	}
+	return name + " " + base
+}
+
+// isGearSlot reports whether an inventory id is an equipment slot.
+func isGearSlot(inventoryId string) bool {
+	for _, s := range GearSlots {
+		if s == inventoryId {
+			return true
+		}
+	}
+	return false
+}
+
+// equippedGear indexes the equipped gear of a character by slot.
+func equippedGear(items models.ItemsResponse) map[string]models.Item {
+	gear := make(map[string]models.Item)
+	for _, item := range items.Items {
+		if isGearSlot(item.InventoryID) {
+			gear[item.InventoryID] = item
+		}
+	}
+	return gear
+}
@@ -0,0 +1,187 @@
+package history
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+)
+
+type JewelKind string
+
+const (
+	JewelRegular  JewelKind = "regular"
+	JewelAbyss    JewelKind = "abyss"
+	JewelCluster  JewelKind = "cluster"
+	JewelTimeless JewelKind = "timeless"
+)
+
+// Jewel is a jewel socketed in the passive tree.
+type Jewel struct {
+	ItemId          string    `json:"item_id"`
+	Name            string    `json:"name"`
+	BaseType        string    `json:"base_type"`
+	Rarity          string    `json:"rarity"`
+	Kind            JewelKind `json:"kind"`
+	Corrupted       bool      `json:"corrupted"`
+	EnchantMods     []string  `json:"enchant_mods"`
+	ImplicitMods    []string  `json:"implicit_mods"`
+	ExplicitMods    []string  `json:"explicit_mods"`
+	CraftedMods     []string  `json:"crafted_mods"`
+	SocketIndex     int       `json:"socket_index"`
+	Socket          NodeRef   `json:"socket"`
+	Radius          string    `json:"radius,omitempty"`
+	ClusterNotables []string  `json:"cluster_notables,omitempty"`
+	ClusterPassives int       `json:"cluster_passives,omitempty"`
+}
+
+func (j Jewel) Label() string {
+	if j.Name == "" {
+		return j.BaseType
+	}
+	return j.Name + " " + j.BaseType
+}
+
+// JewelSwap is a change of the jewel in one socket.
+type JewelSwap struct {
+	SnapshotId string    `json:"snapshot_id"`
+	TakenAt    time.Time `json:"taken_at"`
+	Socket     NodeRef   `json:"socket"`
+	Before     string    `json:"before,omitempty"`
+	After      string    `json:"after,omitempty"`
+}
+
+type JewelHistory struct {
+	SnapshotId string      `json:"snapshot_id"`
+	TakenAt    time.Time   `json:"taken_at"`
+	Current    []Jewel     `json:"current"`
+	Swaps      []JewelSwap `json:"swaps"`
+}
+
+// Jewels lists the jewels socketed in a snapshot's passive tree with the
+// cluster notables allocated inside each cluster jewel.
+func Jewels(d models.SnapshotData, trees *passivetree.Registry) []Jewel {
+	tree := trees.ForLeague(d.Items.Character.League).WithSubgraphs(d.Passives.JewelData)
+	allocated := passivetree.AllocatedHashes(d.Passives)
+
+	var jewels []Jewel
+	for _, item := range d.Passives.Items {
+		j := Jewel{
+			ItemId:       item.ID,
+			Name:         cleanName(item.Name),
+			BaseType:     cleanName(item.TypeLine),
+			Rarity:       item.Rarity,
+			Kind:         jewelKind(item.BaseType),
+			Corrupted:    item.Corrupted,
+			EnchantMods:  item.EnchantMods,
+			ImplicitMods: item.ImplicitMods,
+			ExplicitMods: item.ExplicitMods,
+			CraftedMods:  item.CraftedMods,
+			SocketIndex:  item.X,
+			Socket:       jewelSocket(item.X, tree),
+		}
+
+		if data, ok := d.Passives.JewelData[strconv.Itoa(item.X)]; ok {
+			j.Radius = data.RadiusVisual
+			if data.Subgraph != nil {
+				for key, n := range data.Subgraph.Nodes {
+					hash, _ := strconv.Atoi(key)
+					if !allocated[hash] || n.IsJewelSocket || n.IsMastery {
+						continue
+					}
+					if n.IsNotable {
+						j.ClusterNotables = append(j.ClusterNotables, n.Name)
+					} else {
+						j.ClusterPassives++
+					}
+				}
+				sort.Strings(j.ClusterNotables)
+			}
+		}
+		jewels = append(jewels, j)
+	}
+
+	sort.Slice(jewels, func(a, b int) bool {
+		return jewels[a].SocketIndex < jewels[b].SocketIndex
+	})
+	return jewels
+}
+
+// JewelsHistory returns the jewels of the latest snapshot and every jewel
+// swap across the snapshots, which must be ordered oldest first.
+func JewelsHistory(data []models.SnapshotData, trees *passivetree.Registry) JewelHistory {
+	var h JewelHistory
+	prev := make(map[int]Jewel)
+
+	for i, d := range data {
+		jewels := Jewels(d, trees)
+		cur := make(map[int]Jewel, len(jewels))
+		for _, j := range jewels {
+			cur[j.SocketIndex] = j
+		}
+
+		if i > 0 {
+			sockets := make(map[int]bool)
+			for idx := range cur {
+				sockets[idx] = true
+			}
+			for idx := range prev {
+				sockets[idx] = true
+			}
+			ordered := make([]int, 0, len(sockets))
+			for idx := range sockets {
+				ordered = append(ordered, idx)
+			}
+			sort.Ints(ordered)
+
+			for _, idx := range ordered {
+				before, hadBefore := prev[idx]
+				after, hasAfter := cur[idx]
+				if hadBefore && hasAfter && before.ItemId == after.ItemId {
+					continue
+				}
+				swap := JewelSwap{SnapshotId: d.SnapshotId, TakenAt: d.CreatedAt}
+				if hadBefore {
+					swap.Socket = before.Socket
+					swap.Before = before.Label()
+				}
+				if hasAfter {
+					swap.Socket = after.Socket
+					swap.After = after.Label()
+				}
+				h.Swaps = append(h.Swaps, swap)
+			}
+		}
+
+		h.SnapshotId = d.SnapshotId
+		h.TakenAt = d.CreatedAt
+		h.Current = jewels
+		prev = cur
+	}
+	return h
+}
+
+func jewelKind(baseType string) JewelKind {
+	switch {
+	case strings.Contains(baseType, "Cluster Jewel"):
+		return JewelCluster
+	case baseType == "Timeless Jewel":
+		return JewelTimeless
+	case strings.HasSuffix(baseType, "Eye Jewel"):
+		return JewelAbyss
+	}
+	return JewelRegular
+}
+
+// jewelSocket resolves the socket a jewel sits in. Jewels socketed inside
+// cluster jewels use the socket node hash instead of a slot index.
+func jewelSocket(x int, tree *passivetree.Tree) NodeRef {
+	hash, ok := tree.JewelSlot(x)
+	if !ok {
+		hash = x
+	}
+	return resolveNode(hash, tree)
+}
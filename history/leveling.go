@@ -0,0 +1,132 @@
+package history
+
+import (
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// LevelRanges are the level brackets leveling time is summed over.
+var LevelRanges = [][2]int{
+	{1, 10}, {10, 20}, {20, 30}, {30, 40}, {40, 50}, {50, 60},
+	{60, 70}, {70, 80}, {80, 90}, {90, 95}, {95, 100},
+}
+
+// LevelTime is how long a character stayed at one level. Current is set for
+// the level the character is still at, in which case Hours runs until the
+// latest sample.
+type LevelTime struct {
+	Level      int       `json:"level"`
+	ReachedAt  time.Time `json:"reached_at"`
+	Hours      float64   `json:"hours"`
+	Experience int64     `json:"experience"`
+	XPPerHour  float64   `json:"xp_per_hour"`
+	Current    bool      `json:"current"`
+}
+
+// LevelRange is the time it took to go from one level to another.
+type LevelRange struct {
+	From  int     `json:"from"`
+	To    int     `json:"to"`
+	Hours float64 `json:"hours"`
+}
+
+type LevelingReport struct {
+	Samples         int          `json:"samples"`
+	FirstSeen       time.Time    `json:"first_seen"`
+	LastSeen        time.Time    `json:"last_seen"`
+	StartLevel      int          `json:"start_level"`
+	Level           int          `json:"level"`
+	Experience      int64        `json:"experience"`
+	XPPerHour       float64      `json:"xp_per_hour"`
+	ActiveXPPerHour float64      `json:"active_xp_per_hour"`
+	ExperienceLost  int64        `json:"experience_lost"`
+	Levels          []LevelTime  `json:"levels"`
+	Ranges          []LevelRange `json:"ranges"`
+}
+
+// Leveling computes leveling speed from experience samples, which must be
+// ordered oldest first. XPPerHour is measured over the whole tracked time
+// while ActiveXPPerHour only counts the time between samples where the
+// character gained experience. A level is considered reached at the first
+// sample it shows up in, so precision is bound to the fetch interval.
+// Ranges are only reported when the character was seen at or below their
+// start level and has reached their end level.
+func Leveling(samples []models.ExperienceSample) LevelingReport {
+	var report LevelingReport
+	if len(samples) == 0 {
+		return report
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	report.Samples = len(samples)
+	report.FirstSeen = first.RecordedAt
+	report.LastSeen = last.RecordedAt
+	report.StartLevel = first.Level
+	report.Level = last.Level
+	report.Experience = last.Experience
+
+	if hours := last.RecordedAt.Sub(first.RecordedAt).Hours(); hours > 0 {
+		report.XPPerHour = float64(last.Experience-first.Experience) / hours
+	}
+
+	var gained int64
+	var activeHours float64
+	reached := map[int]models.ExperienceSample{first.Level: first}
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		delta := cur.Experience - prev.Experience
+		switch {
+		case delta > 0:
+			gained += delta
+			activeHours += cur.RecordedAt.Sub(prev.RecordedAt).Hours()
+		case delta < 0:
+			report.ExperienceLost -= delta
+		}
+		for level := prev.Level + 1; level <= cur.Level; level++ {
+			if _, ok := reached[level]; !ok {
+				reached[level] = cur
+			}
+		}
+	}
+	if activeHours > 0 {
+		report.ActiveXPPerHour = float64(gained) / activeHours
+	}
+
+	for level := first.Level; level <= last.Level; level++ {
+		// Levels gained between two samples were never seen, their time is
+		// part of the last level that was.
+		at, ok := reached[level]
+		if !ok || at.Level != level {
+			continue
+		}
+		lt := LevelTime{Level: level, ReachedAt: at.RecordedAt}
+		end, ok := reached[level+1]
+		if !ok {
+			end = last
+			lt.Current = true
+		}
+		lt.Hours = end.RecordedAt.Sub(at.RecordedAt).Hours()
+		lt.Experience = end.Experience - at.Experience
+		if lt.Hours > 0 {
+			lt.XPPerHour = float64(lt.Experience) / lt.Hours
+		}
+		report.Levels = append(report.Levels, lt)
+	}
+
+	for _, r := range LevelRanges {
+		from, to := r[0], r[1]
+		start, okStart := reached[from]
+		end, okEnd := reached[to]
+		if first.Level > from || !okStart || !okEnd {
+			continue
+		}
+		report.Ranges = append(report.Ranges, LevelRange{
+			From:  from,
+			To:    to,
+			Hours: end.RecordedAt.Sub(start.RecordedAt).Hours(),
+		})
+	}
+
+	return report
+}
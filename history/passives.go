@@ -0,0 +1,167 @@
+package history
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+)
+
+type NodeRef struct {
+	Hash int                  `json:"hash"`
+	Name string               `json:"name"`
+	Type passivetree.NodeType `json:"type"`
+}
+
+type MasteryChange struct {
+	Mastery NodeRef `json:"mastery"`
+	Before  string  `json:"before"`
+	After   string  `json:"after"`
+}
+
+// PassiveStep is what changed in the passive tree at one snapshot.
+type PassiveStep struct {
+	SnapshotId     string          `json:"snapshot_id"`
+	TakenAt        time.Time       `json:"taken_at"`
+	Level          int             `json:"level"`
+	PointsUsed     int             `json:"points_used"`
+	Allocated      []NodeRef       `json:"allocated"`
+	Refunded       []NodeRef       `json:"refunded"`
+	MasteryChanges []MasteryChange `json:"mastery_changes"`
+}
+
+// IsRespec reports whether nodes were refunded in this step.
+func (s PassiveStep) IsRespec() bool {
+	return len(s.Refunded) > 0
+}
+
+// KeyNodePick is the first snapshot a keystone or notable was seen in.
+type KeyNodePick struct {
+	Node       NodeRef   `json:"node"`
+	SnapshotId string    `json:"snapshot_id"`
+	TakenAt    time.Time `json:"taken_at"`
+	Level      int       `json:"level"`
+}
+
+type PassiveProgression struct {
+	Steps    []PassiveStep `json:"steps"`
+	KeyNodes []KeyNodePick `json:"key_nodes"`
+}
+
+// PassiveHistory compares the allocated passives of consecutive snapshots,
+// resolving hashes with the tree of each snapshot's league. Cluster jewel
+// nodes (hashes_ex) are resolved through the jewel subgraphs of the
+// snapshot, refunded ones through those of the snapshot before. Snapshots
+// must be ordered oldest first and only snapshots with changes are kept.
+func PassiveHistory(data []models.SnapshotData, trees *passivetree.Registry) PassiveProgression {
+	var progression PassiveProgression
+	var prevNodes map[int]bool
+	var prevTree *passivetree.Tree
+	var prevMasteries map[string]int
+	seenKey := make(map[int]bool)
+
+	for i, d := range data {
+		tree := trees.ForLeague(d.Items.Character.League).WithSubgraphs(d.Passives.JewelData)
+		nodes := passivetree.AllocatedHashes(d.Passives)
+		step := PassiveStep{
+			SnapshotId: d.SnapshotId,
+			TakenAt:    d.CreatedAt,
+			Level:      d.Items.Character.Level,
+			PointsUsed: len(d.Passives.Hashes),
+		}
+
+		for hash := range nodes {
+			if prevNodes[hash] {
+				continue
+			}
+			step.Allocated = append(step.Allocated, resolveNode(hash, tree))
+		}
+		for hash := range prevNodes {
+			if !nodes[hash] {
+				step.Refunded = append(step.Refunded, resolveNode(hash, prevTree))
+			}
+		}
+
+		for mastery, effect := range d.Passives.MasteryEffects {
+			before, existed := prevMasteries[mastery]
+			if existed && before == effect {
+				continue
+			}
+			hash, _ := strconv.Atoi(mastery)
+			change := MasteryChange{
+				Mastery: resolveNode(hash, tree),
+				After:   strings.Join(tree.MasteryEffectText(hash, effect), ", "),
+			}
+			if existed {
+				change.Before = strings.Join(tree.MasteryEffectText(hash, before), ", ")
+			}
+			step.MasteryChanges = append(step.MasteryChanges, change)
+		}
+
+		sortNodeRefs(step.Allocated)
+		sortNodeRefs(step.Refunded)
+
+		for _, ref := range step.Allocated {
+			isKey := ref.Type == passivetree.NodeKeystone ||
+				ref.Type == passivetree.NodeNotable ||
+				ref.Type == passivetree.NodeAscendancy
+			if !isKey || seenKey[ref.Hash] {
+				continue
+			}
+			seenKey[ref.Hash] = true
+			progression.KeyNodes = append(progression.KeyNodes, KeyNodePick{
+				Node:       ref,
+				SnapshotId: d.SnapshotId,
+				TakenAt:    d.CreatedAt,
+				Level:      step.Level,
+			})
+		}
+		sort.Slice(step.MasteryChanges, func(a, b int) bool {
+			return step.MasteryChanges[a].Mastery.Name < step.MasteryChanges[b].Mastery.Name
+		})
+
+		if i == 0 || len(step.Allocated) > 0 || len(step.Refunded) > 0 || len(step.MasteryChanges) > 0 {
+			progression.Steps = append(progression.Steps, step)
+		}
+
+		prevNodes = nodes
+		prevTree = tree
+		prevMasteries = d.Passives.MasteryEffects
+	}
+
+	return progression
+}
+
+func resolveNode(hash int, tree *passivetree.Tree) NodeRef {
+	n := tree.Resolve(hash)
+	return NodeRef{Hash: hash, Name: n.Name, Type: n.Type}
+}
+
+func sortNodeRefs(refs []NodeRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Type != refs[j].Type {
+			return nodeTypeRank(refs[i].Type) < nodeTypeRank(refs[j].Type)
+		}
+		return refs[i].Name < refs[j].Name
+	})
+}
+
+// nodeTypeRank orders the most interesting nodes first.
+func nodeTypeRank(t passivetree.NodeType) int {
+	switch t {
+	case passivetree.NodeKeystone:
+		return 0
+	case passivetree.NodeAscendancy:
+		return 1
+	case passivetree.NodeNotable:
+		return 2
+	case passivetree.NodeMastery:
+		return 3
+	case passivetree.NodeJewelSocket:
+		return 4
+	}
+	return 5
+}
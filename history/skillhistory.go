@@ -0,0 +1,201 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// LinkChange lists the supports swapped in or out of one skill's links
+// between two snapshots.
+type LinkChange struct {
+	Skill           string   `json:"skill"`
+	Slot            string   `json:"slot"`
+	AddedSupports   []string `json:"added_supports"`
+	RemovedSupports []string `json:"removed_supports"`
+}
+
+// GemChange is a gem of a skill whose level or quality moved between two
+// snapshots.
+type GemChange struct {
+	Skill         string `json:"skill"`
+	Gem           string `json:"gem"`
+	LevelBefore   int    `json:"level_before"`
+	Level         int    `json:"level"`
+	QualityBefore int    `json:"quality_before"`
+	Quality       int    `json:"quality"`
+}
+
+// SkillSetup is a character's socketed gems at a snapshot where they changed.
+type SkillSetup struct {
+	SnapshotId       string       `json:"snapshot_id"`
+	TakenAt          time.Time    `json:"taken_at"`
+	Level            int          `json:"level"`
+	MainSkill        string       `json:"main_skill"`
+	MainSkillChanged bool         `json:"main_skill_changed"`
+	Groups           []SkillGroup `json:"groups"`
+	AddedSkills      []string     `json:"added_skills"`
+	RemovedSkills    []string     `json:"removed_skills"`
+	LinkChanges      []LinkChange `json:"link_changes"`
+	GemChanges       []GemChange  `json:"gem_changes"`
+}
+
+// SkillHistory returns the skill setups of a character, keeping only the
+// first snapshot and those where links, skills, gem levels or quality, or
+// the main skill changed.
+// Snapshots must be ordered oldest first.
+func SkillHistory(data []models.SnapshotData) []SkillSetup {
+	var setups []SkillSetup
+	var prev map[string]SkillGroup
+	prevMain := ""
+
+	for i, d := range data {
+		groups := SkillGroups(d.Items)
+		cur := groupsByKey(groups)
+		main := MainSkillName(d.Items)
+
+		setup := SkillSetup{
+			SnapshotId: d.SnapshotId,
+			TakenAt:    d.CreatedAt,
+			Level:      d.Items.Character.Level,
+			MainSkill:  main,
+			Groups:     groups,
+		}
+
+		if i > 0 {
+			setup.MainSkillChanged = main != prevMain
+			for key, g := range cur {
+				p, ok := prev[key]
+				if !ok {
+					setup.AddedSkills = append(setup.AddedSkills, key)
+					continue
+				}
+				added, removed := supportDiff(p, g)
+				if len(added) > 0 || len(removed) > 0 {
+					setup.LinkChanges = append(setup.LinkChanges, LinkChange{
+						Skill:           key,
+						Slot:            g.Slot,
+						AddedSupports:   added,
+						RemovedSupports: removed,
+					})
+				}
+				setup.GemChanges = append(setup.GemChanges, gemChanges(key, p, g)...)
+			}
+			for key := range prev {
+				if _, ok := cur[key]; !ok {
+					setup.RemovedSkills = append(setup.RemovedSkills, key)
+				}
+			}
+			sort.Strings(setup.AddedSkills)
+			sort.Strings(setup.RemovedSkills)
+			sort.Slice(setup.LinkChanges, func(a, b int) bool {
+				return setup.LinkChanges[a].Skill < setup.LinkChanges[b].Skill
+			})
+			sort.Slice(setup.GemChanges, func(a, b int) bool {
+				ga, gb := setup.GemChanges[a], setup.GemChanges[b]
+				if ga.Skill != gb.Skill {
+					return ga.Skill < gb.Skill
+				}
+				return ga.Gem < gb.Gem
+			})
+		}
+
+		changed := i == 0 ||
+			setup.MainSkillChanged ||
+			len(setup.AddedSkills) > 0 ||
+			len(setup.RemovedSkills) > 0 ||
+			len(setup.LinkChanges) > 0 ||
+			len(setup.GemChanges) > 0
+		if changed {
+			setups = append(setups, setup)
+		}
+
+		prev = cur
+		prevMain = main
+	}
+	return setups
+}
+
+// groupKey identifies a skill group across snapshots by its active gems, or
+// by its slot when it only holds supports.
+func groupKey(g SkillGroup) string {
+	var names []string
+	for _, gem := range g.ActiveGems() {
+		names = append(names, gem.Name)
+	}
+	if len(names) == 0 {
+		return g.Slot + " supports"
+	}
+	sort.Strings(names)
+	return strings.Join(names, " + ")
+}
+
+func groupsByKey(groups []SkillGroup) map[string]SkillGroup {
+	byKey := make(map[string]SkillGroup, len(groups))
+	for _, g := range groups {
+		key := groupKey(g)
+		if existing, ok := byKey[key]; ok && len(existing.Gems) >= len(g.Gems) {
+			continue
+		}
+		byKey[key] = g
+	}
+	return byKey
+}
+
+func supportDiff(prev, cur SkillGroup) (added []string, removed []string) {
+	supports := func(g SkillGroup) map[string]bool {
+		set := make(map[string]bool)
+		for _, gem := range g.Gems {
+			if gem.Support {
+				set[gem.Name] = true
+			}
+		}
+		return set
+	}
+	before, after := supports(prev), supports(cur)
+	for name := range after {
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// gemChanges compares the level and quality of the gems a skill group kept.
+func gemChanges(skill string, prev, cur SkillGroup) []GemChange {
+	before := make(map[string]Gem, len(prev.Gems))
+	for _, gem := range prev.Gems {
+		if _, ok := before[gem.Name]; !ok {
+			before[gem.Name] = gem
+		}
+	}
+	var changes []GemChange
+	for _, gem := range cur.Gems {
+		b, ok := before[gem.Name]
+		if !ok {
+			continue
+		}
+		delete(before, gem.Name)
+		if b.Level == gem.Level && b.Quality == gem.Quality {
+			continue
+		}
+		changes = append(changes, GemChange{
+			Skill:         skill,
+			Gem:           gem.Name,
+			LevelBefore:   b.Level,
+			Level:         gem.Level,
+			QualityBefore: b.Quality,
+			Quality:       gem.Quality,
+		})
+	}
+	return changes
+}
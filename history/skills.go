@@ -0,0 +1,138 @@
+package history
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// frameTypeGem is the frameType the PoE API gives skill and support gems.
+const frameTypeGem = 4
+
+type Gem struct {
+	Name    string `json:"name"`
+	Level   int    `json:"level"`
+	Quality int    `json:"quality"`
+	Support bool   `json:"support"`
+}
+
+// SkillGroup is a set of gems socketed in linked sockets of one item.
+type SkillGroup struct {
+	Slot string `json:"slot"`
+	Item string `json:"item"`
+	Gems []Gem  `json:"gems"`
+}
+
+// ActiveGems returns the non support gems of the group.
+func (g SkillGroup) ActiveGems() []Gem {
+	var active []Gem
+	for _, gem := range g.Gems {
+		if !gem.Support {
+			active = append(active, gem)
+		}
+	}
+	return active
+}
+
+// SkillGroups returns every linked group of socketed gems in equipped gear.
+// Jewels in abyss sockets and anything else that isn't a gem are left out.
+func SkillGroups(items models.ItemsResponse) []SkillGroup {
+	gear := equippedGear(items)
+
+	var groups []SkillGroup
+	for _, slot := range GearSlots {
+		item, ok := gear[slot]
+		if !ok || len(item.SocketedItems) == 0 {
+			continue
+		}
+
+		byLink := make(map[int]*SkillGroup)
+		var order []int
+		for _, si := range item.SocketedItems {
+			if si.FrameType != frameTypeGem {
+				continue
+			}
+			link := si.Socket
+			if si.Socket >= 0 && si.Socket < len(item.Sockets) {
+				if item.Sockets[si.Socket].Attr == "A" {
+					continue
+				}
+				link = item.Sockets[si.Socket].Group
+			}
+			g, ok := byLink[link]
+			if !ok {
+				g = &SkillGroup{Slot: slot, Item: ItemLabel(item)}
+				byLink[link] = g
+				order = append(order, link)
+			}
+			g.Gems = append(g.Gems, gemFromSocketedItem(si))
+		}
+
+		for _, link := range order {
+			groups = append(groups, *byLink[link])
+		}
+	}
+	return groups
+}
+
+// MainSkill is the skill group with the most links that has an active gem.
+// Ties are resolved by gear slot order.
+func MainSkill(items models.ItemsResponse) (SkillGroup, bool) {
+	var main SkillGroup
+	found := false
+	for _, g := range SkillGroups(items) {
+		if len(g.ActiveGems()) == 0 {
+			continue
+		}
+		if !found || len(g.Gems) > len(main.Gems) {
+			main = g
+			found = true
+		}
+	}
+	return main, found
+}
+
+// MainSkillName is the name of the first active gem in the main skill group.
+func MainSkillName(items models.ItemsResponse) string {
+	g, ok := MainSkill(items)
+	if !ok {
+		return ""
+	}
+	return g.ActiveGems()[0].Name
+}
+
+func gemFromSocketedItem(si models.SocketedItem) Gem {
+	gem := Gem{
+		Name:    cleanName(si.TypeLine),
+		Support: si.Support,
+	}
+	for _, p := range si.Properties {
+		switch p.Name {
+		case "Level":
+			gem.Level = propertyInt(p)
+		case "Quality":
+			gem.Quality = propertyInt(p)
+		}
+	}
+	return gem
+}
+
+// propertyInt reads the leading number of a property value such as
+// "20 (Max)" or "+23%".
+func propertyInt(p models.ItemProperty) int {
+	if len(p.Values) == 0 || len(p.Values[0]) == 0 {
+		return 0
+	}
+	s, ok := p.Values[0][0].(string)
+	if !ok {
+		return 0
+	}
+	s = strings.TrimPrefix(s, "+")
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
@@ -0,0 +1,97 @@
+package history
+
+import (
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// ItemPeriod is one item worn in a slot, from the first snapshot it was seen
+// in until the snapshot where something else took its place.
+type ItemPeriod struct {
+	ItemId        string     `json:"item_id"`
+	Name          string     `json:"name"`
+	BaseType      string     `json:"base_type"`
+	Rarity        string     `json:"rarity"`
+	ImplicitMods  []string   `json:"implicit_mods"`
+	ExplicitMods  []string   `json:"explicit_mods"`
+	CraftedMods   []string   `json:"crafted_mods"`
+	FracturedMods []string   `json:"fractured_mods"`
+	EnchantMods   []string   `json:"enchant_mods"`
+	EquippedAt    time.Time  `json:"equipped_at"`
+	ReplacedAt    *time.Time `json:"replaced_at"`
+	SnapshotId    string     `json:"snapshot_id"`
+}
+
+// Label is the short display name of the item.
+func (p ItemPeriod) Label() string {
+	if p.Name == "" {
+		return p.BaseType
+	}
+	return p.Name + " " + p.BaseType
+}
+
+type SlotTimeline struct {
+	Slot  string       `json:"slot"`
+	Items []ItemPeriod `json:"items"`
+}
+
+// ItemTimeline builds the history of every gear slot from a character's
+// snapshots, which must be ordered oldest first. Items are matched by their
+// id, so a crafted or re-rolled item keeps its period and shows its latest
+// mods.
+func ItemTimeline(data []models.SnapshotData) []SlotTimeline {
+	periods := make(map[string][]ItemPeriod)
+
+	for _, d := range data {
+		gear := equippedGear(d.Items)
+		for _, slot := range GearSlots {
+			current := periods[slot]
+			var last *ItemPeriod
+			if len(current) > 0 && current[len(current)-1].ReplacedAt == nil {
+				last = &current[len(current)-1]
+			}
+
+			item, ok := gear[slot]
+			switch {
+			case !ok && last != nil:
+				at := d.CreatedAt
+				last.ReplacedAt = &at
+			case ok && last != nil && last.ItemId == item.ID:
+				setItemMods(last, item)
+			case ok:
+				if last != nil {
+					at := d.CreatedAt
+					last.ReplacedAt = &at
+				}
+				p := ItemPeriod{
+					ItemId:     item.ID,
+					Name:       cleanName(item.Name),
+					BaseType:   cleanName(item.TypeLine),
+					Rarity:     item.Rarity,
+					EquippedAt: d.CreatedAt,
+					SnapshotId: d.SnapshotId,
+				}
+				setItemMods(&p, item)
+				periods[slot] = append(periods[slot], p)
+			}
+		}
+	}
+
+	var timelines []SlotTimeline
+	for _, slot := range GearSlots {
+		if len(periods[slot]) == 0 {
+			continue
+		}
+		timelines = append(timelines, SlotTimeline{Slot: slot, Items: periods[slot]})
+	}
+	return timelines
+}
+
+func setItemMods(p *ItemPeriod, item models.Item) {
+	p.ImplicitMods = item.ImplicitMods
+	p.ExplicitMods = item.ExplicitMods
+	p.CraftedMods = item.CraftedMods
+	p.FracturedMods = item.FracturedMods
+	p.EnchantMods = item.EnchantMods
+}
@@ -0,0 +1,168 @@
+// Package idempotency makes POST requests safe to retry. A request sent
+// with an Idempotency-Key header runs once, retries with the same key get
+// the stored response back instead of creating the resource again.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/auth"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// Header carries the key chosen by the client, unique per operation.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from a previous request.
+	ReplayedHeader = "Idempotent-Replayed"
+	// TTL is how long keys are remembered.
+	TTL = 24 * time.Hour
+
+	maxKeyLength = 255
+	maxBodySize  = 1 << 20
+)
+
+// Middleware stores the response of successful POSTs sent with an
+// Idempotency-Key, scoped to the API key that sent them. A retry with the
+// same key and body gets that response again, a different body under the
+// same key is rejected with 422 and a retry while the first request is
+// still running with 409. Failed requests aren't stored, so they can be
+// retried with the same key. It needs the API key set by auth.Middleware.
+func Middleware(repo *repository.Repository, log zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("%s must be at most %d characters", Header, maxKeyLength)))
+				return
+			}
+			apiKey, ok := auth.APIKeyFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+				if err != nil {
+					apierror.Write(w, r, apierror.BadRequest(fmt.Errorf("couldn't read request body")))
+					return
+				}
+				if len(body) > maxBodySize {
+					apierror.Write(w, r, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "request body is larger than %d bytes", maxBodySize))
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			if err := repo.DeleteExpiredIdempotencyKeys(time.Now().Add(-TTL)); err != nil {
+				log.Warn().Err(err).Msg("Failed to delete expired idempotency keys")
+			}
+
+			hash := requestHash(r, body)
+			rec, reserved, err := repo.ReserveIdempotencyKey(repository.ReserveIdempotencyKeyParams{
+				APIKeyId:    apiKey.ID,
+				Key:         key,
+				Method:      r.Method,
+				Path:        r.URL.Path,
+				RequestHash: hash,
+			})
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+			if !reserved {
+				switch {
+				case rec.RequestHash != hash:
+					apierror.Write(w, r, apierror.New(http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyReused,
+						"%s %q was already used for a different request", Header, key))
+				case rec.Status == nil:
+					apierror.Write(w, r, apierror.Conflict("a request with %s %q is still being processed", Header, key))
+				default:
+					if rec.Location != nil {
+						w.Header().Set("Location", *rec.Location)
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set(ReplayedHeader, "true")
+					w.WriteHeader(*rec.Status)
+					w.Write(rec.ResponseBody)
+				}
+				return
+			}
+
+			release := func() {
+				if err := repo.ReleaseIdempotencyKey(apiKey.ID, key); err != nil {
+					log.Error().Err(err).Str("idempotency_key", key).Msg("Failed to release idempotency key")
+				}
+			}
+			// A handler that panics would leave the key reserved, and every
+			// retry answered with 409 until it expires.
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			rw := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			if rw.status < 200 || rw.status > 299 {
+				release()
+				return
+			}
+			err = repo.CompleteIdempotencyKey(repository.CompleteIdempotencyKeyParams{
+				APIKeyId:     apiKey.ID,
+				Key:          key,
+				Status:       rw.status,
+				Location:     w.Header().Get("Location"),
+				ResponseBody: rw.body.Bytes(),
+			})
+			if err != nil {
+				log.Error().Err(err).Str("idempotency_key", key).Msg("Failed to store idempotent response")
+			}
+		})
+	}
+}
+
+// requestHash tells apart different requests sent under the same key.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes a response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,237 @@
+package idempotency
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/auth"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog"
+)
+
+// newTestRepository opens an in-memory database with every migration
+// applied.
+func newTestRepository(t *testing.T) (*repository.Repository, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("migration %s: %v", filepath.Base(file), err)
+		}
+	}
+	return repository.NewRepository(db), db
+}
+
+// testServer runs Middleware behind auth.Middleware in front of a handler
+// that creates a numbered resource on every call. Calls fail with
+// failStatus while it is set, and wait for release while it isn't nil.
+type testServer struct {
+	handler http.Handler
+	db      *sql.DB
+	key     string
+
+	mu         sync.Mutex
+	calls      int
+	failStatus int
+	started    chan struct{}
+	release    chan struct{}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	repo, db := newTestRepository(t)
+	key, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateAPIKey(repository.CreateAPIKeyParams{Name: "test", Prefix: prefix, KeyHash: hash, Scope: string(auth.ScopeAdmin)}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{db: db, key: key}
+	create := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.calls++
+		n, failStatus, started, release := s.calls, s.failStatus, s.started, s.release
+		s.mu.Unlock()
+
+		if started != nil {
+			close(started)
+			<-release
+		}
+		if failStatus != 0 {
+			http.Error(w, "failed", failStatus)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/characters/%d", n))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":"%d"}`, n)
+	})
+	log := zerolog.Nop()
+	s.handler = auth.Middleware(repo, false, log)(Middleware(repo, log)(create))
+	return s
+}
+
+func (s *testServer) post(idempotencyKey string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/characters", strings.NewReader(body))
+	r.Header.Set("X-API-Key", s.key)
+	r.Header.Set(Header, idempotencyKey)
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+	return w
+}
+
+func (s *testServer) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestMiddlewareReplaysSameRequest(t *testing.T) {
+	s := newTestServer(t)
+
+	first := s.post("k1", `{"name":"Char"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request: status %d, want %d", first.Code, http.StatusCreated)
+	}
+	retry := s.post("k1", `{"name":"Char"}`)
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("retry: status %d, want %d", retry.Code, http.StatusCreated)
+	}
+	if n := s.callCount(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+	if got, want := retry.Body.String(), first.Body.String(); got != want {
+		t.Errorf("retry body %q, want %q", got, want)
+	}
+	if got, want := retry.Header().Get("Location"), first.Header().Get("Location"); got != want {
+		t.Errorf("retry Location %q, want %q", got, want)
+	}
+	if first.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("first response has %s set", ReplayedHeader)
+	}
+	if retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry doesn't have %s set", ReplayedHeader)
+	}
+
+	// Another key is another operation.
+	if w := s.post("k2", `{"name":"Char"}`); w.Code != http.StatusCreated || s.callCount() != 2 {
+		t.Errorf("new key: status %d after %d calls, want %d after 2", w.Code, s.callCount(), http.StatusCreated)
+	}
+}
+
+func TestMiddlewareRejectsReusedKey(t *testing.T) {
+	s := newTestServer(t)
+
+	s.post("k1", `{"name":"Char"}`)
+	w := s.post("k1", `{"name":"Other"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body: status %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if n := s.callCount(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestMiddlewareRejectsConcurrentRetry(t *testing.T) {
+	s := newTestServer(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.started, s.release = started, release
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- s.post("k1", `{"name":"Char"}`) }()
+	<-started
+
+	// Only the first request waits in the handler.
+	s.mu.Lock()
+	s.started, s.release = nil, nil
+	s.mu.Unlock()
+	if w := s.post("k1", `{"name":"Char"}`); w.Code != http.StatusConflict {
+		t.Errorf("retry while in flight: status %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Fatalf("first request: status %d, want %d", w.Code, http.StatusCreated)
+	}
+	if w := s.post("k1", `{"name":"Char"}`); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry once finished: status %d replayed %q, want %d replayed", w.Code, w.Header().Get(ReplayedHeader), http.StatusCreated)
+	}
+	if n := s.callCount(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestMiddlewareRetriesFailedRequest(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusInternalServerError} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			s := newTestServer(t)
+			s.failStatus = status
+
+			if w := s.post("k1", `{"name":"Char"}`); w.Code != status {
+				t.Fatalf("failing request: status %d, want %d", w.Code, status)
+			}
+			s.failStatus = 0
+			w := s.post("k1", `{"name":"Char"}`)
+			if w.Code != http.StatusCreated {
+				t.Errorf("retry: status %d, want %d", w.Code, http.StatusCreated)
+			}
+			if w.Header().Get(ReplayedHeader) != "" {
+				t.Errorf("retry of a failed request was replayed")
+			}
+			if n := s.callCount(); n != 2 {
+				t.Errorf("handler ran %d times, want 2", n)
+			}
+		})
+	}
+}
+
+func TestMiddlewareForgetsExpiredKeys(t *testing.T) {
+	s := newTestServer(t)
+
+	s.post("k1", `{"name":"Char"}`)
+	expired := time.Now().Add(-TTL - time.Minute).UTC().Format(time.RFC3339)
+	if _, err := s.db.Exec(`UPDATE idempotency_keys SET created_at = ?`, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// An expired key is free again, even for a different request.
+	w := s.post("k1", `{"name":"Other"}`)
+	if w.Code != http.StatusCreated {
+		t.Errorf("request after expiry: status %d, want %d", w.Code, http.StatusCreated)
+	}
+	if w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("request after expiry was replayed")
+	}
+	if n := s.callCount(); n != 2 {
+		t.Errorf("handler ran %d times, want 2", n)
+	}
+}
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS snapshot_stats (
+  snapshot_id                   TEXT PRIMARY KEY,
+  level                         INTEGER NOT NULL DEFAULT 0,
+  combined_dps                  REAL,
+  life                          REAL,
+  energy_shield                 REAL,
+  mana                          REAL,
+  total_ehp                     REAL,
+  physical_max_hit              REAL,
+  fire_max_hit                  REAL,
+  cold_max_hit                  REAL,
+  lightning_max_hit             REAL,
+  chaos_max_hit                 REAL,
+  fire_resist                   REAL,
+  cold_resist                   REAL,
+  lightning_resist              REAL,
+  chaos_resist                  REAL,
+  movement_speed                REAL,
+
+  created_at      TIMESTAMP NOT NULL,
+  FOREIGN KEY(snapshot_id) REFERENCES pobsnapshots(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS snapshot_stats;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS snapshot_data (
+  snapshot_id     TEXT PRIMARY KEY,
+  items_json      TEXT NOT NULL,
+  passives_json   TEXT NOT NULL,
+
+  created_at      TIMESTAMP NOT NULL,
+  FOREIGN KEY(snapshot_id) REFERENCES pobsnapshots(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS snapshot_data;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS leagues (
+  id                TEXT PRIMARY KEY,
+  name              TEXT NOT NULL,
+  realm             TEXT NOT NULL DEFAULT 'pc',
+  start_at          TIMESTAMP,
+  end_at            TIMESTAMP,
+  hardcore          BOOLEAN NOT NULL DEFAULT FALSE,
+  ssf               BOOLEAN NOT NULL DEFAULT FALSE,
+  parent_league_id  TEXT,
+
+  created_at        TIMESTAMP NOT NULL,
+  updated_at        TIMESTAMP NOT NULL,
+  UNIQUE(name, realm),
+  FOREIGN KEY(parent_league_id) REFERENCES leagues(id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE pobsnapshots ADD COLUMN league TEXT;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE pobsnapshots
+SET league = (
+  SELECT json_extract(d.items_json, '$.character.league')
+  FROM snapshot_data d
+  WHERE d.snapshot_id = pobsnapshots.id
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS leagues;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- SQLite does not support DROP COLUMN directly.
+SELECT 'down SQL query: cannot drop pobsnapshots.league in SQLite, manual intervention required if needed.';
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE accounts ADD COLUMN realm TEXT NOT NULL DEFAULT 'pc';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE characters ADD COLUMN realm TEXT NOT NULL DEFAULT 'pc';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- SQLite does not support DROP COLUMN directly.
+SELECT 'down SQL query: cannot drop realm columns in SQLite, manual intervention required if needed.';
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS ladder_entries (
+  id            TEXT PRIMARY KEY,
+  character_id  TEXT NOT NULL,
+  league        TEXT NOT NULL,
+  realm         TEXT NOT NULL DEFAULT 'pc',
+  rank          INTEGER NOT NULL,
+  level         INTEGER NOT NULL,
+  experience    INTEGER NOT NULL,
+  depth         INTEGER,
+  dead          BOOLEAN NOT NULL DEFAULT FALSE,
+
+  recorded_at   TIMESTAMP NOT NULL,
+  FOREIGN KEY(character_id) REFERENCES characters(id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_ladder_entries_character ON ladder_entries(character_id, recorded_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ladder_entries;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS experience_samples (
+  id            TEXT PRIMARY KEY,
+  character_id  TEXT NOT NULL,
+  league        TEXT,
+  level         INTEGER NOT NULL,
+  experience    INTEGER NOT NULL,
+
+  recorded_at   TIMESTAMP NOT NULL,
+  FOREIGN KEY(character_id) REFERENCES characters(id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_experience_samples_character ON experience_samples(character_id, recorded_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS experience_samples;
+-- +goose StatementEnd
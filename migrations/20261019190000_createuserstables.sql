@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS users (
+  id             TEXT PRIMARY KEY,
+  username       TEXT NOT NULL UNIQUE COLLATE NOCASE,
+  password_hash  TEXT NOT NULL,
+
+  created_at     TIMESTAMP NOT NULL,
+  updated_at     TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS sessions (
+  token_hash  TEXT PRIMARY KEY,
+  user_id     TEXT NOT NULL,
+
+  created_at  TIMESTAMP NOT NULL,
+  expires_at  TIMESTAMP NOT NULL,
+  FOREIGN KEY(user_id) REFERENCES users(id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS watchlist_entries (
+  id            TEXT PRIMARY KEY,
+  user_id       TEXT NOT NULL,
+  account_id    TEXT NOT NULL,
+  character_id  TEXT,
+
+  created_at    TIMESTAMP NOT NULL,
+  FOREIGN KEY(user_id) REFERENCES users(id),
+  FOREIGN KEY(account_id) REFERENCES accounts(id),
+  FOREIGN KEY(character_id) REFERENCES characters(id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_watchlist_entries_unique ON watchlist_entries(user_id, account_id, COALESCE(character_id, ''));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS watchlist_entries;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS sessions;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS users;
+-- +goose StatementEnd
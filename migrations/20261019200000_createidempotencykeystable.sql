@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  api_key_id     TEXT NOT NULL,
+  key            TEXT NOT NULL,
+  method         TEXT NOT NULL,
+  path           TEXT NOT NULL,
+  request_hash   TEXT NOT NULL,
+  status         INTEGER,
+  location       TEXT,
+  response_body  BLOB,
+
+  created_at     TIMESTAMP NOT NULL,
+  completed_at   TIMESTAMP,
+  PRIMARY KEY(api_key_id, key),
+  FOREIGN KEY(api_key_id) REFERENCES api_keys(id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
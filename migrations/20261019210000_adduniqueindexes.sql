@@ -0,0 +1,29 @@
+-- Existing duplicates have to be merged first, creating these indexes fails
+-- otherwise: migrate up to 20261019200000, run `exile-tracker dedupe`, then
+-- migrate up. `make upgrade` does all three.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_name_realm ON accounts(account_name, realm) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_characters_account_name ON characters(account_id, character_name) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_characters_to_fetch_character ON characters_to_fetch(character_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_characters_to_fetch_character;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_characters_account_name;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_accounts_name_realm;
+-- +goose StatementEnd
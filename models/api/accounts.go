@@ -3,9 +3,11 @@ package models
 type CreateAccountInput struct {
 	AccountName string  `json:"account_name"`
 	Player      *string `json:"player"`
+	Realm       string  `json:"realm"`
 }
 
 type UpdateAccountInput struct {
 	AccountName string  `json:"account_name"`
 	Player      *string `json:"player"`
+	Realm       string  `json:"realm"`
 }
@@ -2,14 +2,16 @@ package models
 
 type CreateCharacterInput struct {
 	AccountId     string `json:"account_id"`
-	CharacterName string `json:"CharacterName"`
+	CharacterName string `json:"character_name"`
 	Died          bool   `json:"died"`
 	CurrentLeague string `json:"current_league"`
+	Realm         string `json:"realm"`
 }
 
 type UpdateCharacterInput struct {
 	CharacterName string `json:"character_name"`
 	CurrentLeague string `json:"current_league"`
+	Realm         string `json:"realm"`
 }
 
 type AddCharacterToFetchInput struct {
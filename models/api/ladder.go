@@ -0,0 +1,9 @@
+package models
+
+type LadderImportInput struct {
+	League string `json:"league"`
+	Realm  string `json:"realm"`
+	Top    int    `json:"top"`
+	Class  string `json:"class"`
+	Skill  string `json:"skill"`
+}
@@ -0,0 +1,8 @@
+package models
+
+import "time"
+
+type UpdateLeagueInput struct {
+	StartAt *time.Time `json:"start_at"`
+	EndAt   *time.Time `json:"end_at"`
+}
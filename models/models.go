@@ -2,28 +2,44 @@ package models
 
 import "time"
 
-type SnapshotWithExtras struct {
-	SnapshotData  POBSnapshot
-	CharacterName string
-	AccountName   string
-}
-
 type Account struct {
 	ID          string  `json:"id"`
 	AccountName string  `json:"account_name"`
 	Player      *string `json:"player"`
+	Realm       string  `json:"realm"`
 
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
 }
 
+type User struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WatchlistEntry is an account, or one character of it, a user follows.
+type WatchlistEntry struct {
+	ID            string    `json:"id"`
+	UserId        string    `json:"user_id"`
+	AccountId     string    `json:"account_id"`
+	AccountName   string    `json:"account_name"`
+	CharacterId   *string   `json:"character_id"`
+	CharacterName *string   `json:"character_name"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
 type Character struct {
 	ID            string  `json:"id"`
 	AccountId     string  `json:"account_id"`
-	CharacterName string  `json:"CharacterName"`
+	CharacterName string  `json:"character_name"`
 	Died          bool    `json:"died"`
 	CurrentLeague *string `json:"current_league"`
+	Realm         string  `json:"realm"`
 
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
@@ -31,18 +47,129 @@ type Character struct {
 }
 
 type POBSnapshot struct {
-	ID           string `json:"id"`
-	CharacterId  string `json:"character_id"`
-	ExportString string `json:"export_string"`
+	ID           string  `json:"id"`
+	CharacterId  string  `json:"character_id"`
+	ExportString string  `json:"export_string"`
+	League       *string `json:"league"`
 
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
 }
 
+type League struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Realm          string     `json:"realm"`
+	StartAt        *time.Time `json:"start_at"`
+	EndAt          *time.Time `json:"end_at"`
+	Hardcore       bool       `json:"hardcore"`
+	SSF            bool       `json:"ssf"`
+	ParentLeagueId *string    `json:"parent_league_id"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type LadderEntry struct {
+	ID          string    `json:"id"`
+	CharacterId string    `json:"character_id"`
+	League      string    `json:"league"`
+	Realm       string    `json:"realm"`
+	Rank        int       `json:"rank"`
+	Level       int       `json:"level"`
+	Experience  int64     `json:"experience"`
+	Depth       *int      `json:"depth"`
+	Dead        bool      `json:"dead"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// ExperienceSample is the level and experience of a character at one fetch.
+type ExperienceSample struct {
+	ID          string    `json:"id"`
+	CharacterId string    `json:"character_id"`
+	League      *string   `json:"league"`
+	Level       int       `json:"level"`
+	Experience  int64     `json:"experience"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// APIKey is a stored API key. Only the hash of the key is kept, Prefix is
+// shown to tell keys apart.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// IdempotencyRecord is the outcome of a POST sent with an Idempotency-Key,
+// replayed when the same request is retried. Status is nil while the first
+// request is still running.
+type IdempotencyRecord struct {
+	APIKeyId     string
+	Key          string
+	Method       string
+	Path         string
+	RequestHash  string
+	Status       *int
+	Location     *string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+}
+
 type CharactersToFetch struct {
 	Id          string     `json:"id"`
 	CharacterId string     `json:"character_id"`
 	LastFetch   *time.Time `json:"last_fetch"`
 	ShouldSkip  bool       `json:"should_skip"`
 }
+
+type SnapshotStats struct {
+	SnapshotId      string   `json:"snapshot_id"`
+	Level           int      `json:"level"`
+	CombinedDPS     *float64 `json:"combined_dps"`
+	Life            *float64 `json:"life"`
+	EnergyShield    *float64 `json:"energy_shield"`
+	Mana            *float64 `json:"mana"`
+	TotalEHP        *float64 `json:"total_ehp"`
+	PhysicalMaxHit  *float64 `json:"physical_max_hit"`
+	FireMaxHit      *float64 `json:"fire_max_hit"`
+	ColdMaxHit      *float64 `json:"cold_max_hit"`
+	LightningMaxHit *float64 `json:"lightning_max_hit"`
+	ChaosMaxHit     *float64 `json:"chaos_max_hit"`
+	FireResist      *float64 `json:"fire_resist"`
+	ColdResist      *float64 `json:"cold_resist"`
+	LightningResist *float64 `json:"lightning_resist"`
+	ChaosResist     *float64 `json:"chaos_resist"`
+	MovementSpeed   *float64 `json:"movement_speed"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SnapshotData is the raw character data from the PoE API a snapshot was built from.
+type SnapshotData struct {
+	SnapshotId  string                `json:"snapshot_id"`
+	CharacterId string                `json:"character_id"`
+	Items       ItemsResponse         `json:"items"`
+	Passives    PassiveSkillsResponse `json:"passives"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RosterEntry is one line of the list of tracked players: an account, and
+// optionally one of its characters with how it is fetched. Tracked and
+// Paused are only set on lines with a character.
+type RosterEntry struct {
+	AccountName   string `json:"account_name"`
+	Realm         string `json:"realm"`
+	Player        string `json:"player,omitempty"`
+	CharacterName string `json:"character_name,omitempty"`
+	League        string `json:"league,omitempty"`
+	Tracked       *bool  `json:"tracked,omitempty"`
+	Paused        *bool  `json:"paused,omitempty"`
+}
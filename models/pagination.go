@@ -0,0 +1,25 @@
+package models
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// PageParams selects one page of a list. Cursor is the NextCursor of the
+// previous page, empty for the first one, and is only valid with the same
+// Sort and Desc it was returned with.
+type PageParams struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Desc   bool
+}
+
+// Page is the envelope list endpoints answer with. Total counts every row
+// matching the filters, not only the ones in Items. NextCursor is empty on
+// the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
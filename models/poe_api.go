@@ -1,12 +1,13 @@
 package models
 
 type POECharacterResponse struct {
-	Name     string `json:"name"`
-	Realm    string `json:"realm"`
-	Class    string `json:"class"`
-	League   string `json:"league"`
-	Level    int    `json:"level"`
-	Pinnable bool   `json:"pinnable"`
+	Name       string `json:"name"`
+	Realm      string `json:"realm"`
+	Class      string `json:"class"`
+	League     string `json:"league"`
+	Level      int    `json:"level"`
+	Experience int64  `json:"experience"`
+	Pinnable   bool   `json:"pinnable"`
 }
 
 // PassiveSkillsResponse represents the response from the get-passive-skills endpoint
@@ -51,6 +52,7 @@ type PassiveItem struct {
 	FracturedMods []string       `json:"fracturedMods,omitempty"`
 	Requirements  []ItemProperty `json:"requirements,omitempty"`
 	EnchantMods   []string       `json:"enchantMods,omitempty"`
+	CraftedMods   []string       `json:"craftedMods,omitempty"`
 }
 
 // ItemProperty represents a property of an item
@@ -116,11 +118,12 @@ type ExpansionJewel struct {
 type ItemsResponse struct {
 	Items     []Item `json:"items"`
 	Character struct {
-		Name   string `json:"name"`
-		Realm  string `json:"realm"`
-		Class  string `json:"class"`
-		League string `json:"league"`
-		Level  int    `json:"level"`
+		Name       string `json:"name"`
+		Realm      string `json:"realm"`
+		Class      string `json:"class"`
+		League     string `json:"league"`
+		Level      int    `json:"level"`
+		Experience int64  `json:"experience"`
 	} `json:"character"`
 }
 
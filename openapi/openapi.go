@@ -0,0 +1,150 @@
+// Package openapi holds the OpenAPI document of the REST API and validates
+// request bodies against it.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+// Schema is the part of an OpenAPI schema object the validator understands,
+// which is everything openapi.json uses.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []any              `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+}
+
+type operation struct {
+	OperationID string `json:"operationId"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type document struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// route is an operation with a request body, segments being its path split
+// on "/" with parameters left as "{name}". others are the media types it
+// accepts besides JSON, which aren't validated.
+type route struct {
+	method   string
+	segments []string
+	schema   *Schema
+	others   []string
+}
+
+// Validator checks request bodies against the operations of the document.
+type Validator struct {
+	schemas map[string]*Schema
+	routes  []route
+}
+
+// NewValidator parses Spec. It only fails if openapi.json is broken.
+func NewValidator() (*Validator, error) {
+	v, err := parseValidator(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("parse openapi.json: %w", err)
+	}
+	return v, nil
+}
+
+func parseValidator(spec []byte) (*Validator, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, err
+	}
+
+	v := &Validator{schemas: doc.Components.Schemas}
+	for path, item := range doc.Paths {
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("parse %s %s: %w", method, path, err)
+			}
+			if op.RequestBody == nil {
+				continue
+			}
+			content, ok := op.RequestBody.Content["application/json"]
+			if !ok || content.Schema == nil {
+				continue
+			}
+			rt := route{
+				method:   strings.ToUpper(method),
+				segments: strings.Split(strings.Trim(path, "/"), "/"),
+				schema:   content.Schema,
+			}
+			for mediaType := range op.RequestBody.Content {
+				if mediaType != "application/json" {
+					rt.others = append(rt.others, mediaType)
+				}
+			}
+			v.routes = append(v.routes, rt)
+		}
+	}
+	return v, nil
+}
+
+// Handler serves the document.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
+
+// find returns the operation matching a request path, relative to the
+// server URL. Literal segments win over parameters, so /characters/to-fetch
+// isn't taken for /characters/{id}.
+func (v *Validator) find(method string, path string) *route {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var best *route
+	bestLiterals := -1
+	for i := range v.routes {
+		rt := &v.routes[i]
+		if rt.method != method || len(rt.segments) != len(segments) {
+			continue
+		}
+		literals := 0
+		matched := true
+		for i, s := range rt.segments {
+			if strings.HasPrefix(s, "{") {
+				continue
+			}
+			if s != segments[i] {
+				matched = false
+				break
+			}
+			literals++
+		}
+		if matched && literals > bestLiterals {
+			best, bestLiterals = rt, literals
+		}
+	}
+	return best
+}
@@ -0,0 +1,235 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/google/uuid"
+)
+
+// maxBodySize caps the request bodies read for validation.
+const maxBodySize = 1 << 20
+
+// Middleware rejects requests whose JSON body doesn't match the schema of
+// their operation with 400 and every mismatch found. prefix is stripped
+// from the request path before looking the operation up. Requests to
+// operations without a body schema, or sending one of the other media types
+// the operation accepts, go through untouched.
+func (v *Validator) Middleware(prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rt := v.find(r.Method, strings.TrimPrefix(r.URL.Path, prefix))
+			if rt == nil || rt.accepts(r.Header.Get("Content-Type")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+				if err != nil {
+					apierror.Write(w, r, apierror.BadRequest(fmt.Errorf("couldn't read request body")))
+					return
+				}
+				if len(body) > maxBodySize {
+					apierror.Write(w, r, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "request body is larger than %d bytes", maxBodySize))
+					return
+				}
+			}
+
+			if errs := v.Validate(rt.schema, body); len(errs) > 0 {
+				apierror.Write(w, r, apierror.ValidationFailed(errs))
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// accepts reports whether a request of contentType is one of the non-JSON
+// bodies of the operation, handled by the route itself.
+func (rt *route) accepts(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, other := range rt.others {
+		if other == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a JSON document against a schema.
+func (v *Validator) Validate(schema *Schema, body []byte) []apierror.FieldError {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []apierror.FieldError{{Message: "request body is empty"}}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []apierror.FieldError{{Message: "request body is not valid JSON"}}
+	}
+	var errs []apierror.FieldError
+	v.validate(schema, value, "", &errs)
+	return errs
+}
+
+func (v *Validator) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func (v *Validator) validate(schema *Schema, value any, field string, errs *[]apierror.FieldError) {
+	s := v.resolve(schema)
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, apierror.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !s.Nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, apierror.FieldError{Field: join(field, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errs = append(*errs, apierror.FieldError{Field: join(field, name), Message: "is not a known field"})
+				}
+				continue
+			}
+			v.validate(prop, obj[name], join(field, name), errs)
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range arr {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail("must not be empty")
+			} else {
+				fail("must be at least %d characters", *s.MinLength)
+			}
+			return
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+			return
+		}
+		switch s.Format {
+		case "uuid":
+			if _, err := uuid.Parse(str); err != nil {
+				fail("must be a UUID")
+				return
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				fail("must be an RFC3339 time")
+				return
+			}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	case "integer", "number":
+		kind := "a number"
+		if s.Type == "integer" {
+			kind = "an integer"
+		}
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("must be %s", kind)
+			return
+		}
+		f, err := num.Float64()
+		if err != nil || (s.Type == "integer" && strings.ContainsAny(num.String(), ".eE")) {
+			fail("must be %s", kind)
+			return
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+			return
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = fmt.Sprintf("%q", fmt.Sprint(e))
+		}
+		fail("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func join(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,197 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+)
+
+const testSpec = `{
+	"paths": {
+		"/things/{id}": {
+			"parameters": [{"name": "id", "in": "path"}],
+			"get": {},
+			"put": {"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Thing"}}}}}
+		},
+		"/things/special": {
+			"put": {"requestBody": {"content": {"application/json": {"schema": {
+				"type": "object",
+				"properties": {"flag": {"type": "boolean"}},
+				"additionalProperties": false
+			}}}}}
+		},
+		"/imports": {
+			"post": {"requestBody": {"content": {
+				"application/json": {"schema": {"$ref": "#/components/schemas/Open"}},
+				"text/csv": {"schema": {"type": "string"}}
+			}}}
+		}
+	},
+	"components": {"schemas": {
+		"Thing": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "minLength": 1, "maxLength": 5},
+				"id": {"type": "string", "format": "uuid"},
+				"at": {"type": "string", "format": "date-time"},
+				"count": {"type": "integer", "minimum": 1, "maximum": 10},
+				"note": {"type": "string", "nullable": true},
+				"tags": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}}
+			},
+			"additionalProperties": false
+		},
+		"Tag": {"$ref": "#/components/schemas/Label"},
+		"Label": {"type": "string", "enum": ["red", "blue"]},
+		"Open": {
+			"type": "object",
+			"properties": {"thing": {"$ref": "#/components/schemas/Thing"}}
+		}
+	}}
+}`
+
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+	v, err := parseValidator([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSpecParses(t *testing.T) {
+	v, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.routes) == 0 {
+		t.Error("openapi.json has no operation with a request body")
+	}
+	for name, s := range v.schemas {
+		if v.resolve(s) == nil {
+			t.Errorf("schema %s points to a missing schema", name)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	v := newTestValidator(t)
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"PUT", "/things/special", "things/special"},
+		{"PUT", "/things/42", "things/{id}"},
+		{"PUT", "/things/42/", "things/{id}"},
+		{"GET", "/things/42", ""},
+		{"PUT", "/things/42/more", ""},
+		{"PUT", "/other/42", ""},
+		{"POST", "/imports", "imports"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			var got string
+			if rt := v.find(tt.method, tt.path); rt != nil {
+				got = strings.Join(rt.segments, "/")
+			}
+			if got != tt.want {
+				t.Errorf("find() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v := newTestValidator(t)
+	ref := func(name string) *Schema { return &Schema{Ref: "#/components/schemas/" + name} }
+
+	tests := []struct {
+		name   string
+		schema *Schema
+		body   string
+		want   []apierror.FieldError
+	}{
+		{"valid", ref("Thing"), `{"name": "mage", "count": 3, "note": null, "tags": ["red"]}`, nil},
+		{"empty body", ref("Thing"), ` `, []apierror.FieldError{{Message: "request body is empty"}}},
+		{"broken JSON", ref("Thing"), `{"name":`, []apierror.FieldError{{Message: "request body is not valid JSON"}}},
+		{"not an object", ref("Thing"), `[]`, []apierror.FieldError{{Message: "must be an object"}}},
+		{"required", ref("Thing"), `{}`, []apierror.FieldError{{Field: "name", Message: "is required"}}},
+		{"unknown field", ref("Thing"), `{"name": "mage", "level": 1}`, []apierror.FieldError{{Field: "level", Message: "is not a known field"}}},
+		{"additional properties allowed", ref("Open"), `{"level": 1}`, nil},
+		{"nested ref", ref("Open"), `{"thing": {"name": ""}}`, []apierror.FieldError{{Field: "thing.name", Message: "must not be empty"}}},
+		{"chained ref in array", ref("Thing"), `{"name": "mage", "tags": ["red", "green", 1]}`, []apierror.FieldError{
+			{Field: "tags[1]", Message: `must be one of "red", "blue"`},
+			{Field: "tags[2]", Message: "must be a string"},
+		}},
+		{"not nullable", ref("Thing"), `{"name": null}`, []apierror.FieldError{{Field: "name", Message: "must not be null"}}},
+		{"too long", ref("Thing"), `{"name": "sorceress"}`, []apierror.FieldError{{Field: "name", Message: "must be at most 5 characters"}}},
+		{"formats", ref("Thing"), `{"name": "mage", "id": "42", "at": "yesterday"}`, []apierror.FieldError{
+			{Field: "at", Message: "must be an RFC3339 time"},
+			{Field: "id", Message: "must be a UUID"},
+		}},
+		{"integer", ref("Thing"), `{"name": "mage", "count": 1.5}`, []apierror.FieldError{{Field: "count", Message: "must be an integer"}}},
+		{"not a number", ref("Thing"), `{"name": "mage", "count": "3"}`, []apierror.FieldError{{Field: "count", Message: "must be an integer"}}},
+		{"bounds", ref("Thing"), `{"name": "mage", "count": 11}`, []apierror.FieldError{{Field: "count", Message: "must be at most 10"}}},
+		{"missing schema ignored", ref("Missing"), `{"anything": true}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.Validate(tt.schema, []byte(tt.body))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	v := newTestValidator(t)
+	var received string
+	handler := v.Middleware("/api/v1")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"valid body reaches the handler", "PUT", "/api/v1/things/42", "application/json", `{"name": "mage"}`, http.StatusNoContent},
+		{"invalid body", "PUT", "/api/v1/things/42", "application/json", `{"name": ""}`, http.StatusBadRequest},
+		{"literal route wins", "PUT", "/api/v1/things/special", "application/json", `{"name": "mage"}`, http.StatusBadRequest},
+		{"operation without body schema", "GET", "/api/v1/things/42", "", `not json`, http.StatusNoContent},
+		{"other media type bypasses", "POST", "/api/v1/imports", "text/csv", `name\nmage`, http.StatusNoContent},
+		{"media type parameters", "POST", "/api/v1/imports", "text/csv; charset=utf-8", `name\nmage`, http.StatusNoContent},
+		{"unlisted media type is validated", "POST", "/api/v1/imports", "text/plain", `name\nmage`, http.StatusBadRequest},
+		{"missing content type is validated", "POST", "/api/v1/imports", "", `name\nmage`, http.StatusBadRequest},
+		{"too large", "POST", "/api/v1/imports", "application/json", `"` + strings.Repeat("x", maxBodySize) + `"`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code == http.StatusNoContent && received != tt.body {
+				t.Errorf("handler read %q, want the body %q", received, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package passivetree
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+type NodeState string
+
+const (
+	StateUnallocated NodeState = "unallocated"
+	StateAllocated   NodeState = "allocated"
+	StateAdded       NodeState = "added"
+	StateRemoved     NodeState = "removed"
+)
+
+type DrawNode struct {
+	Hash   int
+	Name   string
+	Type   NodeType
+	X      float64
+	Y      float64
+	Radius float64
+	State  NodeState
+}
+
+type DrawEdge struct {
+	X1    float64
+	Y1    float64
+	X2    float64
+	Y2    float64
+	State NodeState
+}
+
+// Drawing is a tree laid out for rendering, in tree coordinates.
+type Drawing struct {
+	MinX   float64
+	MinY   float64
+	Width  float64
+	Height float64
+	Nodes  []DrawNode
+	Edges  []DrawEdge
+}
+
+// Draw lays the tree out with the allocated hashes highlighted. When
+// previous is not nil the drawing is a diff: nodes only in allocated are
+// marked added and nodes only in previous are marked removed. Ascendancy
+// nodes are left out since they live outside the main tree.
+func (t *Tree) Draw(allocated map[int]bool, previous map[int]bool) Drawing {
+	stateOf := func(hash int) NodeState {
+		now := allocated[hash]
+		if previous == nil {
+			if now {
+				return StateAllocated
+			}
+			return StateUnallocated
+		}
+		before := previous[hash]
+		switch {
+		case now && before:
+			return StateAllocated
+		case now:
+			return StateAdded
+		case before:
+			return StateRemoved
+		}
+		return StateUnallocated
+	}
+
+	var d Drawing
+	positions := make(map[string][2]float64, len(t.Nodes))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+
+	keys := make([]string, 0, len(t.Nodes))
+	for key := range t.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		n := t.Nodes[key]
+		if n.AscendancyName != "" {
+			continue
+		}
+		if g, ok := t.Groups[string(n.Group)]; ok && g.IsProxy {
+			continue
+		}
+		x, y, ok := t.Position(n)
+		if !ok {
+			continue
+		}
+		hash := n.Skill
+		if hash == 0 {
+			hash, _ = strconv.Atoi(key)
+		}
+		positions[key] = [2]float64{x, y}
+		d.Nodes = append(d.Nodes, DrawNode{
+			Hash:   hash,
+			Name:   n.Name,
+			Type:   n.Type(),
+			X:      x,
+			Y:      y,
+			Radius: nodeRadius(n.Type()),
+			State:  stateOf(hash),
+		})
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+
+	for _, key := range keys {
+		from, ok := positions[key]
+		if !ok {
+			continue
+		}
+		n := t.Nodes[key]
+		if n.IsMastery {
+			continue
+		}
+		for _, out := range n.Out {
+			to, ok := positions[out]
+			if !ok || t.Nodes[out].IsMastery {
+				continue
+			}
+			outHash, _ := strconv.Atoi(out)
+			d.Edges = append(d.Edges, DrawEdge{
+				X1:    from[0],
+				Y1:    from[1],
+				X2:    to[0],
+				Y2:    to[1],
+				State: edgeState(stateOf(n.Skill), stateOf(outHash)),
+			})
+		}
+	}
+
+	if len(d.Nodes) == 0 {
+		return d
+	}
+	const margin = 200
+	d.MinX, d.MinY = minX-margin, minY-margin
+	d.Width, d.Height = maxX-minX+2*margin, maxY-minY+2*margin
+	return d
+}
+
+// edgeState colors a connection by the state of both of its ends.
+func edgeState(a, b NodeState) NodeState {
+	switch {
+	case a == StateUnallocated || b == StateUnallocated:
+		return StateUnallocated
+	case a == StateRemoved || b == StateRemoved:
+		if a == StateAdded || b == StateAdded {
+			return StateUnallocated
+		}
+		return StateRemoved
+	case a == StateAdded || b == StateAdded:
+		return StateAdded
+	}
+	return StateAllocated
+}
+
+func nodeRadius(t NodeType) float64 {
+	switch t {
+	case NodeKeystone:
+		return 60
+	case NodeNotable:
+		return 45
+	case NodeMastery, NodeJewelSocket:
+		return 40
+	}
+	return 28
+}
@@ -0,0 +1,111 @@
+package passivetree
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/rs/zerolog"
+)
+
+// versionsFile maps leagues to tree versions inside the tree directory:
+//
+//	{"default": "3.26", "leagues": {"Settlers": "3.25"}}
+type versionsFile struct {
+	Default string            `json:"default"`
+	Leagues map[string]string `json:"leagues"`
+}
+
+// Registry gives access to every bundled tree version. The directory holds
+// one folder per version with GGG's data.json in it, plus an optional
+// versions.json mapping league names to versions. Trees are loaded the first
+// time they are needed.
+type Registry struct {
+	dir      string
+	versions versionsFile
+	log      zerolog.Logger
+
+	mu    sync.Mutex
+	trees map[string]*Tree
+}
+
+func NewRegistry(dir string) *Registry {
+	r := &Registry{
+		dir:   dir,
+		log:   utils.ChildLogger("passivetree"),
+		trees: make(map[string]*Tree),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "versions.json"))
+	if err == nil {
+		if err := json.Unmarshal(data, &r.versions); err != nil {
+			r.log.Warn().Err(err).Msg("Invalid versions.json, ignoring it")
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		r.log.Warn().Err(err).Msg("Unable to read versions.json")
+	}
+
+	if r.versions.Default == "" {
+		versions := r.Versions()
+		if len(versions) > 0 {
+			r.versions.Default = versions[len(versions)-1]
+		}
+	}
+	return r
+}
+
+// Versions lists the tree versions available on disk, sorted.
+func (r *Registry) Versions() []string {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil
+	}
+	var versions []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(r.dir, e.Name(), "data.json")); err == nil {
+			versions = append(versions, e.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// ForLeague returns the tree used by a league, falling back to the default
+// version and then to an empty tree. It never returns nil.
+func (r *Registry) ForLeague(league string) *Tree {
+	if v, ok := r.versions.Leagues[league]; ok {
+		return r.Version(v)
+	}
+	return r.Version(r.versions.Default)
+}
+
+// Version returns a specific tree version, or an empty tree when it can't be
+// loaded.
+func (r *Registry) Version(version string) *Tree {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.trees[version]; ok {
+		return t
+	}
+
+	t := Empty()
+	if version != "" {
+		loaded, err := Load(filepath.Join(r.dir, version, "data.json"))
+		if err != nil {
+			r.log.Warn().Err(err).Str("version", version).Msg("Passive tree not available, node names won't be resolved")
+		} else {
+			t = loaded
+		}
+	}
+	t.Version = version
+	r.trees[version] = t
+	return t
+}
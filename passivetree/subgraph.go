@@ -0,0 +1,81 @@
+package passivetree
+
+import (
+	"strconv"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// WithSubgraphs returns a tree that also contains the nodes and groups of
+// the cluster jewel subgraphs socketed by a character. The original tree is
+// not modified.
+func (t *Tree) WithSubgraphs(jewels map[string]models.JewelData) *Tree {
+	hasSubgraph := false
+	for _, j := range jewels {
+		if j.Subgraph != nil {
+			hasSubgraph = true
+			break
+		}
+	}
+	if !hasSubgraph {
+		return t
+	}
+
+	merged := *t
+	merged.Nodes = make(map[string]Node, len(t.Nodes))
+	for k, v := range t.Nodes {
+		merged.Nodes[k] = v
+	}
+	merged.Groups = make(map[string]Group, len(t.Groups))
+	for k, v := range t.Groups {
+		merged.Groups[k] = v
+	}
+
+	for _, j := range jewels {
+		if j.Subgraph == nil {
+			continue
+		}
+		for key, g := range j.Subgraph.Groups {
+			merged.Groups[key] = Group{
+				X:      g.X,
+				Y:      g.Y,
+				Orbits: g.Orbits,
+				Nodes:  g.Nodes,
+			}
+		}
+		for key, n := range j.Subgraph.Nodes {
+			skill, _ := strconv.Atoi(n.Skill)
+			if skill == 0 {
+				skill, _ = strconv.Atoi(key)
+			}
+			merged.Nodes[key] = Node{
+				Skill:         skill,
+				Name:          n.Name,
+				Icon:          n.Icon,
+				Stats:         n.Stats,
+				IsNotable:     n.IsNotable,
+				IsMastery:     n.IsMastery,
+				IsJewelSocket: n.IsJewelSocket,
+				Group:         GroupKey(n.Group),
+				Orbit:         n.Orbit,
+				OrbitIndex:    n.OrbitIndex,
+				Out:           n.Out,
+				In:            n.In,
+			}
+		}
+	}
+	return &merged
+}
+
+// AllocatedHashes is the set of nodes allocated by a character, cluster
+// jewel nodes included.
+func AllocatedHashes(p models.PassiveSkillsResponse) map[int]bool {
+	hashes := make(map[int]bool, len(p.Hashes)+len(p.HashesEx))
+	for _, h := range p.Hashes {
+		hashes[h] = true
+	}
+	for _, h := range p.HashesEx {
+		hashes[h] = true
+	}
+	return hashes
+}
@@ -0,0 +1,228 @@
+package passivetree
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+type NodeType string
+
+const (
+	NodeNormal      NodeType = "normal"
+	NodeNotable     NodeType = "notable"
+	NodeKeystone    NodeType = "keystone"
+	NodeMastery     NodeType = "mastery"
+	NodeJewelSocket NodeType = "jewel_socket"
+	NodeAscendancy  NodeType = "ascendancy"
+)
+
+type MasteryEffect struct {
+	Effect int      `json:"effect"`
+	Stats  []string `json:"stats"`
+}
+
+// GroupKey is a group id. The tree export uses numbers while cluster jewel
+// subgraphs use strings such as "expansion_12345".
+type GroupKey string
+
+func (g *GroupKey) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*g = GroupKey(n.String())
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*g = GroupKey(s)
+	return nil
+}
+
+// Node is a passive skill as described in GGG's skill tree export.
+type Node struct {
+	Skill          int             `json:"skill"`
+	Name           string          `json:"name"`
+	Icon           string          `json:"icon"`
+	Stats          []string        `json:"stats"`
+	IsNotable      bool            `json:"isNotable"`
+	IsKeystone     bool            `json:"isKeystone"`
+	IsMastery      bool            `json:"isMastery"`
+	IsJewelSocket  bool            `json:"isJewelSocket"`
+	AscendancyName string          `json:"ascendancyName"`
+	MasteryEffects []MasteryEffect `json:"masteryEffects"`
+	Group          GroupKey        `json:"group"`
+	Orbit          int             `json:"orbit"`
+	OrbitIndex     int             `json:"orbitIndex"`
+	Out            []string        `json:"out"`
+	In             []string        `json:"in"`
+}
+
+func (n Node) Type() NodeType {
+	switch {
+	case n.AscendancyName != "":
+		return NodeAscendancy
+	case n.IsKeystone:
+		return NodeKeystone
+	case n.IsNotable:
+		return NodeNotable
+	case n.IsMastery:
+		return NodeMastery
+	case n.IsJewelSocket:
+		return NodeJewelSocket
+	}
+	return NodeNormal
+}
+
+type Group struct {
+	X       float64  `json:"x"`
+	Y       float64  `json:"y"`
+	Orbits  []int    `json:"orbits"`
+	Nodes   []string `json:"nodes"`
+	IsProxy bool     `json:"isProxy"`
+}
+
+type Constants struct {
+	SkillsPerOrbit []int     `json:"skillsPerOrbit"`
+	OrbitRadii     []float64 `json:"orbitRadii"`
+}
+
+// Tree is a loaded skill tree export.
+type Tree struct {
+	Version    string           `json:"-"`
+	Nodes      map[string]Node  `json:"nodes"`
+	Groups     map[string]Group `json:"groups"`
+	JewelSlots []int            `json:"jewelSlots"`
+	Constants  Constants        `json:"constants"`
+	MinX       float64          `json:"min_x"`
+	MinY       float64          `json:"min_y"`
+	MaxX       float64          `json:"max_x"`
+	MaxY       float64          `json:"max_y"`
+}
+
+// ResolvedNode is everything known about an allocated hash.
+type ResolvedNode struct {
+	Hash  int      `json:"hash"`
+	Name  string   `json:"name"`
+	Stats []string `json:"stats"`
+	Type  NodeType `json:"type"`
+	X     float64  `json:"x"`
+	Y     float64  `json:"y"`
+	Known bool     `json:"known"`
+}
+
+// Load reads a skill tree export (data.json from GGG's skilltree-export
+// repository).
+func Load(path string) (*Tree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read passive tree: %w", err)
+	}
+
+	var t Tree
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("unable to parse passive tree: %w", err)
+	}
+	if t.Groups == nil {
+		t.Groups = map[string]Group{}
+	}
+	return &t, nil
+}
+
+// Empty returns a tree without nodes, used when no tree data is available.
+func Empty() *Tree {
+	return &Tree{Nodes: map[string]Node{}, Groups: map[string]Group{}}
+}
+
+func (t *Tree) Node(hash int) (Node, bool) {
+	n, ok := t.Nodes[strconv.Itoa(hash)]
+	return n, ok
+}
+
+// NodeName resolves a hash to its node name, falling back to the hash itself.
+func (t *Tree) NodeName(hash int) string {
+	if n, ok := t.Node(hash); ok && n.Name != "" {
+		return n.Name
+	}
+	return fmt.Sprintf("Unknown node %d", hash)
+}
+
+func (t *Tree) Resolve(hash int) ResolvedNode {
+	n, ok := t.Node(hash)
+	if !ok {
+		return ResolvedNode{Hash: hash, Name: t.NodeName(hash), Type: NodeNormal}
+	}
+	x, y, _ := t.Position(n)
+	return ResolvedNode{
+		Hash:  hash,
+		Name:  n.Name,
+		Stats: n.Stats,
+		Type:  n.Type(),
+		X:     x,
+		Y:     y,
+		Known: true,
+	}
+}
+
+// JewelSlot returns the hash of the jewel socket node with the given index,
+// as used by the x position of jewels in the passive skills response.
+func (t *Tree) JewelSlot(index int) (int, bool) {
+	if index < 0 || index >= len(t.JewelSlots) {
+		return 0, false
+	}
+	return t.JewelSlots[index], true
+}
+
+// MasteryEffectText returns the stat text of a mastery effect chosen on the
+// given mastery node.
+func (t *Tree) MasteryEffectText(masteryHash int, effect int) []string {
+	n, ok := t.Node(masteryHash)
+	if !ok {
+		return nil
+	}
+	for _, e := range n.MasteryEffects {
+		if e.Effect == effect {
+			return e.Stats
+		}
+	}
+	return nil
+}
+
+// Position computes the coordinates of a node from its group and orbit, the
+// same way the game and Path of Building lay the tree out.
+func (t *Tree) Position(n Node) (float64, float64, bool) {
+	g, ok := t.Groups[string(n.Group)]
+	if !ok {
+		return 0, 0, false
+	}
+	if n.Orbit >= len(t.Constants.OrbitRadii) || n.Orbit >= len(t.Constants.SkillsPerOrbit) {
+		return g.X, g.Y, true
+	}
+
+	radius := t.Constants.OrbitRadii[n.Orbit]
+	angle := orbitAngle(t.Constants.SkillsPerOrbit[n.Orbit], n.OrbitIndex)
+	return g.X + math.Sin(angle)*radius, g.Y - math.Cos(angle)*radius, true
+}
+
+// Orbits with 16 and 40 slots are not evenly spaced since 3.17, they snap to
+// multiples of 30/45 and 10/45 degrees.
+var (
+	orbit16Angles = []float64{0, 30, 45, 60, 90, 120, 135, 150, 180, 210, 225, 240, 270, 300, 315, 330}
+	orbit40Angles = []float64{0, 10, 20, 30, 40, 45, 50, 60, 70, 80, 90, 100, 110, 120, 130, 135, 140, 150, 160, 170, 180, 190, 200, 210, 220, 225, 230, 240, 250, 260, 270, 280, 290, 300, 310, 315, 320, 330, 340, 350}
+)
+
+func orbitAngle(skillsInOrbit int, index int) float64 {
+	var degrees float64
+	switch {
+	case skillsInOrbit == 16 && index < len(orbit16Angles):
+		degrees = orbit16Angles[index]
+	case skillsInOrbit == 40 && index < len(orbit40Angles):
+		degrees = orbit40Angles[index]
+	case skillsInOrbit > 0:
+		degrees = 360 * float64(index) / float64(skillsInOrbit)
+	}
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,296 @@
+package pob
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/rs/zerolog"
+)
+
+//go:embed worker.lua
+var workerScript []byte
+
+var ErrPoolClosed = errors.New("pob pool is closed")
+
+// Job is the character data sent to a worker, as returned by the PoE API.
+type Job struct {
+	Items    any
+	Passives any
+}
+
+// Result is what a worker answers with after importing a character.
+type Result struct {
+	Code  string
+	Stats map[string]float64
+}
+
+// JobError is returned when PoB itself failed to process a job. The worker
+// that reported it is still healthy.
+type JobError struct {
+	Message string
+}
+
+func (e *JobError) Error() string {
+	return "pob failed to process build: " + e.Message
+}
+
+type PoolConfig struct {
+	Size                int
+	MaxJobsPerWorker    int
+	LuaJITPath          string
+	POBRoot             string
+	StartTimeout        time.Duration
+	JobTimeout          time.Duration
+	HealthCheckInterval time.Duration
+}
+
+// Pool keeps a set of HeadlessWrapper processes alive so PoB data only has
+// to be loaded once per worker instead of once per character.
+type Pool struct {
+	cfg    PoolConfig
+	script string
+	idle   chan *worker
+	log    zerolog.Logger
+
+	mu     sync.Mutex
+	nextID int
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.Size <= 0 {
+		cfg.Size = 1
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = 2 * time.Minute
+	}
+	if cfg.JobTimeout <= 0 {
+		cfg.JobTimeout = 2 * time.Minute
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = time.Minute
+	}
+	return &Pool{
+		cfg:  cfg,
+		idle: make(chan *worker, cfg.Size),
+		log:  utils.ChildLogger("pob-pool"),
+		done: make(chan struct{}),
+	}
+}
+
+// Start writes the worker script to disk and spawns every worker.
+func (p *Pool) Start() error {
+	dir, err := os.MkdirTemp("", "exile-tracker-pob")
+	if err != nil {
+		return fmt.Errorf("failed to create worker script directory: %w", err)
+	}
+	p.script = filepath.Join(dir, "worker.lua")
+	if err := os.WriteFile(p.script, workerScript, 0644); err != nil {
+		return fmt.Errorf("failed to write worker script: %w", err)
+	}
+
+	p.log.Info().Int("size", p.cfg.Size).Msg("Starting PoB worker pool")
+	for i := 0; i < p.cfg.Size; i++ {
+		w, err := p.spawn()
+		if err != nil {
+			p.Close()
+			return err
+		}
+		p.idle <- w
+	}
+
+	p.wg.Add(1)
+	go p.healthLoop()
+	return nil
+}
+
+// Submit runs a job on the next free worker. Workers that crash or time out
+// are replaced before the error is returned.
+func (p *Pool) Submit(ctx context.Context, job Job) (Result, error) {
+	w, err := p.acquire(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, p.cfg.JobTimeout)
+	defer cancel()
+
+	res, err := w.run(jobCtx, job)
+	if err != nil {
+		var jobErr *JobError
+		if errors.As(err, &jobErr) {
+			p.release(w)
+			return Result{}, err
+		}
+		w.log.Warn().Err(err).Msg("PoB worker failed, restarting it")
+		p.replace(w)
+		return Result{}, err
+	}
+
+	p.release(w)
+	return res, nil
+}
+
+// Close stops the health checks and kills every idle worker. Workers busy
+// with a job are killed when they are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for {
+		select {
+		case w := <-p.idle:
+			w.kill()
+		default:
+			if p.script != "" {
+				os.RemoveAll(filepath.Dir(p.script))
+			}
+			p.log.Info().Msg("PoB worker pool stopped")
+			return
+		}
+	}
+}
+
+func (p *Pool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *Pool) spawn() (*worker, error) {
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	p.mu.Unlock()
+	return startWorker(id, p.cfg, p.script, p.log)
+}
+
+func (p *Pool) acquire(ctx context.Context) (*worker, error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case w := <-p.idle:
+		return w, nil
+	}
+}
+
+// release puts a worker back in the pool, recycling it first when it has
+// processed its share of jobs.
+func (p *Pool) release(w *worker) {
+	if p.cfg.MaxJobsPerWorker > 0 && w.jobs >= p.cfg.MaxJobsPerWorker {
+		w.log.Debug().Int("jobs", w.jobs).Msg("Recycling PoB worker")
+		p.replace(w)
+		return
+	}
+	p.put(w)
+}
+
+// put hands a worker back to the idle channel, or kills it when the pool is
+// closed. closed is checked under mu so Close can't drain idle in between
+// and leave the worker running.
+func (p *Pool) put(w *worker) {
+	p.mu.Lock()
+	closed := p.closed
+	if !closed {
+		p.idle <- w
+	}
+	p.mu.Unlock()
+	if closed {
+		w.kill()
+	}
+}
+
+// replace kills a worker and starts a new one in its place. If the new
+// worker can't be started the pool retries on the next health check.
+func (p *Pool) replace(w *worker) {
+	w.kill()
+	if p.isClosed() {
+		return
+	}
+	nw, err := p.spawn()
+	if err != nil {
+		p.log.Error().Err(err).Msg("Failed to restart PoB worker")
+		p.wg.Add(1)
+		go p.respawnLater()
+		return
+	}
+	p.put(nw)
+}
+
+func (p *Pool) respawnLater() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-time.After(p.cfg.HealthCheckInterval):
+		}
+		w, err := p.spawn()
+		if err != nil {
+			p.log.Error().Err(err).Msg("Failed to restart PoB worker")
+			continue
+		}
+		p.put(w)
+		return
+	}
+}
+
+// healthLoop periodically pings workers that have been idle for a while.
+func (p *Pool) healthLoop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.checkIdleWorkers()
+		}
+	}
+}
+
+func (p *Pool) checkIdleWorkers() {
+	for i := 0; i < p.cfg.Size; i++ {
+		var w *worker
+		select {
+		case w = <-p.idle:
+		default:
+			return
+		}
+
+		if time.Since(w.lastUsed) < p.cfg.HealthCheckInterval {
+			p.put(w)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := w.ping(ctx)
+		cancel()
+		if err != nil {
+			w.log.Warn().Err(err).Msg("PoB worker failed health check, restarting it")
+			p.replace(w)
+			continue
+		}
+		p.put(w)
+	}
+}
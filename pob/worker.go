@@ -0,0 +1,187 @@
+package pob
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var errWorkerExited = errors.New("pob worker exited")
+
+// message is the envelope used for both directions of the worker protocol.
+type message struct {
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Items    any                `json:"items,omitempty"`
+	Passives any                `json:"passives,omitempty"`
+	OK       bool               `json:"ok,omitempty"`
+	Code     string             `json:"code,omitempty"`
+	Stats    map[string]float64 `json:"stats,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// worker is a single HeadlessWrapper process.
+type worker struct {
+	id       int
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	lines    chan []byte
+	seq      int
+	jobs     int
+	lastUsed time.Time
+	log      zerolog.Logger
+}
+
+func startWorker(id int, cfg PoolConfig, script string, log zerolog.Logger) (*worker, error) {
+	srcDir := filepath.Join(cfg.POBRoot, "src")
+	runtimeLua := filepath.Join(cfg.POBRoot, "runtime", "lua")
+	runtime := filepath.Join(cfg.POBRoot, "runtime")
+
+	cmd := exec.Command(cfg.LuaJITPath, script)
+	cmd.Dir = srcDir
+	cmd.Env = append(os.Environ(),
+		"LUA_PATH="+runtimeLua+"/?.lua;"+runtimeLua+"/?/init.lua;;",
+		"LUA_CPATH="+runtime+"/?.so;"+runtime+"/?.dll;;",
+	)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start pob worker: %w", err)
+	}
+
+	w := &worker{
+		id:       id,
+		cmd:      cmd,
+		stdin:    stdin,
+		lines:    make(chan []byte, 16),
+		lastUsed: time.Now(),
+		log:      log.With().Int("worker", id).Logger(),
+	}
+
+	go w.readStdout(stdout)
+	go w.readStderr(stderr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.StartTimeout)
+	defer cancel()
+	if _, err := w.await(ctx, func(m message) bool { return m.Type == "ready" }); err != nil {
+		w.kill()
+		return nil, fmt.Errorf("pob worker did not become ready: %w", err)
+	}
+
+	w.log.Debug().Int("pid", cmd.Process.Pid).Msg("PoB worker ready")
+	return w, nil
+}
+
+func (w *worker) readStdout(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := make([]byte, len(scanner.Bytes()))
+		copy(line, scanner.Bytes())
+		w.lines <- line
+	}
+	close(w.lines)
+}
+
+func (w *worker) readStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		w.log.Debug().Str("output", scanner.Text()).Msg("PoB worker output")
+	}
+}
+
+// await reads protocol lines until one satisfies match. Lines that are not
+// valid JSON are skipped.
+func (w *worker) await(ctx context.Context, match func(message) bool) (message, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return message{}, ctx.Err()
+		case line, ok := <-w.lines:
+			if !ok {
+				return message{}, errWorkerExited
+			}
+			var m message
+			if err := json.Unmarshal(line, &m); err != nil {
+				continue
+			}
+			if match(m) {
+				return m, nil
+			}
+		}
+	}
+}
+
+func (w *worker) request(ctx context.Context, req message) (message, error) {
+	w.seq++
+	req.ID = strconv.Itoa(w.seq)
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return message{}, err
+	}
+	data = append(data, '\n')
+	if _, err := w.stdin.Write(data); err != nil {
+		return message{}, errors.Join(errWorkerExited, err)
+	}
+
+	w.lastUsed = time.Now()
+	return w.await(ctx, func(m message) bool { return m.ID == req.ID })
+}
+
+func (w *worker) ping(ctx context.Context) error {
+	res, err := w.request(ctx, message{Type: "ping"})
+	if err != nil {
+		return err
+	}
+	if res.Type != "pong" {
+		return fmt.Errorf("unexpected ping response %q", res.Type)
+	}
+	return nil
+}
+
+func (w *worker) run(ctx context.Context, job Job) (Result, error) {
+	res, err := w.request(ctx, message{
+		Type:     "build",
+		Items:    job.Items,
+		Passives: job.Passives,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	w.jobs++
+	if !res.OK {
+		return Result{}, &JobError{Message: res.Error}
+	}
+	return Result{Code: res.Code, Stats: res.Stats}, nil
+}
+
+func (w *worker) kill() {
+	w.stdin.Close()
+	if w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+	w.cmd.Wait()
+}
@@ -0,0 +1,94 @@
+-- Long-lived Path of Building worker used by the exile-tracker fetcher.
+--
+-- Must be started with the PoB "src" directory as the working directory.
+-- Reads one JSON request per line from stdin and answers with one JSON
+-- response per line on stdout. Anything PoB prints is redirected to stderr
+-- so stdout only carries protocol messages.
+--
+-- Requests:
+--   {"id": "1", "type": "ping"}
+--   {"id": "2", "type": "build", "items": {...}, "passives": {...}}
+-- Responses:
+--   {"id": "1", "type": "pong"}
+--   {"id": "2", "ok": true, "code": "...", "stats": {"Life": 4200, ...}}
+--   {"id": "2", "ok": false, "error": "..."}
+
+local stdout = io.stdout
+
+print = function(...)
+	local parts = {}
+	for i = 1, select("#", ...) do
+		parts[#parts + 1] = tostring(select(i, ...))
+	end
+	io.stderr:write(table.concat(parts, "\t"), "\n")
+end
+
+arg = {}
+dofile("HeadlessWrapper.lua")
+
+local dkjson = require("dkjson")
+
+-- Keys read from build.calcsTab.mainOutput after every build
+local statKeys = {
+	"CombinedDPS",
+	"Life",
+	"EnergyShield",
+	"Mana",
+	"TotalEHP",
+	"PhysicalMaximumHitTaken",
+	"FireMaximumHitTaken",
+	"ColdMaximumHitTaken",
+	"LightningMaximumHitTaken",
+	"ChaosMaximumHitTaken",
+	"FireResist",
+	"ColdResist",
+	"LightningResist",
+	"ChaosResist",
+	"EffectiveMovementSpeedMod",
+}
+
+local function respond(msg)
+	stdout:write(dkjson.encode(msg), "\n")
+	stdout:flush()
+end
+
+local function exportCode()
+	local xmlText = build:SaveDB("code")
+	local code = common.base64.encode(Deflate(xmlText))
+	code = code:gsub("+", "-"):gsub("/", "_")
+	return code
+end
+
+local function collectStats()
+	local output = build.calcsTab.mainOutput or {}
+	local stats = {}
+	for _, key in ipairs(statKeys) do
+		if type(output[key]) == "number" then
+			stats[key] = output[key]
+		end
+	end
+	return stats
+end
+
+local function runBuild(req)
+	loadBuildFromJSON(dkjson.encode(req.items), dkjson.encode(req.passives))
+	return { id = req.id, ok = true, code = exportCode(), stats = collectStats() }
+end
+
+respond({ type = "ready" })
+
+for line in io.stdin:lines() do
+	local req, _, err = dkjson.decode(line)
+	if type(req) ~= "table" then
+		respond({ ok = false, error = "invalid request: " .. tostring(err) })
+	elseif req.type == "ping" then
+		respond({ id = req.id, type = "pong" })
+	else
+		local ok, res = pcall(runBuild, req)
+		if ok then
+			respond(res)
+		else
+			respond({ id = req.id, ok = false, error = tostring(res) })
+		end
+	end
+end
@@ -19,13 +19,34 @@ const (
 	CharactersEndpoint    = "/character-window/get-characters"
 	PassiveSkillsEndpoint = "/character-window/get-passive-skills"
 	ItemsEndpoint         = "/character-window/get-items"
+	LeaguesEndpoint       = "/leagues"
 
 	UserAgent = "Oath exile-tracker/0.0.1 (contact: neryt.alexander@gmail.com)"
 )
 
+// Realms the PoE API serves characters from.
+const (
+	RealmPC   = "pc"
+	RealmXbox = "xbox"
+	RealmSony = "sony"
+)
+
+var Realms = []string{RealmPC, RealmXbox, RealmSony}
+
+// IsValidRealm reports whether realm is one of Realms.
+func IsValidRealm(realm string) bool {
+	for _, r := range Realms {
+		if r == realm {
+			return true
+		}
+	}
+	return false
+}
+
 type POEClient struct {
 	httpClient *http.Client
 	baseURL    string
+	apiBaseURL string
 	userAgent  string
 	log        zerolog.Logger
 }
@@ -57,20 +78,27 @@ type POEError struct {
 	} `json:"error"`
 }
 
-func NewPoeClient(timeout time.Duration) *POEClient {
+// NewPoeClient creates a client for the character window endpoints of the
+// website. apiBaseURL is the root of the public API, used for league data.
+func NewPoeClient(timeout time.Duration, apiBaseURL string) *POEClient {
 	return &POEClient{
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
-		baseURL:   BaseURL,
-		userAgent: UserAgent,
-		log:       utils.ChildLogger("poe-client"),
+		baseURL:    BaseURL,
+		apiBaseURL: apiBaseURL,
+		userAgent:  UserAgent,
+		log:        utils.ChildLogger("poe-client"),
 	}
 }
 
 func (pc *POEClient) makeRequest(endpoint string, params map[string]string) (*http.Response, error) {
+	return pc.makeRequestTo(pc.baseURL, endpoint, params)
+}
+
+func (pc *POEClient) makeRequestTo(baseURL string, endpoint string, params map[string]string) (*http.Response, error) {
 	//parse url
-	u, err := url.Parse(pc.baseURL + endpoint)
+	u, err := url.Parse(baseURL + endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
@@ -114,7 +142,7 @@ func (pc *POEClient) makeRequest(endpoint string, params map[string]string) (*ht
 		if err != nil {
 			return nil, fmt.Errorf("couldnt unmarshall error response %w", err)
 		}
-
+		return nil, fmt.Errorf("request to %s failed with status %d: %s", endpoint, res.StatusCode, poeError.Error.Message)
 	}
 
 	return res, nil
@@ -232,3 +260,34 @@ func (pc *POEClient) GetCharactersJson(acName string, realm string) ([]byte, err
 	}
 	return data, nil
 }
+
+// League is a league as listed by the public API.
+type League struct {
+	ID      string     `json:"id"`
+	Realm   string     `json:"realm"`
+	StartAt *time.Time `json:"startAt"`
+	EndAt   *time.Time `json:"endAt"`
+	Rules   []struct {
+		ID string `json:"id"`
+	} `json:"rules"`
+}
+
+// GetLeagues lists the main leagues of a realm, including the permanent
+// ones and leagues that have ended recently.
+func (pc *POEClient) GetLeagues(realm string) ([]League, error) {
+	params := map[string]string{
+		"type":  "main",
+		"realm": realm,
+	}
+	res, err := pc.makeRequestTo(pc.apiBaseURL, LeaguesEndpoint, params)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var leagues []League
+	if err := json.NewDecoder(res.Body).Decode(&leagues); err != nil {
+		return nil, fmt.Errorf("error while decoding get leagues response %w", err)
+	}
+	return leagues, nil
+}
@@ -0,0 +1,76 @@
+package poeclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	LadderEndpoint = "/api/ladders/"
+
+	// LadderPageSize is the most entries the ladder endpoint returns at once.
+	LadderPageSize = 200
+	// LadderMaxDepth is how deep the public ladder can be paged.
+	LadderMaxDepth = 15000
+)
+
+// LadderDepth is how deep a character went in the Azurite Mine, only set
+// on leagues with a Delve ladder.
+type LadderDepth struct {
+	Default int `json:"default"`
+	Solo    int `json:"solo"`
+}
+
+type LadderCharacter struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Level      int          `json:"level"`
+	Class      string       `json:"class"`
+	Experience int64        `json:"experience"`
+	Depth      *LadderDepth `json:"depth"`
+}
+
+type LadderAccount struct {
+	Name  string `json:"name"`
+	Realm string `json:"realm"`
+}
+
+type LadderEntry struct {
+	Rank      int             `json:"rank"`
+	Dead      bool            `json:"dead"`
+	Retired   bool            `json:"retired"`
+	Online    bool            `json:"online"`
+	Character LadderCharacter `json:"character"`
+	Account   LadderAccount   `json:"account"`
+}
+
+type Ladder struct {
+	Total   int           `json:"total"`
+	Entries []LadderEntry `json:"entries"`
+}
+
+// GetLadder returns one page of the public ladder of a league. limit is
+// capped to LadderPageSize.
+func (pc *POEClient) GetLadder(league string, realm string, offset int, limit int) (Ladder, error) {
+	if limit <= 0 || limit > LadderPageSize {
+		limit = LadderPageSize
+	}
+	params := map[string]string{
+		"offset": strconv.Itoa(offset),
+		"limit":  strconv.Itoa(limit),
+		"realm":  realm,
+	}
+	res, err := pc.makeRequest(LadderEndpoint+url.PathEscape(league), params)
+	if err != nil {
+		return Ladder{}, err
+	}
+	defer res.Body.Close()
+
+	var ladder Ladder
+	if err := json.NewDecoder(res.Body).Decode(&ladder); err != nil {
+		return Ladder{}, fmt.Errorf("error while decoding get ladder response %w", err)
+	}
+	return ladder, nil
+}
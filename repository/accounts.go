@@ -7,10 +7,20 @@ import (
 	"github.com/google/uuid"
 )
 
-func (r *Repository) GetAllAccounts() ([]models.Account, error) {
-	query := "SELECT id, account_name, player, updated_at, created_at FROM accounts WHERE deleted_at IS NULL"
+// GetAllAccounts returns every account. When league is set only accounts
+// with a character in that league are returned.
+func (r *Repository) GetAllAccounts(league string) ([]models.Account, error) {
+	query := `
+	SELECT id, account_name, player, realm, updated_at, created_at
+	FROM accounts
+	WHERE deleted_at IS NULL
+	AND (? = '' OR EXISTS (
+		SELECT 1 FROM characters c
+		WHERE c.account_id = accounts.id AND c.deleted_at IS NULL AND c.current_league = ?
+	))
+	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, league, league)
 	if err != nil {
 		return nil, err
 	}
@@ -19,7 +29,7 @@ func (r *Repository) GetAllAccounts() ([]models.Account, error) {
 	var accounts []models.Account
 	for rows.Next() {
 		var acc models.Account
-		err := rows.Scan(&acc.ID, &acc.AccountName, &acc.Player, &acc.UpdatedAt, &acc.CreatedAt)
+		err := rows.Scan(&acc.ID, &acc.AccountName, &acc.Player, &acc.Realm, &acc.UpdatedAt, &acc.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -28,23 +38,56 @@ func (r *Repository) GetAllAccounts() ([]models.Account, error) {
 	return accounts, nil
 }
 
-func (r Repository) CreateAccount(accountName string, player string) error {
+// CreateAccount stores a new account and returns it as persisted.
+func (r *Repository) CreateAccount(accountName string, player string, realm string) (models.Account, error) {
 	query := `
-	INSERT INTO accounts (id, account_name, player, created_at, updated_at)
-	VALUES(?, ?, ?, ?, ?)
+	INSERT INTO accounts (id, account_name, player, realm, created_at, updated_at)
+	VALUES(?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now().UTC().Format(time.RFC3339)
 
 	idString := uuid.New().String()
 
-	_, err := r.db.Exec(query, idString, accountName, player, now, now)
-	return err
+	_, err := r.db.Exec(query, idString, accountName, player, realm, now, now)
+	if err != nil {
+		return models.Account{}, mapError("account", err)
+	}
+	return r.GetAccountByID(idString)
+}
+
+// UpsertAccount creates an account unless one with that name already
+// exists in the realm, in which case the existing one is returned
+// untouched. It reports whether the account was created.
+func (r *Repository) UpsertAccount(accountName string, player string, realm string) (models.Account, bool, error) {
+	query := `
+	INSERT INTO accounts (id, account_name, player, realm, created_at, updated_at)
+	VALUES(?, ?, ?, ?, ?, ?)
+	ON CONFLICT DO NOTHING
+	`
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	idString := uuid.New().String()
+
+	res, err := r.db.Exec(query, idString, accountName, player, realm, now, now)
+	if err != nil {
+		return models.Account{}, false, mapError("account", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return models.Account{}, false, err
+	}
+	if n == 0 {
+		acc, err := r.GetAccountByName(accountName, realm)
+		return acc, false, mapError("account", err)
+	}
+	acc, err := r.GetAccountByID(idString)
+	return acc, true, err
 }
 
 func (r *Repository) GetAccountByID(id string) (models.Account, error) {
 	query := `
-	SELECT id, account_name, player, updated_at, created_at
+	SELECT id, account_name, player, realm, updated_at, created_at
 	FROM accounts
 	WHERE id = ?
 	`
@@ -54,6 +97,30 @@ func (r *Repository) GetAccountByID(id string) (models.Account, error) {
 		&a.ID,
 		&a.AccountName,
 		&a.Player,
+		&a.Realm,
+		&a.UpdatedAt,
+		&a.CreatedAt,
+	)
+	if err != nil {
+		return models.Account{}, mapError("account", err)
+	}
+	return a, nil
+}
+
+// GetAccountByName finds an account by its PoE account name in a realm.
+func (r *Repository) GetAccountByName(accountName string, realm string) (models.Account, error) {
+	query := `
+	SELECT id, account_name, player, realm, updated_at, created_at
+	FROM accounts
+	WHERE account_name = ? AND realm = ? AND deleted_at IS NULL
+	`
+
+	var a models.Account
+	err := r.db.QueryRow(query, accountName, realm).Scan(
+		&a.ID,
+		&a.AccountName,
+		&a.Player,
+		&a.Realm,
 		&a.UpdatedAt,
 		&a.CreatedAt,
 	)
@@ -67,6 +134,7 @@ const updateAccount = `
 UPDATE accounts
 SET account_name = ?, 
 		player = ?, 
+		realm = ?,
 		updated_at = ?
 WHERE id = ?
 `
@@ -75,45 +143,69 @@ type UpdateAccountParams struct {
 	ID          string
 	AccountName string
 	Player      string
+	Realm       string
 	UpdatedAt   string
 }
 
 func (r *Repository) UpdateAccount(arg UpdateAccountParams) error {
-	_, err := r.db.Exec(updateAccount,
+	res, err := r.db.Exec(updateAccount,
 		arg.AccountName,
 		arg.Player,
+		arg.Realm,
 		arg.UpdatedAt,
 		arg.ID,
 	)
-	if err != nil {
-		return err
-	}
-	return nil
+	return mapAffected("account", res, err)
 }
 
-func (r *Repository) SearchAccounts(searchTerm string) ([]models.Account, error) {
-	query := `
-	SELECT id, account_name, player, updated_at, created_at 
-	FROM accounts 
-	WHERE deleted_at IS NULL 
-	AND (account_name LIKE ? OR player LIKE ?)
-	`
+var accountSorts = map[string]string{
+	"name":       "account_name COLLATE NOCASE",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
 
-	searchPattern := "%" + searchTerm + "%"
-	rows, err := r.db.Query(query, searchPattern, searchPattern)
-	if err != nil {
-		return nil, err
+type ListAccountsParams struct {
+	models.PageParams
+	League        string
+	Realm         string
+	Query         string
+	WatchedBy     string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// ListAccounts returns one page of accounts matching every filter that is
+// set. Query matches the account name or player, WatchedBy keeps the
+// accounts a user watches directly or through one of their characters.
+func (r *Repository) ListAccounts(arg ListAccountsParams) (models.Page[models.Account], error) {
+	q := listQuery{
+		columns:     `id, account_name, player, realm, updated_at, created_at`,
+		table:       `accounts`,
+		sorts:       accountSorts,
+		defaultSort: "created_at",
 	}
-	defer rows.Close()
+	q.filter("deleted_at IS NULL")
+	if arg.League != "" {
+		q.filter(`EXISTS (
+		SELECT 1 FROM characters c
+		WHERE c.account_id = accounts.id AND c.deleted_at IS NULL AND c.current_league = ?
+	)`, arg.League)
+	}
+	if arg.Realm != "" {
+		q.filter("realm = ?", arg.Realm)
+	}
+	if arg.Query != "" {
+		searchPattern := "%" + arg.Query + "%"
+		q.filter("(account_name LIKE ? OR player LIKE ?)", searchPattern, searchPattern)
+	}
+	if arg.WatchedBy != "" {
+		q.filter("id IN (SELECT account_id FROM watchlist_entries WHERE user_id = ?)", arg.WatchedBy)
+	}
+	q.filterTime("created_at", arg.CreatedAfter, arg.CreatedBefore)
 
-	var accounts []models.Account
-	for rows.Next() {
+	return paginate(r.db, q, arg.PageParams, func(row rowScanner) (models.Account, error) {
 		var acc models.Account
-		err := rows.Scan(&acc.ID, &acc.AccountName, &acc.Player, &acc.UpdatedAt, &acc.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		accounts = append(accounts, acc)
-	}
-	return accounts, nil
+		err := row.Scan(&acc.ID, &acc.AccountName, &acc.Player, &acc.Realm, &acc.UpdatedAt, &acc.CreatedAt)
+		return acc, err
+	})
 }
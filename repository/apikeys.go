@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/google/uuid"
+)
+
+const apiKeyColumns = `id, name, prefix, scope, created_at, last_used_at, revoked_at`
+
+type CreateAPIKeyParams struct {
+	Name    string
+	Prefix  string
+	KeyHash string
+	Scope   string
+}
+
+func (r *Repository) CreateAPIKey(arg CreateAPIKeyParams) (models.APIKey, error) {
+	query := `
+	INSERT INTO api_keys (id, name, prefix, key_hash, scope, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now().UTC()
+	key := models.APIKey{
+		ID:        uuid.New().String(),
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		Scope:     arg.Scope,
+		CreatedAt: now,
+	}
+	_, err := r.db.Exec(query, key.ID, key.Name, key.Prefix, arg.KeyHash, key.Scope, now.Format(time.RFC3339))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// GetActiveAPIKeyByHash finds a key that hasn't been revoked.
+func (r *Repository) GetActiveAPIKeyByHash(keyHash string) (models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`
+	return scanAPIKey(r.db.QueryRow(query, keyHash))
+}
+
+func (r *Repository) GetAllAPIKeys() ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at ASC`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// ErrAmbiguousAPIKey is returned when a prefix matches several active keys.
+var ErrAmbiguousAPIKey = errors.New("matches more than one key, revoke it by ID")
+
+// RevokeAPIKey revokes a key by its ID or prefix. It returns
+// sql.ErrNoRows when no active key matches and ErrAmbiguousAPIKey, revoking
+// nothing, when a prefix matches several.
+func (r *Repository) RevokeAPIKey(idOrPrefix string) error {
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM api_keys WHERE (id = ? OR prefix = ?) AND revoked_at IS NULL`, idOrPrefix, idOrPrefix)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	switch {
+	case len(ids) == 0:
+		return sql.ErrNoRows
+	case len(ids) > 1:
+		return fmt.Errorf("%q %w", idOrPrefix, ErrAmbiguousAPIKey)
+	}
+
+	_, err = tx.Exec(`UPDATE api_keys SET revoked_at = ? WHERE id = ?`, time.Now().UTC().Format(time.RFC3339), ids[0])
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) TouchAPIKey(id string) error {
+	_, err := r.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, time.Now().UTC().Format(time.RFC3339), id)
+	return err
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scope, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return k, nil
+}
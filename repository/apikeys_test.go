@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestRevokeAPIKey(t *testing.T) {
+	repo, db := newTestRepository(t, "")
+
+	create := func(name string, prefix string) string {
+		t.Helper()
+		k, err := repo.CreateAPIKey(CreateAPIKeyParams{Name: name, Prefix: prefix, KeyHash: name, Scope: "read"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k.ID
+	}
+	shared1 := create("one", "et_shared")
+	create("two", "et_shared")
+	create("three", "et_alone")
+
+	if err := repo.RevokeAPIKey("et_shared"); !errors.Is(err, ErrAmbiguousAPIKey) {
+		t.Errorf("revoking a shared prefix: err = %v, want ErrAmbiguousAPIKey", err)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NOT NULL`); n != 0 {
+		t.Fatalf("%d keys revoked by an ambiguous prefix, want none", n)
+	}
+
+	if err := repo.RevokeAPIKey("et_alone"); err != nil {
+		t.Errorf("revoking a unique prefix: %v", err)
+	}
+	if err := repo.RevokeAPIKey(shared1); err != nil {
+		t.Errorf("revoking by ID: %v", err)
+	}
+	// The other key sharing the prefix is now the only active one.
+	if err := repo.RevokeAPIKey("et_shared"); err != nil {
+		t.Errorf("revoking the last key of a prefix: %v", err)
+	}
+	if err := repo.RevokeAPIKey("et_alone"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoking a revoked key: err = %v, want sql.ErrNoRows", err)
+	}
+}
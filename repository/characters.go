@@ -7,49 +7,14 @@ import (
 	"github.com/google/uuid"
 )
 
-const searchCharactersInAccount = `
-	SELECT id, character_name
-	FROM characters
-	WHERE deleted_at IS NULL
-	AND account_id = ?
-	AND character_name LIKE ?
-`
-
-type SearchCharactersInAccountParams struct {
-	AccountId string
-	Query     string
-}
-
-func (r *Repository) SearchCharactersInAccount(params SearchCharactersInAccountParams) ([]models.Character, error) {
-	searchPattern := "%" + params.Query + "%"
-	rows, err := r.db.Query(searchCharactersInAccount, params.AccountId, searchPattern)
-
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var characters []models.Character
-
-	for rows.Next() {
-		var c models.Character
-		err := rows.Scan(&c.ID, &c.CharacterName)
-		if err != nil {
-			return nil, err
-		}
-		characters = append(characters, c)
-	}
-
-	return characters, nil
-}
-
-func (r *Repository) GetCharactersByAccountId(accountId string) ([]models.Character, error) {
+func (r *Repository) GetCharactersByAccountId(accountId string, league string) ([]models.Character, error) {
 	query := `
-	SELECT id, account_id, character_name, died, current_league, created_at, updated_at
+	SELECT id, account_id, character_name, died, current_league, realm, created_at, updated_at
 	FROM characters
 	WHERE account_id = ? AND deleted_at IS NULL
+	AND (? = '' OR current_league = ?)
 	`
-	rows, err := r.db.Query(query, accountId)
+	rows, err := r.db.Query(query, accountId, league, league)
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +29,7 @@ func (r *Repository) GetCharactersByAccountId(accountId string) ([]models.Charac
 			&char.CharacterName,
 			&char.Died,
 			&char.CurrentLeague,
+			&char.Realm,
 			&char.CreatedAt,
 			&char.UpdatedAt,
 		)
@@ -76,18 +42,50 @@ func (r *Repository) GetCharactersByAccountId(accountId string) ([]models.Charac
 	return characters, nil
 }
 
-func (r *Repository) CreateCharacter(accountId string, characterName string, currentLeague string) error {
+// CreateCharacter stores a new character and returns it as persisted.
+func (r *Repository) CreateCharacter(accountId string, characterName string, currentLeague string, realm string) (models.Character, error) {
 	query := `
-		INSERT INTO characters(id, account_id, character_name, current_league, created_at, updated_at)
-		VALUES(?,?,?,?,?,?)
+		INSERT INTO characters(id, account_id, character_name, current_league, realm, created_at, updated_at)
+		VALUES(?,?,?,?,?,?,?)
 	`
 
 	now := time.Now().UTC().Format(time.RFC3339)
 
 	idString := uuid.New().String()
-	_, err := r.db.Exec(query, idString, accountId, characterName, currentLeague, now, now)
+	_, err := r.db.Exec(query, idString, accountId, characterName, currentLeague, realm, now, now)
+	if err != nil {
+		return models.Character{}, mapError("character", err)
+	}
+	return r.GetCharacterByID(idString)
+}
 
-	return err
+// UpsertCharacter creates a character unless the account already has one
+// with that name, in which case the existing one is returned untouched. It
+// reports whether the character was created.
+func (r *Repository) UpsertCharacter(accountId string, characterName string, currentLeague string, realm string) (models.Character, bool, error) {
+	query := `
+		INSERT INTO characters(id, account_id, character_name, current_league, realm, created_at, updated_at)
+		VALUES(?,?,?,?,?,?,?)
+		ON CONFLICT DO NOTHING
+	`
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	idString := uuid.New().String()
+
+	res, err := r.db.Exec(query, idString, accountId, characterName, currentLeague, realm, now, now)
+	if err != nil {
+		return models.Character{}, false, mapError("character", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return models.Character{}, false, err
+	}
+	if n == 0 {
+		c, err := r.GetCharacterByName(accountId, characterName)
+		return c, false, mapError("character", err)
+	}
+	c, err := r.GetCharacterByID(idString)
+	return c, true, err
 }
 
 func (r *Repository) UpdateDiedStatus(characterId string, died bool) error {
@@ -108,16 +106,18 @@ func (r *Repository) KillCharacter(characterId string) error {
 		UPDATE characters SET died = ?, updated_at = ? 
 		WHERE id = ?
 	`
-	_, err := r.db.Exec(query, true, time.Now().UTC().Format(time.RFC3339), characterId)
-	return err
+	res, err := r.db.Exec(query, true, time.Now().UTC().Format(time.RFC3339), characterId)
+	return mapAffected("character", res, err)
 }
 
-func (r *Repository) GetCharactersToFetch() ([]models.CharactersToFetch, error) {
+func (r *Repository) GetCharactersToFetch(league string) ([]models.CharactersToFetch, error) {
 	query := `
-		SELECT id, character_id, last_fetch, should_skip
-		FROM characters_to_fetch
+		SELECT f.id, f.character_id, f.last_fetch, f.should_skip
+		FROM characters_to_fetch f
+		INNER JOIN characters c ON c.id = f.character_id
+		WHERE (? = '' OR c.current_league = ?)
 	`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, league, league)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +140,15 @@ func (r *Repository) GetCharactersToFetch() ([]models.CharactersToFetch, error)
 	return cToFetch, nil
 }
 
+// IsCharacterToFetch reports whether a character is already enrolled in
+// characters_to_fetch.
+func (r *Repository) IsCharacterToFetch(characterId string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM characters_to_fetch WHERE character_id = ?)`
+	var exists bool
+	err := r.db.QueryRow(query, characterId).Scan(&exists)
+	return exists, err
+}
+
 const addCharacterToFetch = `
 INSERT INTO characters_to_fetch(id, character_id)
 		VALUES(?,?)
@@ -149,26 +158,80 @@ type AddCharactersToFetchParams struct {
 	CharacterId string
 }
 
-func (r *Repository) AddCharacterToFetch(params AddCharactersToFetchParams) error {
+// AddCharacterToFetch enrolls a character in characters_to_fetch and
+// returns the enrollment as persisted.
+func (r *Repository) AddCharacterToFetch(params AddCharactersToFetchParams) (models.CharactersToFetch, error) {
 	id := uuid.New().String()
 	_, err := r.db.Exec(addCharacterToFetch, id, params.CharacterId)
-	return err
+	if err != nil {
+		return models.CharactersToFetch{}, mapError("character to fetch", err)
+	}
+	return r.GetCharacterToFetch(params.CharacterId)
+}
+
+// GetCharacterToFetch returns the enrollment of a character in
+// characters_to_fetch.
+func (r *Repository) GetCharacterToFetch(characterId string) (models.CharactersToFetch, error) {
+	query := `
+		SELECT id, character_id, last_fetch, should_skip
+		FROM characters_to_fetch
+		WHERE character_id = ?
+	`
+	var c models.CharactersToFetch
+	err := r.db.QueryRow(query, characterId).Scan(
+		&c.Id,
+		&c.CharacterId,
+		&c.LastFetch,
+		&c.ShouldSkip,
+	)
+	if err != nil {
+		return models.CharactersToFetch{}, mapError("character to fetch", err)
+	}
+	return c, nil
+}
+
+// EnsureCharacterToFetch enrolls a character in characters_to_fetch unless
+// it already is, so a character tracked for several reasons is only fetched
+// once. It reports whether the character was added.
+func (r *Repository) EnsureCharacterToFetch(characterId string) (bool, error) {
+	query := `
+	INSERT INTO characters_to_fetch(id, character_id)
+	VALUES(?, ?)
+	ON CONFLICT(character_id) DO NOTHING
+	`
+	res, err := r.db.Exec(query, uuid.New().String(), characterId)
+	if err != nil {
+		return false, mapError("character to fetch", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
 func (r *Repository) SetShouldSkip(shouldSkip bool, id string) error {
 	query := `
 		UPDATE characters_to_fetch
-		SET should_skip = ?, updated_at = ?
+		SET should_skip = ?
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query, shouldSkip, time.Now().UTC().Format(time.RFC3339), id)
-	return err
+	res, err := r.db.Exec(query, shouldSkip, id)
+	return mapAffected("character to fetch", res, err)
+}
+
+// RemoveCharacterToFetch stops fetching a character. Its snapshots are
+// kept. It reports whether the character was enrolled.
+func (r *Repository) RemoveCharacterToFetch(characterId string) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM characters_to_fetch WHERE character_id = ?`, characterId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
 func (r *Repository) GetCharacterByID(id string) (models.Character, error) {
 	query := `
-    SELECT id, account_id, character_name, died, current_league, created_at, updated_at
+    SELECT id, account_id, character_name, died, current_league, realm, created_at, updated_at
     FROM characters
     WHERE id = ?
     `
@@ -179,6 +242,31 @@ func (r *Repository) GetCharacterByID(id string) (models.Character, error) {
 		&c.CharacterName,
 		&c.Died,
 		&c.CurrentLeague,
+		&c.Realm,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return models.Character{}, mapError("character", err)
+	}
+	return c, nil
+}
+
+// GetCharacterByName finds a character of an account by name.
+func (r *Repository) GetCharacterByName(accountId string, characterName string) (models.Character, error) {
+	query := `
+    SELECT id, account_id, character_name, died, current_league, realm, created_at, updated_at
+    FROM characters
+    WHERE account_id = ? AND character_name = ? AND deleted_at IS NULL
+    `
+	var c models.Character
+	err := r.db.QueryRow(query, accountId, characterName).Scan(
+		&c.ID,
+		&c.AccountId,
+		&c.CharacterName,
+		&c.Died,
+		&c.CurrentLeague,
+		&c.Realm,
 		&c.CreatedAt,
 		&c.UpdatedAt,
 	)
@@ -188,13 +276,16 @@ func (r *Repository) GetCharacterByID(id string) (models.Character, error) {
 	return c, nil
 }
 
-func (r *Repository) GetAllCharacters() ([]models.Character, error) {
+// GetAllCharacters returns every character, or only the ones currently in
+// league when it is set.
+func (r *Repository) GetAllCharacters(league string) ([]models.Character, error) {
 	query := `
-	SELECT id, account_id, character_name, died, current_league, created_at, updated_at
+	SELECT id, account_id, character_name, died, current_league, realm, created_at, updated_at
 	FROM characters
 	WHERE deleted_at IS NULL
+	AND (? = '' OR current_league = ?)
 	`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, league, league)
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +300,7 @@ func (r *Repository) GetAllCharacters() ([]models.Character, error) {
 			&char.CharacterName,
 			&char.Died,
 			&char.CurrentLeague,
+			&char.Realm,
 			&char.CreatedAt,
 			&char.UpdatedAt,
 		)
@@ -226,6 +318,7 @@ UPDATE characters
 SET character_name = ?, 
 	died = ?, 
 	current_league = ?,
+	realm = ?,
 	updated_at = ?
 WHERE id = ?
 `
@@ -235,19 +328,104 @@ type UpdateCharacterParams struct {
 	CharacterName string
 	Died          bool
 	CurrentLeague string
+	Realm         string
 	UpdatedAt     string
 }
 
 func (r *Repository) UpdateCharacter(arg UpdateCharacterParams) error {
-	_, err := r.db.Exec(updateCharacter,
+	res, err := r.db.Exec(updateCharacter,
 		arg.CharacterName,
 		arg.Died,
 		arg.CurrentLeague,
+		arg.Realm,
 		arg.UpdatedAt,
 		arg.ID,
 	)
-	if err != nil {
-		return err
+	return mapAffected("character", res, err)
+}
+
+var characterSorts = map[string]string{
+	"name":       "character_name COLLATE NOCASE",
+	"league":     "COALESCE(current_league, '')",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// latestCharacterClasses is the class reported in the newest snapshot of
+// every character, the only place it is stored. It doesn't refer to the
+// rows being listed, so SQLite builds it once per query rather than once
+// for every character the page and its count look at.
+const latestCharacterClasses = `
+	SELECT p.character_id FROM pobsnapshots p
+	INNER JOIN snapshot_data d ON d.snapshot_id = p.id
+	WHERE p.deleted_at IS NULL AND p.created_at = (
+		SELECT MAX(l.created_at) FROM pobsnapshots l
+		WHERE l.character_id = p.character_id AND l.deleted_at IS NULL
+	)
+	AND json_extract(d.items_json, '$.character.class')`
+
+type ListCharactersParams struct {
+	models.PageParams
+	AccountId string
+	League    string
+	Realm     string
+	Query     string
+	Died      *bool
+	Tracked   *bool
+	// Classes keeps characters whose latest snapshot has one of these
+	// classes or ascendancies.
+	Classes       []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// ListCharacters returns one page of characters matching every filter that
+// is set. Tracked filters on whether the character is enrolled in
+// characters_to_fetch.
+func (r *Repository) ListCharacters(arg ListCharactersParams) (models.Page[models.Character], error) {
+	q := listQuery{
+		columns:     `id, account_id, character_name, died, current_league, realm, created_at, updated_at`,
+		table:       `characters`,
+		sorts:       characterSorts,
+		defaultSort: "created_at",
+	}
+	q.filter("deleted_at IS NULL")
+	if arg.AccountId != "" {
+		q.filter("account_id = ?", arg.AccountId)
+	}
+	if arg.League != "" {
+		q.filter("current_league = ?", arg.League)
+	}
+	if arg.Realm != "" {
+		q.filter("realm = ?", arg.Realm)
 	}
-	return nil
+	if arg.Query != "" {
+		q.filter("character_name LIKE ?", "%"+arg.Query+"%")
+	}
+	if arg.Died != nil {
+		q.filter("died = ?", *arg.Died)
+	}
+	if arg.Tracked != nil {
+		q.filter("EXISTS (SELECT 1 FROM characters_to_fetch f WHERE f.character_id = characters.id) = ?", *arg.Tracked)
+	}
+	if len(arg.Classes) > 0 {
+		list, args := inList(arg.Classes)
+		q.filter("id IN ("+latestCharacterClasses+" IN "+list+")", args...)
+	}
+	q.filterTime("created_at", arg.CreatedAfter, arg.CreatedBefore)
+
+	return paginate(r.db, q, arg.PageParams, func(row rowScanner) (models.Character, error) {
+		var char models.Character
+		err := row.Scan(
+			&char.ID,
+			&char.AccountId,
+			&char.CharacterName,
+			&char.Died,
+			&char.CurrentLeague,
+			&char.Realm,
+			&char.CreatedAt,
+			&char.UpdatedAt,
+		)
+		return char, err
+	})
 }
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+func TestListCharactersByClass(t *testing.T) {
+	repo, db := newTestRepository(t, "")
+	stmts := []string{
+		`INSERT INTO accounts (id, account_name, realm, created_at, updated_at) VALUES
+			('a1', 'Steel#1', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('a2', 'Ziz#2', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO characters (id, account_id, character_name, died, realm, created_at, updated_at) VALUES
+			('c1', 'a1', 'Mage', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('c2', 'a1', 'Witch', false, 'pc', '2025-01-02T00:00:00Z', '2025-01-02T00:00:00Z'),
+			('c3', 'a2', 'Ranger', false, 'pc', '2025-01-03T00:00:00Z', '2025-01-03T00:00:00Z'),
+			('c4', 'a2', 'Fresh', false, 'pc', '2025-01-04T00:00:00Z', '2025-01-04T00:00:00Z')`,
+		// c1 respecced from Witch to Elementalist, c2's newer snapshot was
+		// deleted.
+		`INSERT INTO pobsnapshots (id, character_id, export_string, created_at, updated_at, deleted_at) VALUES
+			('s1', 'c1', 'A', '2025-02-01T00:00:00Z', '2025-02-01T00:00:00Z', NULL),
+			('s2', 'c1', 'B', '2025-02-02T00:00:00Z', '2025-02-02T00:00:00Z', NULL),
+			('s3', 'c2', 'C', '2025-02-01T00:00:00Z', '2025-02-01T00:00:00Z', NULL),
+			('s4', 'c2', 'D', '2025-02-02T00:00:00Z', '2025-02-02T00:00:00Z', '2025-02-03T00:00:00Z'),
+			('s5', 'c3', 'E', '2025-02-01T00:00:00Z', '2025-02-01T00:00:00Z', NULL)`,
+		`INSERT INTO snapshot_data (snapshot_id, items_json, passives_json, created_at) VALUES
+			('s1', '{"character": {"class": "Witch"}}', '{}', '2025-02-01T00:00:00Z'),
+			('s2', '{"character": {"class": "Elementalist"}}', '{}', '2025-02-02T00:00:00Z'),
+			('s3', '{"character": {"class": "Witch"}}', '{}', '2025-02-01T00:00:00Z'),
+			('s4', '{"character": {"class": "Necromancer"}}', '{}', '2025-02-02T00:00:00Z'),
+			('s5', '{"character": {"class": "Deadeye"}}', '{}', '2025-02-01T00:00:00Z')`,
+	}
+	for _, stmt := range stmts {
+		exec(t, db, stmt)
+	}
+
+	tests := []struct {
+		name    string
+		params  ListCharactersParams
+		want    []string
+		wantAll int
+	}{
+		{"no class", ListCharactersParams{}, []string{"c1", "c2", "c3", "c4"}, 4},
+		{"latest snapshot only", ListCharactersParams{Classes: []string{"Witch"}}, []string{"c2"}, 1},
+		{"several classes", ListCharactersParams{Classes: []string{"Elementalist", "Deadeye"}}, []string{"c1", "c3"}, 2},
+		{"with other filters", ListCharactersParams{AccountId: "a1", Classes: []string{"Elementalist", "Deadeye"}}, []string{"c1"}, 1},
+		{"paged", ListCharactersParams{PageParams: models.PageParams{Limit: 1}, Classes: []string{"Elementalist", "Deadeye"}}, []string{"c1"}, 2},
+		{"deleted snapshot ignored", ListCharactersParams{Classes: []string{"Necromancer"}}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.ListCharacters(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, c := range page.Items {
+				ids = append(ids, c.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) || page.Total != tt.wantAll {
+				t.Errorf("ListCharacters() = %v of %d, want %v of %d", ids, page.Total, tt.want, tt.wantAll)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DedupeReport counts what MergeDuplicates changed.
+type DedupeReport struct {
+	// Accounts and Characters are the duplicates merged into another record.
+	Accounts   int
+	Characters int
+	// SnapshotsMoved were reassigned to the character kept, SnapshotsRemoved
+	// were identical to the snapshot before them once merged.
+	SnapshotsMoved   int
+	SnapshotsRemoved int
+	// LadderEntriesRemoved and ExperienceSamplesRemoved were recorded for
+	// several copies of a character at the same time, in the same league.
+	LadderEntriesRemoved     int
+	ExperienceSamplesRemoved int
+	// FetchEntries are repeated characters_to_fetch rows removed.
+	FetchEntries int
+}
+
+// dedupeTables are the tables MergeDuplicates moves rows of. It runs on a
+// database migrated up to 20261019200000, before 20261019210000 adds the
+// unique indexes.
+var dedupeTables = []string{"characters_to_fetch", "ladder_entries", "experience_samples", "watchlist_entries"}
+
+// MergeDuplicates merges accounts sharing a name in a realm, characters
+// sharing a name in an account and repeated characters_to_fetch rows, so
+// the unique indexes can be created. The oldest record of each group is
+// kept and everything pointing to the others is moved to it. With dryRun
+// the changes are counted and rolled back.
+func (r *Repository) MergeDuplicates(dryRun bool) (DedupeReport, error) {
+	var report DedupeReport
+
+	tx, err := r.begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range dedupeTables {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, table).Scan(&exists)
+		if err != nil {
+			return report, err
+		}
+		if !exists {
+			return report, fmt.Errorf("table %s is missing, migrate up to 20261019200000 before merging duplicates", table)
+		}
+	}
+
+	if err := mergeDuplicateAccounts(tx, &report); err != nil {
+		return report, err
+	}
+	if err := mergeDuplicateCharacters(tx, &report); err != nil {
+		return report, err
+	}
+
+	// One row per character is kept, preferring one that isn't skipped and
+	// was fetched last.
+	res, err := tx.Exec(`
+	DELETE FROM characters_to_fetch WHERE id NOT IN (
+		SELECT id FROM (
+			SELECT id, ROW_NUMBER() OVER (
+				PARTITION BY character_id ORDER BY should_skip ASC, last_fetch DESC, id ASC
+			) AS n
+			FROM characters_to_fetch
+		) WHERE n = 1
+	)
+	`)
+	if err != nil {
+		return report, err
+	}
+	report.FetchEntries, err = affected(res)
+	if err != nil {
+		return report, err
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+type duplicateRow struct {
+	id        string
+	key       string
+	player    *string
+	died      bool
+	league    *string
+	updatedAt time.Time
+}
+
+// groupDuplicates returns the groups of rows sharing a key, oldest first.
+func groupDuplicates(rows []duplicateRow) [][]duplicateRow {
+	groups := map[string][]duplicateRow{}
+	var keys []string
+	for _, row := range rows {
+		if _, ok := groups[row.key]; !ok {
+			keys = append(keys, row.key)
+		}
+		groups[row.key] = append(groups[row.key], row)
+	}
+	var dups [][]duplicateRow
+	for _, k := range keys {
+		if len(groups[k]) > 1 {
+			dups = append(dups, groups[k])
+		}
+	}
+	return dups
+}
+
+func mergeDuplicateAccounts(tx *sql.Tx, report *DedupeReport) error {
+	rows, err := tx.Query(`
+	SELECT id, account_name || char(0) || realm, player, updated_at
+	FROM accounts
+	WHERE deleted_at IS NULL
+	ORDER BY created_at ASC, id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	var accounts []duplicateRow
+	for rows.Next() {
+		var a duplicateRow
+		if err := rows.Scan(&a.id, &a.key, &a.player, &a.updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		accounts = append(accounts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, group := range groupDuplicates(accounts) {
+		keep := group[0]
+		for _, dup := range group[1:] {
+			// The player name is kept from a duplicate when the oldest
+			// account has none.
+			if dup.player != nil && *dup.player != "" {
+				_, err := tx.Exec(`UPDATE accounts SET player = ? WHERE id = ? AND (player IS NULL OR player = '')`, *dup.player, keep.id)
+				if err != nil {
+					return err
+				}
+			}
+			stmts := []string{
+				`UPDATE characters SET account_id = ? WHERE account_id = ?`,
+				`UPDATE OR IGNORE watchlist_entries SET account_id = ? WHERE account_id = ?`,
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt, keep.id, dup.id); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec(`DELETE FROM watchlist_entries WHERE account_id = ?`, dup.id); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM accounts WHERE id = ?`, dup.id); err != nil {
+				return err
+			}
+			report.Accounts++
+		}
+	}
+	return nil
+}
+
+func mergeDuplicateCharacters(tx *sql.Tx, report *DedupeReport) error {
+	rows, err := tx.Query(`
+	SELECT id, account_id || char(0) || character_name, died, current_league, updated_at
+	FROM characters
+	WHERE deleted_at IS NULL
+	ORDER BY created_at ASC, id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	var characters []duplicateRow
+	for rows.Next() {
+		var c duplicateRow
+		if err := rows.Scan(&c.id, &c.key, &c.died, &c.league, &c.updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		characters = append(characters, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, group := range groupDuplicates(characters) {
+		keep := group[0]
+
+		// Both copies were fetched every cycle, so the merged history holds
+		// the same build twice. They are found before the snapshots move.
+		removed, err := repeatedSnapshots(tx, group)
+		if err != nil {
+			return err
+		}
+
+		died := false
+		latest := group[0]
+		for _, c := range group {
+			died = died || c.died
+			if c.updatedAt.After(latest.updatedAt) {
+				latest = c
+			}
+		}
+		_, err = tx.Exec(`UPDATE characters SET died = ?, current_league = ? WHERE id = ?`, died, latest.league, keep.id)
+		if err != nil {
+			return err
+		}
+
+		for _, dup := range group[1:] {
+			res, err := tx.Exec(`UPDATE pobsnapshots SET character_id = ? WHERE character_id = ?`, keep.id, dup.id)
+			if err != nil {
+				return err
+			}
+			moved, err := affected(res)
+			if err != nil {
+				return err
+			}
+			report.SnapshotsMoved += moved
+
+			stmts := []string{
+				`UPDATE ladder_entries SET character_id = ? WHERE character_id = ?`,
+				`UPDATE experience_samples SET character_id = ? WHERE character_id = ?`,
+				`UPDATE characters_to_fetch SET character_id = ? WHERE character_id = ?`,
+				`UPDATE OR IGNORE watchlist_entries SET character_id = ? WHERE character_id = ?`,
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt, keep.id, dup.id); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec(`DELETE FROM watchlist_entries WHERE character_id = ?`, dup.id); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM characters WHERE id = ?`, dup.id); err != nil {
+				return err
+			}
+			report.Characters++
+		}
+
+		for _, id := range removed {
+			stmts := []string{
+				`DELETE FROM snapshot_stats WHERE snapshot_id = ?`,
+				`DELETE FROM snapshot_data WHERE snapshot_id = ?`,
+				`DELETE FROM pobsnapshots WHERE id = ?`,
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt, id); err != nil {
+					return err
+				}
+			}
+			report.SnapshotsRemoved++
+		}
+
+		// Ladder polls and experience samples were recorded for every copy,
+		// only one per league and time is kept.
+		res, err := tx.Exec(`
+		DELETE FROM ladder_entries WHERE character_id = ? AND EXISTS (
+			SELECT 1 FROM ladder_entries o
+			WHERE o.character_id = ladder_entries.character_id
+			AND o.league = ladder_entries.league
+			AND o.recorded_at = ladder_entries.recorded_at
+			AND o.rowid < ladder_entries.rowid
+		)
+		`, keep.id)
+		if err != nil {
+			return err
+		}
+		removedEntries, err := affected(res)
+		if err != nil {
+			return err
+		}
+		report.LadderEntriesRemoved += removedEntries
+
+		res, err = tx.Exec(`
+		DELETE FROM experience_samples WHERE character_id = ? AND EXISTS (
+			SELECT 1 FROM experience_samples o
+			WHERE o.character_id = experience_samples.character_id
+			AND o.league IS experience_samples.league
+			AND o.recorded_at = experience_samples.recorded_at
+			AND o.rowid < experience_samples.rowid
+		)
+		`, keep.id)
+		if err != nil {
+			return err
+		}
+		removedSamples, err := affected(res)
+		if err != nil {
+			return err
+		}
+		report.ExperienceSamplesRemoved += removedSamples
+	}
+	return nil
+}
+
+// repeatedSnapshots returns the snapshots of a group of duplicate
+// characters that are the same build as the snapshot before them, taken
+// from another character of the group.
+func repeatedSnapshots(tx *sql.Tx, group []duplicateRow) ([]string, error) {
+	type snapshot struct {
+		id           string
+		characterId  string
+		exportString string
+		createdAt    time.Time
+	}
+	var snapshots []snapshot
+	for _, c := range group {
+		rows, err := tx.Query(`
+		SELECT id, character_id, export_string, created_at
+		FROM pobsnapshots
+		WHERE character_id = ? AND deleted_at IS NULL
+		`, c.id)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var s snapshot
+			if err := rows.Scan(&s.id, &s.characterId, &s.exportString, &s.createdAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			snapshots = append(snapshots, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].createdAt.Before(snapshots[j].createdAt)
+	})
+	var repeated []string
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := snapshots[i-1], snapshots[i]
+		if cur.exportString == prev.exportString && cur.characterId != prev.characterId {
+			repeated = append(repeated, cur.id)
+			// The next snapshot is compared with the one kept.
+			snapshots[i] = prev
+		}
+	}
+	return repeated, nil
+}
+
+func affected(res sql.Result) (int, error) {
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupDuplicates(t *testing.T) {
+	rows := func(keys ...string) []duplicateRow {
+		var r []duplicateRow
+		for i, k := range keys {
+			r = append(r, duplicateRow{id: string(rune('a' + i)), key: k})
+		}
+		return r
+	}
+	ids := func(groups [][]duplicateRow) [][]string {
+		var out [][]string
+		for _, g := range groups {
+			var ids []string
+			for _, r := range g {
+				ids = append(ids, r.id)
+			}
+			out = append(out, ids)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		keys []string
+		want [][]string
+	}{
+		{"empty", nil, nil},
+		{"no duplicates", []string{"x", "y", "z"}, nil},
+		{"one group keeps order", []string{"x", "y", "x", "x"}, [][]string{{"a", "c", "d"}}},
+		{"groups in order of first row", []string{"y", "x", "x", "y"}, [][]string{{"a", "d"}, {"b", "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(groupDuplicates(rows(tt.keys...)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groupDuplicates(%v) = %v, want %v", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
+
+// seedDuplicates stores Steel#1 twice in pc, each copy with a Mage
+// character fetched, polled and snapshotted at the same times, and once
+// in xbox, which isn't a duplicate.
+func seedDuplicates(t *testing.T, db *sql.DB) {
+	t.Helper()
+	stmts := []string{
+		`INSERT INTO accounts (id, account_name, player, realm, created_at, updated_at) VALUES
+			('a1', 'Steel#1', NULL, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('a2', 'Steel#1', 'Steel', 'pc', '2025-02-01T00:00:00Z', '2025-02-01T00:00:00Z'),
+			('a3', 'Steel#1', NULL, 'xbox', '2025-03-01T00:00:00Z', '2025-03-01T00:00:00Z')`,
+		`INSERT INTO characters (id, account_id, character_name, died, current_league, realm, created_at, updated_at) VALUES
+			('c1', 'a1', 'Mage', false, 'Settlers', 'pc', '2025-01-01T00:00:00Z', '2025-01-05T00:00:00Z'),
+			('c2', 'a2', 'Mage', true, 'Standard', 'pc', '2025-02-01T00:00:00Z', '2025-03-01T00:00:00Z'),
+			('c3', 'a2', 'Other', false, NULL, 'pc', '2025-02-01T00:00:00Z', '2025-02-01T00:00:00Z')`,
+		// s2 repeats s1 from the other copy and s4 repeats s3, s3 is a new
+		// build.
+		`INSERT INTO pobsnapshots (id, character_id, export_string, created_at, updated_at) VALUES
+			('s1', 'c1', 'A', '2025-02-01T01:00:00Z', '2025-02-01T01:00:00Z'),
+			('s2', 'c2', 'A', '2025-02-01T02:00:00Z', '2025-02-01T02:00:00Z'),
+			('s3', 'c2', 'B', '2025-02-01T03:00:00Z', '2025-02-01T03:00:00Z'),
+			('s4', 'c1', 'B', '2025-02-01T04:00:00Z', '2025-02-01T04:00:00Z')`,
+		`INSERT INTO snapshot_stats (snapshot_id, created_at) VALUES
+			('s1', '2025-02-01T01:00:00Z'), ('s2', '2025-02-01T02:00:00Z')`,
+		`INSERT INTO ladder_entries (id, character_id, league, rank, level, experience, recorded_at) VALUES
+			('l1', 'c1', 'Settlers', 10, 90, 1000, '2025-02-02T00:00:00Z'),
+			('l2', 'c2', 'Settlers', 10, 90, 1000, '2025-02-02T00:00:00Z'),
+			('l3', 'c2', 'Settlers', 8, 91, 2000, '2025-02-03T00:00:00Z')`,
+		`INSERT INTO experience_samples (id, character_id, league, level, experience, recorded_at) VALUES
+			('e1', 'c1', NULL, 90, 1000, '2025-02-02T00:00:00Z'),
+			('e2', 'c2', NULL, 90, 1000, '2025-02-02T00:00:00Z'),
+			('e3', 'c2', 'Settlers', 90, 1000, '2025-02-02T00:00:00Z')`,
+		`INSERT INTO characters_to_fetch (id, character_id, last_fetch, should_skip) VALUES
+			('f1', 'c1', NULL, true),
+			('f2', 'c2', '2025-02-04T00:00:00Z', false)`,
+		`INSERT INTO users (id, username, password_hash, created_at, updated_at) VALUES
+			('u1', 'ana', 'x', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO watchlist_entries (id, user_id, account_id, character_id, created_at) VALUES
+			('w1', 'u1', 'a1', NULL, '2025-01-01T00:00:00Z'),
+			('w2', 'u1', 'a2', NULL, '2025-01-01T00:00:00Z'),
+			('w3', 'u1', 'a2', 'c2', '2025-01-01T00:00:00Z')`,
+	}
+	for _, stmt := range stmts {
+		exec(t, db, stmt)
+	}
+}
+
+var wantDedupeReport = DedupeReport{
+	Accounts:                 1,
+	Characters:               1,
+	SnapshotsMoved:           2,
+	SnapshotsRemoved:         2,
+	LadderEntriesRemoved:     1,
+	ExperienceSamplesRemoved: 1,
+	FetchEntries:             1,
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	repo, db := newTestRepository(t, "20261019200000")
+	seedDuplicates(t, db)
+
+	report, err := repo.MergeDuplicates(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != wantDedupeReport {
+		t.Errorf("report = %+v, want %+v", report, wantDedupeReport)
+	}
+
+	acc, err := repo.GetAccountByID("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Player == nil || *acc.Player != "Steel" {
+		t.Errorf("player = %v, want the one of the duplicate", acc.Player)
+	}
+	if _, err := repo.GetAccountByID("a2"); err == nil {
+		t.Error("duplicate account a2 still exists")
+	}
+
+	c, err := repo.GetCharacterByID("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Died || c.CurrentLeague == nil || *c.CurrentLeague != "Standard" {
+		t.Errorf("character = died %v league %v, want died in the league of the latest copy", c.Died, c.CurrentLeague)
+	}
+
+	checks := []struct {
+		query string
+		want  int
+	}{
+		{`SELECT COUNT(*) FROM characters WHERE account_id = 'a1'`, 2},
+		{`SELECT COUNT(*) FROM pobsnapshots WHERE character_id = 'c1' AND id IN ('s1', 's3')`, 2},
+		{`SELECT COUNT(*) FROM pobsnapshots`, 2},
+		{`SELECT COUNT(*) FROM snapshot_stats WHERE snapshot_id = 's2'`, 0},
+		{`SELECT COUNT(*) FROM ladder_entries WHERE character_id = 'c1'`, 2},
+		{`SELECT COUNT(*) FROM experience_samples WHERE character_id = 'c1'`, 2},
+		{`SELECT COUNT(*) FROM characters_to_fetch WHERE character_id = 'c1' AND id = 'f2'`, 1},
+		{`SELECT COUNT(*) FROM characters_to_fetch`, 1},
+		{`SELECT COUNT(*) FROM watchlist_entries WHERE account_id = 'a1'`, 2},
+		{`SELECT COUNT(*) FROM watchlist_entries WHERE character_id = 'c1'`, 1},
+	}
+	for _, c := range checks {
+		if got := count(t, db, c.query); got != c.want {
+			t.Errorf("%s = %d, want %d", c.query, got, c.want)
+		}
+	}
+
+	// The unique indexes can be created once merged, and merging again
+	// finds nothing.
+	migrate(t, db, "20261019200000", "")
+	report, err = repo.MergeDuplicates(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (DedupeReport{}) {
+		t.Errorf("second merge = %+v, want nothing", report)
+	}
+}
+
+func TestMergeDuplicatesDryRun(t *testing.T) {
+	repo, db := newTestRepository(t, "20261019200000")
+	seedDuplicates(t, db)
+
+	report, err := repo.MergeDuplicates(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != wantDedupeReport {
+		t.Errorf("report = %+v, want %+v", report, wantDedupeReport)
+	}
+	for table, want := range map[string]int{"accounts": 3, "characters": 3, "pobsnapshots": 4, "ladder_entries": 3, "characters_to_fetch": 2} {
+		if got := count(t, db, `SELECT COUNT(*) FROM `+table); got != want {
+			t.Errorf("%s has %d rows after a dry run, want %d", table, got, want)
+		}
+	}
+}
+
+func TestMergeDuplicatesOldSchema(t *testing.T) {
+	repo, _ := newTestRepository(t, "20261019160000")
+	_, err := repo.MergeDuplicates(true)
+	if err == nil || !strings.Contains(err.Error(), "20261019200000") {
+		t.Errorf("err = %v, want one asking to migrate up to 20261019200000", err)
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when the requested row doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write would break a unique constraint.
+	ErrConflict = errors.New("already exists")
+	// ErrInvalidReference is returned when a write points to a row that
+	// doesn't exist, like a character of an unknown account.
+	ErrInvalidReference = errors.New("references a record that doesn't exist")
+)
+
+// RecordError is one of the sentinel errors above for an entity, like
+// "account not found". The driver error stays in the chain, so callers
+// checking for sql.ErrNoRows keep working.
+type RecordError struct {
+	Kind   error
+	Entity string
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return e.Entity + " " + e.Kind.Error()
+}
+
+func (e *RecordError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// mapError turns the errors of a query on entity into a RecordError when
+// they match one of the sentinels, and returns them untouched otherwise.
+func mapError(entity string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &RecordError{Kind: ErrNotFound, Entity: entity, Err: err}
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return &RecordError{Kind: ErrConflict, Entity: entity, Err: err}
+		case sqlite3.ErrConstraintForeignKey:
+			return &RecordError{Kind: ErrInvalidReference, Entity: entity, Err: err}
+		}
+	}
+	return err
+}
+
+// mapAffected reports ErrNotFound for entity when a write touched no row.
+func mapAffected(entity string, res sql.Result, err error) error {
+	if err != nil {
+		return mapError(entity, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &RecordError{Kind: ErrNotFound, Entity: entity, Err: sql.ErrNoRows}
+	}
+	return nil
+}
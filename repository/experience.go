@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/google/uuid"
+)
+
+const createExperienceSample = `
+INSERT INTO experience_samples (id, character_id, league, level, experience, recorded_at)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type CreateExperienceSampleParams struct {
+	CharacterId string
+	League      string
+	Level       int
+	Experience  int64
+	RecordedAt  time.Time
+}
+
+func (r *Repository) CreateExperienceSample(arg CreateExperienceSampleParams) error {
+	var league *string
+	if arg.League != "" {
+		league = &arg.League
+	}
+	_, err := r.db.Exec(createExperienceSample,
+		uuid.New().String(),
+		arg.CharacterId,
+		league,
+		arg.Level,
+		arg.Experience,
+		arg.RecordedAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// GetExperienceSamplesByCharacter returns every level and experience
+// sample of a character, oldest first.
+func (r *Repository) GetExperienceSamplesByCharacter(characterId string) ([]models.ExperienceSample, error) {
+	query := `
+	SELECT id, character_id, league, level, experience, recorded_at
+	FROM experience_samples
+	WHERE character_id = ?
+	ORDER BY recorded_at ASC
+	`
+	rows, err := r.db.Query(query, characterId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []models.ExperienceSample
+	for rows.Next() {
+		var s models.ExperienceSample
+		err := rows.Scan(
+			&s.ID,
+			&s.CharacterId,
+			&s.League,
+			&s.Level,
+			&s.Experience,
+			&s.RecordedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+const idempotencyColumns = `api_key_id, key, method, path, request_hash, status, location, response_body, created_at, completed_at`
+
+type ReserveIdempotencyKeyParams struct {
+	APIKeyId    string
+	Key         string
+	Method      string
+	Path        string
+	RequestHash string
+}
+
+// ReserveIdempotencyKey claims an idempotency key for a request. When the
+// key was already used it returns the stored record and false instead.
+func (r *Repository) ReserveIdempotencyKey(arg ReserveIdempotencyKeyParams) (models.IdempotencyRecord, bool, error) {
+	query := `
+	INSERT OR IGNORE INTO idempotency_keys (api_key_id, key, method, path, request_hash, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now().UTC()
+	res, err := r.db.Exec(query, arg.APIKeyId, arg.Key, arg.Method, arg.Path, arg.RequestHash, now.Format(time.RFC3339))
+	if err != nil {
+		return models.IdempotencyRecord{}, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return models.IdempotencyRecord{}, false, err
+	}
+	if n > 0 {
+		return models.IdempotencyRecord{
+			APIKeyId:    arg.APIKeyId,
+			Key:         arg.Key,
+			Method:      arg.Method,
+			Path:        arg.Path,
+			RequestHash: arg.RequestHash,
+			CreatedAt:   now,
+		}, true, nil
+	}
+
+	existing := `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE api_key_id = ? AND key = ?`
+	rec, err := scanIdempotencyRecord(r.db.QueryRow(existing, arg.APIKeyId, arg.Key))
+	return rec, false, err
+}
+
+type CompleteIdempotencyKeyParams struct {
+	APIKeyId     string
+	Key          string
+	Status       int
+	Location     string
+	ResponseBody []byte
+}
+
+// CompleteIdempotencyKey stores the response of a reserved key.
+func (r *Repository) CompleteIdempotencyKey(arg CompleteIdempotencyKeyParams) error {
+	query := `
+	UPDATE idempotency_keys
+	SET status = ?, location = NULLIF(?, ''), response_body = ?, completed_at = ?
+	WHERE api_key_id = ? AND key = ?
+	`
+	_, err := r.db.Exec(query, arg.Status, arg.Location, arg.ResponseBody, time.Now().UTC().Format(time.RFC3339), arg.APIKeyId, arg.Key)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved key, so the request can be
+// retried after a failure.
+func (r *Repository) ReleaseIdempotencyKey(apiKeyId string, key string) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE api_key_id = ? AND key = ?`, apiKeyId, key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys forgets the keys used before a time.
+func (r *Repository) DeleteExpiredIdempotencyKeys(before time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < ?`, before.UTC().Format(time.RFC3339))
+	return err
+}
+
+func scanIdempotencyRecord(row rowScanner) (models.IdempotencyRecord, error) {
+	var rec models.IdempotencyRecord
+	err := row.Scan(
+		&rec.APIKeyId,
+		&rec.Key,
+		&rec.Method,
+		&rec.Path,
+		&rec.RequestHash,
+		&rec.Status,
+		&rec.Location,
+		&rec.ResponseBody,
+		&rec.CreatedAt,
+		&rec.CompletedAt,
+	)
+	if err != nil {
+		return models.IdempotencyRecord{}, err
+	}
+	return rec, nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/google/uuid"
+)
+
+const createLadderEntry = `
+INSERT INTO ladder_entries (id, character_id, league, realm, rank, level, experience, depth, dead, recorded_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateLadderEntryParams struct {
+	CharacterId string
+	League      string
+	Realm       string
+	Rank        int
+	Level       int
+	Experience  int64
+	Depth       *int
+	Dead        bool
+	RecordedAt  time.Time
+}
+
+func (r *Repository) CreateLadderEntry(arg CreateLadderEntryParams) error {
+	_, err := r.db.Exec(createLadderEntry,
+		uuid.New().String(),
+		arg.CharacterId,
+		arg.League,
+		arg.Realm,
+		arg.Rank,
+		arg.Level,
+		arg.Experience,
+		arg.Depth,
+		arg.Dead,
+		arg.RecordedAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// GetLadderEntriesByCharacter returns the ladder positions recorded for a
+// character, oldest first. When league is set only that league is returned.
+func (r *Repository) GetLadderEntriesByCharacter(characterId string, league string) ([]models.LadderEntry, error) {
+	query := `
+	SELECT id, character_id, league, realm, rank, level, experience, depth, dead, recorded_at
+	FROM ladder_entries
+	WHERE character_id = ?
+	AND (? = '' OR league = ?)
+	ORDER BY recorded_at ASC
+	`
+	rows, err := r.db.Query(query, characterId, league, league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LadderEntry
+	for rows.Next() {
+		var e models.LadderEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.CharacterId,
+			&e.League,
+			&e.Realm,
+			&e.Rank,
+			&e.Level,
+			&e.Experience,
+			&e.Depth,
+			&e.Dead,
+			&e.RecordedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// LadderCandidate is a stored character that can show up on a league's
+// ladder. Experience is the most it was seen with in that league, nil when
+// it was never sampled.
+type LadderCandidate struct {
+	CharacterId   string
+	AccountName   string
+	CharacterName string
+	Experience    *int64
+}
+
+// GetLadderCandidates returns the living characters of a realm currently
+// playing league.
+func (r *Repository) GetLadderCandidates(league string, realm string) ([]LadderCandidate, error) {
+	query := `
+	SELECT c.id, a.account_name, c.character_name, MAX(x.experience)
+	FROM characters c
+	INNER JOIN accounts a ON a.id = c.account_id
+	LEFT JOIN (
+		SELECT character_id, experience FROM experience_samples WHERE league = ?
+		UNION ALL
+		SELECT character_id, experience FROM ladder_entries WHERE league = ?
+	) x ON x.character_id = c.id
+	WHERE c.deleted_at IS NULL AND a.deleted_at IS NULL
+	AND NOT c.died AND c.current_league = ? AND c.realm = ?
+	GROUP BY c.id, a.account_name, c.character_name
+	`
+	rows, err := r.db.Query(query, league, league, league, realm)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []LadderCandidate
+	for rows.Next() {
+		var c LadderCandidate
+		if err := rows.Scan(&c.CharacterId, &c.AccountName, &c.CharacterName, &c.Experience); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/google/uuid"
+)
+
+const leagueColumns = `
+	id, name, realm, start_at, end_at, hardcore, ssf, parent_league_id, created_at, updated_at
+`
+
+// permanentLeagues are the leagues characters are moved to when a
+// challenge league ends.
+var permanentLeagues = map[string]bool{
+	"Standard":     true,
+	"Hardcore":     true,
+	"SSF Standard": true,
+	"SSF Hardcore": true,
+}
+
+// IsPermanentLeague reports whether a league never ends.
+func IsPermanentLeague(name string) bool {
+	return permanentLeagues[name]
+}
+
+// leagueTraits derives the hardcore and SSF flags and the parent league from
+// a league name, e.g. "SSF Settlers HC" is a hardcore SSF league whose
+// parent is "Settlers".
+func leagueTraits(name string) (hardcore bool, ssf bool, parent string) {
+	rest := name
+	if strings.HasPrefix(rest, "SSF ") {
+		ssf = true
+		rest = strings.TrimPrefix(rest, "SSF ")
+	}
+
+	switch {
+	case rest == "Hardcore":
+		hardcore = true
+		parent = "Standard"
+	case strings.HasPrefix(rest, "Hardcore "):
+		hardcore = true
+		parent = strings.TrimPrefix(rest, "Hardcore ")
+	case strings.HasSuffix(rest, " HC"):
+		hardcore = true
+		parent = strings.TrimSuffix(rest, " HC")
+	default:
+		parent = rest
+	}
+
+	if parent == name {
+		parent = ""
+	}
+	return hardcore, ssf, parent
+}
+
+func scanLeague(row rowScanner) (models.League, error) {
+	var l models.League
+	err := row.Scan(
+		&l.ID,
+		&l.Name,
+		&l.Realm,
+		&l.StartAt,
+		&l.EndAt,
+		&l.Hardcore,
+		&l.SSF,
+		&l.ParentLeagueId,
+		&l.CreatedAt,
+		&l.UpdatedAt,
+	)
+	return l, err
+}
+
+func (r *Repository) GetAllLeagues() ([]models.League, error) {
+	query := `SELECT ` + leagueColumns + `
+	FROM leagues
+	ORDER BY start_at IS NULL, start_at DESC, name ASC
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leagues []models.League
+	for rows.Next() {
+		l, err := scanLeague(rows)
+		if err != nil {
+			return nil, err
+		}
+		leagues = append(leagues, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return leagues, nil
+}
+
+func (r *Repository) GetLeagueByID(id string) (models.League, error) {
+	query := `SELECT ` + leagueColumns + `
+	FROM leagues
+	WHERE id = ?
+	`
+	l, err := scanLeague(r.db.QueryRow(query, id))
+	return l, mapError("league", err)
+}
+
+func (r *Repository) GetLeagueByName(name string, realm string) (models.League, error) {
+	query := `SELECT ` + leagueColumns + `
+	FROM leagues
+	WHERE name = ? AND realm = ?
+	`
+	return scanLeague(r.db.QueryRow(query, name, realm))
+}
+
+const createLeague = `
+INSERT INTO leagues (id, name, realm, hardcore, ssf, parent_league_id, created_at, updated_at)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// EnsureLeague returns the league with the given name, creating it and its
+// parent league when they are not known yet.
+func (r *Repository) EnsureLeague(name string, realm string) (models.League, error) {
+	l, err := r.GetLeagueByName(name, realm)
+	if err == nil {
+		return l, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.League{}, err
+	}
+
+	hardcore, ssf, parentName := leagueTraits(name)
+	var parentId *string
+	if parentName != "" {
+		parent, err := r.EnsureLeague(parentName, realm)
+		if err != nil {
+			return models.League{}, err
+		}
+		parentId = &parent.ID
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = r.db.Exec(createLeague, uuid.New().String(), name, realm, hardcore, ssf, parentId, now, now)
+	if err != nil {
+		return models.League{}, err
+	}
+	return r.GetLeagueByName(name, realm)
+}
+
+const updateLeagueDates = `
+UPDATE leagues
+SET start_at = ?,
+	end_at = ?,
+	updated_at = ?
+WHERE id = ?
+`
+
+type UpdateLeagueDatesParams struct {
+	ID      string
+	StartAt *time.Time
+	EndAt   *time.Time
+}
+
+func (r *Repository) UpdateLeagueDates(params UpdateLeagueDatesParams) error {
+	res, err := r.db.Exec(updateLeagueDates,
+		formatOptionalTime(params.StartAt),
+		formatOptionalTime(params.EndAt),
+		time.Now().UTC().Format(time.RFC3339),
+		params.ID,
+	)
+	return mapAffected("league", res, err)
+}
+
+// EndLeague sets the end date of a league unless it already has one.
+func (r *Repository) EndLeague(id string, at time.Time) error {
+	query := `
+	UPDATE leagues
+	SET end_at = ?, updated_at = ?
+	WHERE id = ? AND end_at IS NULL
+	`
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := r.db.Exec(query, at.UTC().Format(time.RFC3339), now, id)
+	return err
+}
+
+// SetCharacterLeague moves a character to another league.
+func (r *Repository) SetCharacterLeague(characterId string, league string) error {
+	query := `
+	UPDATE characters
+	SET current_league = ?, updated_at = ?
+	WHERE id = ?
+	`
+	_, err := r.db.Exec(query, league, time.Now().UTC().Format(time.RFC3339), characterId)
+	return err
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// InvalidSortError is returned when a list is asked to sort on a field it
+// doesn't support.
+type InvalidSortError struct {
+	Sort    string
+	Allowed []string
+}
+
+func (e InvalidSortError) Error() string {
+	return fmt.Sprintf("invalid sort %q, expected one of %s", e.Sort, strings.Join(e.Allowed, ", "))
+}
+
+// cursor is the position after the last row of a page: the value of the
+// sort column and the id, which breaks ties.
+type cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// listQuery is a filtered list of rows from table, paged with keyset
+// pagination on one of sorts. Sort expressions must be text, which holds
+// for names and the RFC3339 timestamps every table stores.
+type listQuery struct {
+	columns     string
+	table       string
+	where       []string
+	args        []any
+	sorts       map[string]string
+	defaultSort string
+}
+
+func (q *listQuery) filter(cond string, args ...any) {
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+}
+
+// filterTime keeps rows where column is on or after from and before to,
+// when they are set.
+func (q *listQuery) filterTime(column string, from *time.Time, to *time.Time) {
+	if from != nil {
+		q.filter(column+" >= ?", from.UTC().Format(time.RFC3339))
+	}
+	if to != nil {
+		q.filter(column+" < ?", to.UTC().Format(time.RFC3339))
+	}
+}
+
+// filterIn keeps rows where expr is one of values, when any are set.
+func (q *listQuery) filterIn(expr string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	list, args := inList(values)
+	q.filter(expr+" IN "+list, args...)
+}
+
+// inList is the placeholder list of an IN condition on values, which must
+// not be empty, and its arguments.
+func inList(values []string) (string, []any) {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return "(?" + strings.Repeat(", ?", len(values)-1) + ")", args
+}
+
+func (q *listQuery) whereClause(extra ...string) string {
+	conds := append(append([]string{}, q.where...), extra...)
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// paginate runs a listQuery and returns one page of it with the total row
+// count. scan reads the columns of one row.
+func paginate[T any](db dbtx, q listQuery, page models.PageParams, scan func(rowScanner) (T, error)) (models.Page[T], error) {
+	sortKey := page.Sort
+	if sortKey == "" {
+		sortKey = q.defaultSort
+	}
+	sortExpr, ok := q.sorts[sortKey]
+	if !ok {
+		allowed := make([]string, 0, len(q.sorts))
+		for k := range q.sorts {
+			allowed = append(allowed, k)
+		}
+		sort.Strings(allowed)
+		return models.Page[T]{}, InvalidSortError{Sort: sortKey, Allowed: allowed}
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = models.DefaultPageSize
+	}
+	if limit > models.MaxPageSize {
+		limit = models.MaxPageSize
+	}
+
+	result := models.Page[T]{Items: []T{}}
+	countQuery := `SELECT COUNT(*) FROM ` + q.table + q.whereClause()
+	if err := db.QueryRow(countQuery, q.args...).Scan(&result.Total); err != nil {
+		return models.Page[T]{}, err
+	}
+
+	args := append([]any{}, q.args...)
+	var after []string
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return models.Page[T]{}, err
+		}
+		if c.Sort != sortKey || c.Desc != page.Desc {
+			return models.Page[T]{}, fmt.Errorf("%w: it was made for another sort", ErrInvalidCursor)
+		}
+		op := ">"
+		if page.Desc {
+			op = "<"
+		}
+		after = append(after, fmt.Sprintf("(%s, id) %s (?, ?)", sortExpr, op))
+		args = append(args, c.Value, c.ID)
+	}
+
+	dir := "ASC"
+	if page.Desc {
+		dir = "DESC"
+	}
+	query := fmt.Sprintf(`SELECT %s, CAST(%s AS TEXT), id FROM %s%s ORDER BY %s %s, id %s LIMIT ?`,
+		q.columns, sortExpr, q.table, q.whereClause(after...), sortExpr, dir, dir)
+	args = append(args, limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return models.Page[T]{}, err
+	}
+	defer rows.Close()
+
+	var last cursor
+	for rows.Next() {
+		if len(result.Items) == limit {
+			result.NextCursor = encodeCursor(last)
+			break
+		}
+		var value sql.NullString
+		last = cursor{Sort: sortKey, Desc: page.Desc}
+		item, err := scan(pageScanner{rows: rows, extra: []any{&value, &last.ID}})
+		if err != nil {
+			return models.Page[T]{}, err
+		}
+		last.Value = value.String
+		result.Items = append(result.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Page[T]{}, err
+	}
+	return result, nil
+}
+
+// pageScanner appends the cursor columns paginate selects to the ones scan
+// functions ask for.
+type pageScanner struct {
+	rows  *sql.Rows
+	extra []any
+}
+
+func (s pageScanner) Scan(dest ...any) error {
+	return s.rows.Scan(append(dest, s.extra...)...)
+}
+
+// IsInvalidPage reports whether err comes from a bad cursor or sort, which
+// is the caller's fault rather than the database's.
+func IsInvalidPage(err error) bool {
+	var sortErr InvalidSortError
+	return errors.Is(err, ErrInvalidCursor) || errors.As(err, &sortErr)
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// walk follows NextCursor from the first page to the last and returns the
+// ids of every row in the order they were listed.
+func walk[T any](t *testing.T, list func(models.PageParams) (models.Page[T], error), params models.PageParams, id func(T) string) []string {
+	t.Helper()
+	var ids []string
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("NextCursor never ran out")
+		}
+		page, err := list(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) > params.Limit {
+			t.Fatalf("page of %d rows, limit is %d", len(page.Items), params.Limit)
+		}
+		for _, item := range page.Items {
+			ids = append(ids, id(item))
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		params.Cursor = page.NextCursor
+	}
+}
+
+// row is a listed row with the value it sorts on for one sort.
+type row struct {
+	id  string
+	key string
+}
+
+// expectedOrder sorts rows like paginate's ORDER BY: by key, ties by id,
+// both reversed when desc.
+func expectedOrder(rows []row, desc bool) []string {
+	sorted := append([]row{}, rows...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if desc {
+			a, b = b, a
+		}
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.id < b.id
+	})
+	ids := make([]string, len(sorted))
+	for i, r := range sorted {
+		ids[i] = r.id
+	}
+	return ids
+}
+
+func TestPaginateFollowsCursors(t *testing.T) {
+	repo, db := newTestRepository(t, "")
+	const (
+		t1 = "2025-01-01T00:00:00Z"
+		t2 = "2025-01-02T00:00:00Z"
+		t3 = "2025-01-03T00:00:00Z"
+	)
+	// Ids don't follow any sort, names differ only by case and timestamps
+	// repeat so ties have to be broken by id.
+	accounts := []struct {
+		id, name, created, updated string
+	}{
+		{"a5", "alice", t1, t2},
+		{"a2", "Alice", t1, t1},
+		{"a9", "bob", t2, t1},
+		{"a1", "BOB", t1, t3},
+		{"a7", "carol", t3, t3},
+		{"a3", "Dave", t2, t2},
+		{"a8", "dave", t3, t1},
+		{"a4", "erin", t1, t2},
+		{"a6", "Frank", t2, t3},
+	}
+	characters := []struct {
+		id, account, name string
+		league            *string
+		created, updated  string
+	}{
+		{"c4", "a1", "mage", nil, t1, t2},
+		{"c8", "a1", "Mage", ptr("Settlers"), t2, t2},
+		{"c1", "a2", "witch", ptr("Standard"), t1, t1},
+		{"c6", "a2", "Witch", nil, t3, t1},
+		{"c3", "a3", "ranger", ptr("Settlers"), t1, t3},
+		{"c9", "a3", "Duelist", ptr("settlers"), t2, t2},
+		{"c2", "a4", "duelist", nil, t3, t3},
+		{"c7", "a5", "Templar", ptr("Standard"), t1, t1},
+		{"c5", "a6", "shadow", ptr("Settlers"), t2, t1},
+	}
+
+	for _, a := range accounts {
+		exec(t, db, `INSERT INTO accounts (id, account_name, realm, created_at, updated_at) VALUES (?, ?, 'pc', ?, ?)`,
+			a.id, a.name, a.created, a.updated)
+	}
+	exec(t, db, `INSERT INTO accounts (id, account_name, realm, created_at, updated_at, deleted_at) VALUES ('a0', 'gone', 'pc', ?, ?, ?)`, t1, t1, t2)
+	for _, c := range characters {
+		exec(t, db, `INSERT INTO characters (id, account_id, character_name, died, current_league, realm, created_at, updated_at) VALUES (?, ?, ?, false, ?, 'pc', ?, ?)`,
+			c.id, c.account, c.name, c.league, c.created, c.updated)
+	}
+	for i, created := range []string{t2, t1, t3, t1, t2, t2, t1} {
+		exec(t, db, `INSERT INTO pobsnapshots (id, character_id, export_string, created_at, updated_at) VALUES (?, 'c4', 'A', ?, ?)`,
+			fmt.Sprintf("s%d", (i*3)%7), created, created)
+	}
+	exec(t, db, `INSERT INTO pobsnapshots (id, character_id, export_string, created_at, updated_at) VALUES ('other', 'c8', 'A', ?, ?)`, t1, t1)
+
+	accountRows := func(sortKey string) []row {
+		var rows []row
+		for _, a := range accounts {
+			key := map[string]string{"name": strings.ToLower(a.name), "created_at": a.created, "updated_at": a.updated}[sortKey]
+			rows = append(rows, row{a.id, key})
+		}
+		return rows
+	}
+	characterRows := func(sortKey string) []row {
+		var rows []row
+		for _, c := range characters {
+			league := ""
+			if c.league != nil {
+				league = *c.league
+			}
+			key := map[string]string{"name": strings.ToLower(c.name), "league": league, "created_at": c.created, "updated_at": c.updated}[sortKey]
+			rows = append(rows, row{c.id, key})
+		}
+		return rows
+	}
+	snapshotRows := func(sortKey string) []row {
+		rows, err := db.Query(`SELECT id, created_at FROM pobsnapshots WHERE character_id = 'c4'`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var all []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.key); err != nil {
+				t.Fatal(err)
+			}
+			all = append(all, r)
+		}
+		return all
+	}
+
+	lists := []struct {
+		name  string
+		sorts map[string]string
+		rows  func(sortKey string) []row
+		walk  func(t *testing.T, params models.PageParams) []string
+	}{
+		{"accounts", accountSorts, accountRows, func(t *testing.T, params models.PageParams) []string {
+			return walk(t, func(p models.PageParams) (models.Page[models.Account], error) {
+				return repo.ListAccounts(ListAccountsParams{PageParams: p})
+			}, params, func(a models.Account) string { return a.ID })
+		}},
+		{"characters", characterSorts, characterRows, func(t *testing.T, params models.PageParams) []string {
+			return walk(t, func(p models.PageParams) (models.Page[models.Character], error) {
+				return repo.ListCharacters(ListCharactersParams{PageParams: p})
+			}, params, func(c models.Character) string { return c.ID })
+		}},
+		{"snapshots", snapshotSorts, snapshotRows, func(t *testing.T, params models.PageParams) []string {
+			return walk(t, func(p models.PageParams) (models.Page[models.POBSnapshot], error) {
+				return repo.ListSnapshots(ListSnapshotsParams{PageParams: p, CharacterId: "c4"})
+			}, params, func(s models.POBSnapshot) string { return s.ID })
+		}},
+	}
+	for _, list := range lists {
+		for sortKey := range list.sorts {
+			for _, desc := range []bool{false, true} {
+				want := expectedOrder(list.rows(sortKey), desc)
+				for _, limit := range []int{1, 2, 4, len(want)} {
+					name := fmt.Sprintf("%s by %s desc=%v limit=%d", list.name, sortKey, desc, limit)
+					t.Run(name, func(t *testing.T) {
+						got := list.walk(t, models.PageParams{Limit: limit, Sort: sortKey, Desc: desc})
+						if !reflect.DeepEqual(got, want) {
+							t.Errorf("listed %v, want %v", got, want)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
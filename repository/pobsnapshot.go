@@ -8,77 +8,74 @@ import (
 )
 
 const createPobSnapshot = `
-INSERT INTO pobsnapshots (id, character_id, export_string, created_at, updated_at)
-	VALUES(?, ?, ?, ?, ?)
+INSERT INTO pobsnapshots (id, character_id, export_string, league, created_at, updated_at)
+	VALUES(?, ?, ?, ?, ?, ?)
 `
 
 type CreatePoBSnapshotParams struct {
 	CharacterId  string
 	ExportString string
+	League       string
+	Stats        *models.SnapshotStats
+	ItemsJson    []byte
+	PassivesJson []byte
 }
 
-func (r *Repository) CreatePOBSnapshot(params CreatePoBSnapshotParams) error {
+// CreatePOBSnapshot stores a snapshot with its stats and raw data in one
+// transaction and returns it as persisted.
+func (r *Repository) CreatePOBSnapshot(params CreatePoBSnapshotParams) (models.POBSnapshot, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
 	idString := uuid.New().String()
 
-	_, err := r.db.Exec(createPobSnapshot,
+	tx, err := r.begin()
+	if err != nil {
+		return models.POBSnapshot{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(createPobSnapshot,
 		idString,
 		params.CharacterId,
 		params.ExportString,
+		params.League,
 		now,
 		now,
 	)
-	return err
-}
-
-const getSnapshotsByCharacterWithExtras = `
-	SELECT p.id, p.export_string, c.character_name, a.account_name, p.created_at  
-	FROM pobsnapshots p
-	INNER JOIN characters c on c.id = p.character_id
-	INNER JOIN accounts a on a.id = c.account_id
-	WHERE p.character_id = ?
-	ORDER BY p.created_at DESC
-`
-
-type GetSnapshotsByCharacterWithExtras struct {
-	CharacterId string
-}
-
-func (r *Repository) GetSnapshotsByCharacterWithExtras(params GetSnapshotsByCharacterWithExtras) ([]models.SnapshotWithExtras, error) {
-	rows, err := r.db.Query(getSnapshotsByCharacterWithExtras, params.CharacterId)
 	if err != nil {
-		return nil, err
+		return models.POBSnapshot{}, mapError("snapshot", err)
 	}
-	defer rows.Close()
 
-	var swe []models.SnapshotWithExtras
-	for rows.Next() {
+	if params.Stats != nil {
+		stats := *params.Stats
+		stats.SnapshotId = idString
+		if err := createSnapshotStatsTx(tx, stats, now); err != nil {
+			return models.POBSnapshot{}, err
+		}
+	}
 
-		var s models.SnapshotWithExtras
-		err := rows.Scan(
-			&s.SnapshotData.ID,
-			&s.SnapshotData.ExportString,
-			&s.CharacterName,
-			&s.AccountName,
-			&s.SnapshotData.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
+	if params.ItemsJson != nil && params.PassivesJson != nil {
+		if err := createSnapshotDataTx(tx, idString, params.ItemsJson, params.PassivesJson, now); err != nil {
+			return models.POBSnapshot{}, err
 		}
-		swe = append(swe, s)
 	}
 
-	return swe, nil
+	if err := tx.Commit(); err != nil {
+		return models.POBSnapshot{}, err
+	}
+	return r.GetSnapshotByID(idString)
 }
 
-func (r *Repository) GetSnapshotsByCharacter(characterId string) ([]models.POBSnapshot, error) {
+// GetSnapshotsByCharacter returns the snapshots of a character, or only the
+// ones taken in league when it is set.
+func (r *Repository) GetSnapshotsByCharacter(characterId string, league string) ([]models.POBSnapshot, error) {
 	query := `
-	SELECT id, character_id, export_string, created_at, updated_at, deleted_at
+	SELECT id, character_id, export_string, league, created_at, updated_at, deleted_at
 	FROM pobsnapshots
 	WHERE character_id = ?
+	AND (? = '' OR league = ?)
 	ORDER BY created_at ASC
 	`
-	rows, err := r.db.Query(query, characterId)
+	rows, err := r.db.Query(query, characterId, league, league)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +88,7 @@ func (r *Repository) GetSnapshotsByCharacter(characterId string) ([]models.POBSn
 			&s.ID,
 			&s.CharacterId,
 			&s.ExportString,
+			&s.League,
 			&s.CreatedAt,
 			&s.UpdatedAt,
 			&s.DeletedAt,
@@ -108,7 +106,7 @@ func (r *Repository) GetSnapshotsByCharacter(characterId string) ([]models.POBSn
 
 func (r *Repository) GetLatestSnapshotByCharacter(characterId string) (models.POBSnapshot, error) {
 	query := `
-	SELECT id, character_id, export_string, created_at, updated_at, deleted_at
+	SELECT id, character_id, export_string, league, created_at, updated_at, deleted_at
 	FROM pobsnapshots 
 	WHERE character_id = ?
 	ORDER BY created_at DESC
@@ -119,19 +117,20 @@ func (r *Repository) GetLatestSnapshotByCharacter(characterId string) (models.PO
 		&s.ID,
 		&s.CharacterId,
 		&s.ExportString,
+		&s.League,
 		&s.CreatedAt,
 		&s.UpdatedAt,
 		&s.DeletedAt,
 	)
 	if err != nil {
-		return models.POBSnapshot{}, err
+		return models.POBSnapshot{}, mapError("snapshot", err)
 	}
 	return s, nil
 }
 
 func (r *Repository) GetSnapshotByID(id string) (models.POBSnapshot, error) {
 	query := `
-	SELECT id, character_id, export_string, created_at, updated_at, deleted_at
+	SELECT id, character_id, export_string, league, created_at, updated_at, deleted_at
 	FROM pobsnapshots
 	WHERE id = ?
 	`
@@ -140,12 +139,56 @@ func (r *Repository) GetSnapshotByID(id string) (models.POBSnapshot, error) {
 		&s.ID,
 		&s.CharacterId,
 		&s.ExportString,
+		&s.League,
 		&s.CreatedAt,
 		&s.UpdatedAt,
 		&s.DeletedAt,
 	)
 	if err != nil {
-		return models.POBSnapshot{}, err
+		return models.POBSnapshot{}, mapError("snapshot", err)
 	}
 	return s, nil
 }
+
+var snapshotSorts = map[string]string{
+	"created_at": "created_at",
+}
+
+type ListSnapshotsParams struct {
+	models.PageParams
+	CharacterId   string
+	League        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// ListSnapshots returns one page of the snapshots of a character, oldest
+// first unless Desc is set.
+func (r *Repository) ListSnapshots(arg ListSnapshotsParams) (models.Page[models.POBSnapshot], error) {
+	q := listQuery{
+		columns:     `id, character_id, export_string, league, created_at, updated_at, deleted_at`,
+		table:       `pobsnapshots`,
+		sorts:       snapshotSorts,
+		defaultSort: "created_at",
+	}
+	q.filter("character_id = ?", arg.CharacterId)
+	q.filter("deleted_at IS NULL")
+	if arg.League != "" {
+		q.filter("league = ?", arg.League)
+	}
+	q.filterTime("created_at", arg.CreatedAfter, arg.CreatedBefore)
+
+	return paginate(r.db, q, arg.PageParams, func(row rowScanner) (models.POBSnapshot, error) {
+		var s models.POBSnapshot
+		err := row.Scan(
+			&s.ID,
+			&s.CharacterId,
+			&s.ExportString,
+			&s.League,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+			&s.DeletedAt,
+		)
+		return s, err
+	})
+}
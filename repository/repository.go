@@ -1,13 +1,49 @@
 package repository
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+)
+
+// dbtx is what queries run on, the database or a transaction.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
 
 type Repository struct {
-	db *sql.DB
+	db dbtx
+	// conn is nil for repositories running inside a transaction.
+	conn *sql.DB
 }
 
 func NewRepository(db *sql.DB) *Repository {
 	return &Repository{
-		db: db,
+		db:   db,
+		conn: db,
+	}
+}
+
+// InTx runs fn with a repository whose queries all belong to one
+// transaction, committed when fn returns nil and rolled back otherwise. fn
+// must only use the repository it is given.
+func (r *Repository) InTx(fn func(tx *Repository) error) error {
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Repository{db: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) begin() (*sql.Tx, error) {
+	if r.conn == nil {
+		return nil, errors.New("already in a transaction")
 	}
+	return r.conn.Begin()
 }
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestRepository opens an in-memory database migrated up to version,
+// every migration when version is empty.
+func newTestRepository(t *testing.T, version string) (*Repository, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrate(t, db, "", version)
+	return NewRepository(db), db
+}
+
+// migrate runs the Up part of the migrations after version from, up to
+// version to, on db. Empty versions leave that end open.
+func migrate(t *testing.T, db *sql.DB, from string, to string) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		name := filepath.Base(file)
+		version, _, _ := strings.Cut(name, "_")
+		if from != "" && version <= from {
+			continue
+		}
+		if to != "" && version > to {
+			break
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("migration %s: %v", name, err)
+		}
+	}
+}
+
+func exec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func count(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// GetRoster lists every account with one entry per character, accounts
+// without characters getting an entry of their own. When realm is set only
+// accounts of that realm are listed.
+func (r *Repository) GetRoster(realm string) ([]models.RosterEntry, error) {
+	query := `
+	SELECT a.account_name, a.realm, a.player, c.character_name, c.current_league,
+		f.id IS NOT NULL, COALESCE(f.should_skip, false)
+	FROM accounts a
+	LEFT JOIN characters c ON c.account_id = a.id AND c.deleted_at IS NULL
+	LEFT JOIN characters_to_fetch f ON f.character_id = c.id
+	WHERE a.deleted_at IS NULL
+	AND (? = '' OR a.realm = ?)
+	ORDER BY a.account_name COLLATE NOCASE, a.realm, c.character_name COLLATE NOCASE
+	`
+
+	rows, err := r.db.Query(query, realm, realm)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.RosterEntry{}
+	for rows.Next() {
+		var (
+			e               models.RosterEntry
+			player          sql.NullString
+			characterName   sql.NullString
+			league          sql.NullString
+			tracked, paused bool
+		)
+		if err := rows.Scan(&e.AccountName, &e.Realm, &player, &characterName, &league, &tracked, &paused); err != nil {
+			return nil, err
+		}
+		e.Player = player.String
+		if characterName.Valid {
+			e.CharacterName = characterName.String
+			e.League = league.String
+			e.Tracked = &tracked
+			e.Paused = &paused
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+const createSnapshotData = `
+INSERT INTO snapshot_data (snapshot_id, items_json, passives_json, created_at)
+	VALUES(?, ?, ?, ?)
+`
+
+func createSnapshotDataTx(tx *sql.Tx, snapshotId string, items []byte, passives []byte, now string) error {
+	_, err := tx.Exec(createSnapshotData, snapshotId, string(items), string(passives), now)
+	return err
+}
+
+func scanSnapshotData(row rowScanner) (models.SnapshotData, error) {
+	var d models.SnapshotData
+	var items, passives string
+	if err := row.Scan(&d.SnapshotId, &d.CharacterId, &items, &passives, &d.CreatedAt); err != nil {
+		return models.SnapshotData{}, err
+	}
+	if err := json.Unmarshal([]byte(items), &d.Items); err != nil {
+		return models.SnapshotData{}, err
+	}
+	if err := json.Unmarshal([]byte(passives), &d.Passives); err != nil {
+		return models.SnapshotData{}, err
+	}
+	return d, nil
+}
+
+func (r *Repository) GetSnapshotData(snapshotId string) (models.SnapshotData, error) {
+	query := `
+	SELECT d.snapshot_id, p.character_id, d.items_json, d.passives_json, p.created_at
+	FROM snapshot_data d
+	INNER JOIN pobsnapshots p ON p.id = d.snapshot_id
+	WHERE d.snapshot_id = ?
+	`
+	d, err := scanSnapshotData(r.db.QueryRow(query, snapshotId))
+	return d, mapError("snapshot data", err)
+}
+
+// GetSnapshotDataByCharacter returns the raw data of every snapshot of a
+// character, oldest first.
+func (r *Repository) GetSnapshotDataByCharacter(characterId string) ([]models.SnapshotData, error) {
+	query := `
+	SELECT d.snapshot_id, p.character_id, d.items_json, d.passives_json, p.created_at
+	FROM snapshot_data d
+	INNER JOIN pobsnapshots p ON p.id = d.snapshot_id
+	WHERE p.character_id = ? AND p.deleted_at IS NULL
+	ORDER BY p.created_at ASC
+	`
+	return querySnapshotData(r.db.Query(query, characterId))
+}
+
+// recentSnapshotIds selects the limit newest snapshots with data of tracked
+// characters, plus the latest one of every character. It takes the limit as
+// its only argument.
+const recentSnapshotIds = `(
+	SELECT p.id FROM pobsnapshots p
+	INNER JOIN characters c ON c.id = p.character_id
+	WHERE p.deleted_at IS NULL AND c.deleted_at IS NULL
+	AND p.id IN (SELECT snapshot_id FROM snapshot_data)
+	AND (
+		p.id IN (
+			SELECT rp.id FROM snapshot_data rd
+			INNER JOIN pobsnapshots rp ON rp.id = rd.snapshot_id
+			INNER JOIN characters rc ON rc.id = rp.character_id
+			WHERE rp.deleted_at IS NULL AND rc.deleted_at IS NULL
+			ORDER BY rp.created_at DESC
+			LIMIT ?
+		)
+		OR p.created_at = (
+			SELECT MAX(lp.created_at) FROM snapshot_data ld
+			INNER JOIN pobsnapshots lp ON lp.id = ld.snapshot_id
+			WHERE lp.character_id = p.character_id AND lp.deleted_at IS NULL
+		)
+	)
+)`
+
+// GetRecentSnapshotData returns the raw data of the limit newest snapshots
+// of tracked characters, plus the latest snapshot of every one of them so
+// each character is still seen, grouped by character and oldest first.
+// Reports read it rather than every snapshot, whose data is large to decode.
+func (r *Repository) GetRecentSnapshotData(limit int) ([]models.SnapshotData, error) {
+	query := `
+	SELECT d.snapshot_id, p.character_id, d.items_json, d.passives_json, p.created_at
+	FROM snapshot_data d
+	INNER JOIN pobsnapshots p ON p.id = d.snapshot_id
+	WHERE p.id IN ` + recentSnapshotIds + `
+	ORDER BY p.character_id, p.created_at ASC
+	`
+	return querySnapshotData(r.db.Query(query, limit))
+}
+
+func querySnapshotData(rows *sql.Rows, err error) ([]models.SnapshotData, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []models.SnapshotData
+	for rows.Next() {
+		d, err := scanSnapshotData(rows)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGetRecentSnapshotData(t *testing.T) {
+	repo, db := newTestRepository(t, "")
+	stmts := []string{
+		`INSERT INTO accounts (id, account_name, realm, created_at, updated_at) VALUES
+			('a1', 'Steel#1', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO characters (id, account_id, character_name, died, realm, created_at, updated_at, deleted_at) VALUES
+			('c1', 'a1', 'Mage', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z', NULL),
+			('c2', 'a1', 'Witch', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z', NULL),
+			('c3', 'a1', 'Gone', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z', '2025-03-01T00:00:00Z')`,
+		// c2 stopped playing early, c1's newest snapshot has no data and c3
+		// was deleted.
+		`INSERT INTO pobsnapshots (id, character_id, export_string, created_at, updated_at, deleted_at) VALUES
+			('s1', 'c1', 'A', '2025-02-01T00:00:00Z', '2025-02-01T00:00:00Z', NULL),
+			('s2', 'c1', 'B', '2025-02-02T00:00:00Z', '2025-02-02T00:00:00Z', NULL),
+			('s3', 'c1', 'C', '2025-02-03T00:00:00Z', '2025-02-03T00:00:00Z', NULL),
+			('s4', 'c1', 'D', '2025-02-04T00:00:00Z', '2025-02-04T00:00:00Z', NULL),
+			('s5', 'c2', 'E', '2025-01-10T00:00:00Z', '2025-01-10T00:00:00Z', NULL),
+			('s6', 'c2', 'F', '2025-01-11T00:00:00Z', '2025-01-11T00:00:00Z', NULL),
+			('s7', 'c3', 'G', '2025-02-05T00:00:00Z', '2025-02-05T00:00:00Z', NULL)`,
+		`INSERT INTO snapshot_data (snapshot_id, items_json, passives_json, created_at) VALUES
+			('s1', '{}', '{}', '2025-02-01T00:00:00Z'),
+			('s2', '{}', '{}', '2025-02-02T00:00:00Z'),
+			('s3', '{}', '{}', '2025-02-03T00:00:00Z'),
+			('s5', '{}', '{}', '2025-01-10T00:00:00Z'),
+			('s6', '{}', '{}', '2025-01-11T00:00:00Z'),
+			('s7', '{}', '{}', '2025-02-05T00:00:00Z')`,
+		`INSERT INTO snapshot_stats (snapshot_id, level, created_at)
+			SELECT id, 90, created_at FROM pobsnapshots`,
+	}
+	for _, stmt := range stmts {
+		exec(t, db, stmt)
+	}
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{1, []string{"s3", "s6"}},
+		{2, []string{"s2", "s3", "s6"}},
+		{10, []string{"s1", "s2", "s3", "s5", "s6"}},
+	}
+	for _, tt := range tests {
+		data, err := repo.GetRecentSnapshotData(tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, d := range data {
+			ids = append(ids, d.SnapshotId)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("GetRecentSnapshotData(%d) = %v, want %v", tt.limit, ids, tt.want)
+		}
+
+		stats, err := repo.GetRecentSnapshotStats(tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = nil
+		for _, s := range stats {
+			ids = append(ids, s.SnapshotId)
+		}
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("GetRecentSnapshotStats(%d) = %v, want %v", tt.limit, ids, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+const snapshotStatsColumns = `
+	s.snapshot_id, s.level, s.combined_dps, s.life, s.energy_shield, s.mana, s.total_ehp,
+	s.physical_max_hit, s.fire_max_hit, s.cold_max_hit, s.lightning_max_hit, s.chaos_max_hit,
+	s.fire_resist, s.cold_resist, s.lightning_resist, s.chaos_resist, s.movement_speed, s.created_at
+`
+
+const createSnapshotStats = `
+INSERT INTO snapshot_stats (
+	snapshot_id, level, combined_dps, life, energy_shield, mana, total_ehp,
+	physical_max_hit, fire_max_hit, cold_max_hit, lightning_max_hit, chaos_max_hit,
+	fire_resist, cold_resist, lightning_resist, chaos_resist, movement_speed, created_at
+)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// Stat is a stat accepted by the API, stored in Column of snapshot_stats.
+// Value reads it from a row, nil when PoB didn't calculate it.
+type Stat struct {
+	Column string
+	Value  func(models.SnapshotStats) *float64
+}
+
+// Stats are the stats accepted by the API, by name.
+var Stats = map[string]Stat{
+	"level": {"level", func(s models.SnapshotStats) *float64 {
+		v := float64(s.Level)
+		return &v
+	}},
+	"combined_dps":      {"combined_dps", func(s models.SnapshotStats) *float64 { return s.CombinedDPS }},
+	"life":              {"life", func(s models.SnapshotStats) *float64 { return s.Life }},
+	"energy_shield":     {"energy_shield", func(s models.SnapshotStats) *float64 { return s.EnergyShield }},
+	"mana":              {"mana", func(s models.SnapshotStats) *float64 { return s.Mana }},
+	"total_ehp":         {"total_ehp", func(s models.SnapshotStats) *float64 { return s.TotalEHP }},
+	"physical_max_hit":  {"physical_max_hit", func(s models.SnapshotStats) *float64 { return s.PhysicalMaxHit }},
+	"fire_max_hit":      {"fire_max_hit", func(s models.SnapshotStats) *float64 { return s.FireMaxHit }},
+	"cold_max_hit":      {"cold_max_hit", func(s models.SnapshotStats) *float64 { return s.ColdMaxHit }},
+	"lightning_max_hit": {"lightning_max_hit", func(s models.SnapshotStats) *float64 { return s.LightningMaxHit }},
+	"chaos_max_hit":     {"chaos_max_hit", func(s models.SnapshotStats) *float64 { return s.ChaosMaxHit }},
+	"fire_resist":       {"fire_resist", func(s models.SnapshotStats) *float64 { return s.FireResist }},
+	"cold_resist":       {"cold_resist", func(s models.SnapshotStats) *float64 { return s.ColdResist }},
+	"lightning_resist":  {"lightning_resist", func(s models.SnapshotStats) *float64 { return s.LightningResist }},
+	"chaos_resist":      {"chaos_resist", func(s models.SnapshotStats) *float64 { return s.ChaosResist }},
+	"movement_speed":    {"movement_speed", func(s models.SnapshotStats) *float64 { return s.MovementSpeed }},
+}
+
+// StatColumns maps the stat names accepted by the API to snapshot_stats columns.
+var StatColumns = statColumns()
+
+func statColumns() map[string]string {
+	columns := make(map[string]string, len(Stats))
+	for name, s := range Stats {
+		columns[name] = s.Column
+	}
+	return columns
+}
+
+// StatValue returns the value of a stat by its API name, or nil when the
+// stat is unknown or was not calculated.
+func StatValue(s models.SnapshotStats, stat string) *float64 {
+	st, ok := Stats[stat]
+	if !ok {
+		return nil
+	}
+	return st.Value(s)
+}
+
+func createSnapshotStatsTx(tx *sql.Tx, s models.SnapshotStats, now string) error {
+	_, err := tx.Exec(createSnapshotStats,
+		s.SnapshotId,
+		s.Level,
+		s.CombinedDPS,
+		s.Life,
+		s.EnergyShield,
+		s.Mana,
+		s.TotalEHP,
+		s.PhysicalMaxHit,
+		s.FireMaxHit,
+		s.ColdMaxHit,
+		s.LightningMaxHit,
+		s.ChaosMaxHit,
+		s.FireResist,
+		s.ColdResist,
+		s.LightningResist,
+		s.ChaosResist,
+		s.MovementSpeed,
+		now,
+	)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnapshotStats(row rowScanner) (models.SnapshotStats, error) {
+	var s models.SnapshotStats
+	err := row.Scan(
+		&s.SnapshotId,
+		&s.Level,
+		&s.CombinedDPS,
+		&s.Life,
+		&s.EnergyShield,
+		&s.Mana,
+		&s.TotalEHP,
+		&s.PhysicalMaxHit,
+		&s.FireMaxHit,
+		&s.ColdMaxHit,
+		&s.LightningMaxHit,
+		&s.ChaosMaxHit,
+		&s.FireResist,
+		&s.ColdResist,
+		&s.LightningResist,
+		&s.ChaosResist,
+		&s.MovementSpeed,
+		&s.CreatedAt,
+	)
+	return s, err
+}
+
+func (r *Repository) GetSnapshotStats(snapshotId string) (models.SnapshotStats, error) {
+	query := `SELECT ` + snapshotStatsColumns + `
+	FROM snapshot_stats s
+	WHERE s.snapshot_id = ?
+	`
+	s, err := scanSnapshotStats(r.db.QueryRow(query, snapshotId))
+	if err != nil {
+		return models.SnapshotStats{}, mapError("snapshot stats", err)
+	}
+	return s, nil
+}
+
+func (r *Repository) GetSnapshotStatsByCharacter(characterId string) ([]models.SnapshotStats, error) {
+	query := `SELECT ` + snapshotStatsColumns + `
+	FROM snapshot_stats s
+	INNER JOIN pobsnapshots p ON p.id = s.snapshot_id
+	WHERE p.character_id = ? AND p.deleted_at IS NULL
+	ORDER BY p.created_at ASC
+	`
+	return r.querySnapshotStats(query, characterId)
+}
+
+// GetRecentSnapshotStats returns the stats of the snapshots
+// GetRecentSnapshotData returns for the same limit.
+func (r *Repository) GetRecentSnapshotStats(limit int) ([]models.SnapshotStats, error) {
+	query := `SELECT ` + snapshotStatsColumns + `
+	FROM snapshot_stats s
+	INNER JOIN pobsnapshots p ON p.id = s.snapshot_id
+	WHERE p.id IN ` + recentSnapshotIds + `
+	ORDER BY p.created_at ASC
+	`
+	return r.querySnapshotStats(query, limit)
+}
+
+type GetStatDropsParams struct {
+	CharacterId string
+	Stat        string
+}
+
+// GetStatDrops returns the stats of every snapshot where the given stat is
+// lower than in the previous snapshot of the same character.
+func (r *Repository) GetStatDrops(params GetStatDropsParams) ([]models.SnapshotStats, error) {
+	column, ok := StatColumns[params.Stat]
+	if !ok {
+		return nil, fmt.Errorf("unknown stat '%s'", params.Stat)
+	}
+
+	query := `
+	WITH ordered AS (
+		SELECT s.*, LAG(s.` + column + `) OVER (ORDER BY p.created_at) AS previous_value, p.created_at AS taken_at
+		FROM snapshot_stats s
+		INNER JOIN pobsnapshots p ON p.id = s.snapshot_id
+		WHERE p.character_id = ? AND p.deleted_at IS NULL
+	)
+	SELECT ` + snapshotStatsColumns + `
+	FROM ordered s
+	WHERE s.previous_value IS NOT NULL AND s.` + column + ` < s.previous_value
+	ORDER BY s.taken_at ASC
+	`
+	return r.querySnapshotStats(query, params.CharacterId)
+}
+
+func (r *Repository) querySnapshotStats(query string, args ...any) ([]models.SnapshotStats, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.SnapshotStats
+	for rows.Next() {
+		s, err := scanSnapshotStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
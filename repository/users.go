@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/google/uuid"
+)
+
+const userColumns = `id, username, password_hash, created_at, updated_at`
+
+func (r *Repository) CreateUser(username string, passwordHash string) (models.User, error) {
+	query := `
+	INSERT INTO users (id, username, password_hash, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	`
+	now := time.Now().UTC()
+	user := models.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	_, err := r.db.Exec(query, user.ID, user.Username, user.PasswordHash, now.Format(time.RFC3339), now.Format(time.RFC3339))
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// GetUserByUsername looks a user up ignoring case.
+func (r *Repository) GetUserByUsername(username string) (models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ?`
+	return scanUser(r.db.QueryRow(query, username))
+}
+
+func (r *Repository) GetUserByID(id string) (models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	return scanUser(r.db.QueryRow(query, id))
+}
+
+type CreateSessionParams struct {
+	TokenHash string
+	UserId    string
+	ExpiresAt time.Time
+}
+
+func (r *Repository) CreateSession(arg CreateSessionParams) error {
+	query := `
+	INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+	VALUES (?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		arg.TokenHash,
+		arg.UserId,
+		time.Now().UTC().Format(time.RFC3339),
+		arg.ExpiresAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// GetUserBySession returns the user owning a session that hasn't expired.
+func (r *Repository) GetUserBySession(tokenHash string) (models.User, error) {
+	query := `
+	SELECT u.id, u.username, u.password_hash, u.created_at, u.updated_at
+	FROM sessions s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE s.token_hash = ? AND s.expires_at > ?
+	`
+	return scanUser(r.db.QueryRow(query, tokenHash, time.Now().UTC().Format(time.RFC3339)))
+}
+
+func (r *Repository) DeleteSession(tokenHash string) error {
+	_, err := r.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+// DeleteExpiredSessions removes sessions past their expiry date.
+func (r *Repository) DeleteExpiredSessions() error {
+	_, err := r.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return models.User{}, err
+	}
+	return u, nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/google/uuid"
+)
+
+type WatchParams struct {
+	UserId      string
+	AccountId   string
+	CharacterId *string
+}
+
+// AddToWatchlist adds an account, or a single character of it when
+// CharacterId is set, to a user's watchlist. Watching something twice is a
+// no-op.
+func (r *Repository) AddToWatchlist(arg WatchParams) error {
+	query := `
+	INSERT OR IGNORE INTO watchlist_entries (id, user_id, account_id, character_id, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		uuid.New().String(),
+		arg.UserId,
+		arg.AccountId,
+		arg.CharacterId,
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+func (r *Repository) RemoveFromWatchlist(arg WatchParams) error {
+	query := `
+	DELETE FROM watchlist_entries
+	WHERE user_id = ? AND account_id = ? AND COALESCE(character_id, '') = COALESCE(?, '')
+	`
+	_, err := r.db.Exec(query, arg.UserId, arg.AccountId, arg.CharacterId)
+	return err
+}
+
+// GetWatchlist returns everything a user watches, newest first.
+func (r *Repository) GetWatchlist(userId string) ([]models.WatchlistEntry, error) {
+	query := `
+	SELECT w.id, w.user_id, w.account_id, a.account_name, w.character_id, c.character_name, w.created_at
+	FROM watchlist_entries w
+	INNER JOIN accounts a ON a.id = w.account_id
+	LEFT JOIN characters c ON c.id = w.character_id
+	WHERE w.user_id = ?
+	ORDER BY w.created_at DESC
+	`
+	rows, err := r.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.WatchlistEntry
+	for rows.Next() {
+		var e models.WatchlistEntry
+		err := rows.Scan(&e.ID, &e.UserId, &e.AccountId, &e.AccountName, &e.CharacterId, &e.CharacterName, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// GetWatchedIDs returns the account and character ids a user watches, for
+// rendering watch toggles.
+func (r *Repository) GetWatchedIDs(userId string) (accounts map[string]bool, characters map[string]bool, err error) {
+	rows, err := r.db.Query(`SELECT account_id, character_id FROM watchlist_entries WHERE user_id = ?`, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	accounts = map[string]bool{}
+	characters = map[string]bool{}
+	for rows.Next() {
+		var accountId string
+		var characterId *string
+		if err := rows.Scan(&accountId, &characterId); err != nil {
+			return nil, nil, err
+		}
+		if characterId == nil {
+			accounts[accountId] = true
+		} else {
+			characters[*characterId] = true
+		}
+	}
+	return accounts, characters, nil
+}
+
+// CountWatchedTracked counts the characters a user watches, directly or
+// through their account, that are enrolled for fetching.
+func (r *Repository) CountWatchedTracked(userId string) (int, error) {
+	query := `
+	SELECT COUNT(DISTINCT f.character_id)
+	FROM characters_to_fetch f
+	INNER JOIN characters c ON c.id = f.character_id
+	INNER JOIN watchlist_entries w ON w.account_id = c.account_id
+		AND (w.character_id IS NULL OR w.character_id = c.id)
+	WHERE w.user_id = ? AND c.deleted_at IS NULL
+	`
+	var n int
+	err := r.db.QueryRow(query, userId).Scan(&n)
+	return n, err
+}
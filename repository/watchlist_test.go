@@ -0,0 +1,41 @@
+package repository
+
+import "testing"
+
+func TestCountWatchedTracked(t *testing.T) {
+	repo, db := newTestRepository(t, "")
+	stmts := []string{
+		`INSERT INTO users (id, username, password_hash, created_at, updated_at) VALUES
+			('u1', 'alice', 'x', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('u2', 'bob', 'x', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO accounts (id, account_name, realm, created_at, updated_at) VALUES
+			('a1', 'Steel#1', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('a2', 'Zap#2', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO characters (id, account_id, character_name, died, realm, created_at, updated_at) VALUES
+			('c1', 'a1', 'Mage', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('c2', 'a1', 'Witch', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('c3', 'a2', 'Duelist', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+			('c4', 'a2', 'Ranger', false, 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO characters_to_fetch (id, character_id) VALUES ('f1', 'c1'), ('f2', 'c2'), ('f3', 'c3'), ('f4', 'c4')`,
+		// u1 watches all of a1, c1 once more on its own, and c3. u2 only
+		// watches c4.
+		`INSERT INTO watchlist_entries (id, user_id, account_id, character_id, created_at) VALUES
+			('w1', 'u1', 'a1', NULL, '2025-01-01T00:00:00Z'),
+			('w2', 'u1', 'a1', 'c1', '2025-01-01T00:00:00Z'),
+			('w3', 'u1', 'a2', 'c3', '2025-01-01T00:00:00Z'),
+			('w4', 'u2', 'a2', 'c4', '2025-01-01T00:00:00Z')`,
+	}
+	for _, stmt := range stmts {
+		exec(t, db, stmt)
+	}
+
+	for user, want := range map[string]int{"u1": 3, "u2": 1, "u3": 0} {
+		n, err := repo.CountWatchedTracked(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("CountWatchedTracked(%s) = %d, want %d", user, n, want)
+		}
+	}
+}
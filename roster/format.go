@@ -0,0 +1,218 @@
+package roster
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Columns are the CSV columns, in the order they are written. They are the
+// JSON field names of models.RosterEntry.
+var Columns = []string{"account_name", "realm", "player", "character_name", "league", "tracked", "paused"}
+
+// Document is the JSON form of a list.
+type Document struct {
+	Entries []models.RosterEntry `json:"entries"`
+}
+
+// ParseFormat checks format is json or csv, json when empty.
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("invalid format %q, expected json or csv", format)
+}
+
+// Read decodes a list in format.
+func Read(r io.Reader, format string) ([]Entry, error) {
+	if format == FormatCSV {
+		return ReadCSV(r)
+	}
+	return ReadJSON(r)
+}
+
+// Write encodes a list in format.
+func Write(w io.Writer, format string, entries []models.RosterEntry) error {
+	if format == FormatCSV {
+		return WriteCSV(w, entries)
+	}
+	return WriteJSON(w, entries)
+}
+
+// ReadJSON decodes a Document.
+func ReadJSON(r io.Reader) ([]Entry, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON list: %w", err)
+	}
+	entries := make([]Entry, len(doc.Entries))
+	for i, e := range doc.Entries {
+		entries[i] = Entry{RosterEntry: e}
+	}
+	return entries, nil
+}
+
+func WriteJSON(w io.Writer, entries []models.RosterEntry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Document{Entries: entries})
+}
+
+// ReadCSV decodes a CSV file whose first line names its columns, in any
+// order. Header names are matched loosely, so "Account Name" is
+// account_name. Only account_name is required. tracked and paused accept
+// true/false, yes/no or 1/0, and are left unset when empty.
+func ReadCSV(r io.Reader) ([]Entry, error) {
+	br := bufio.NewReader(r)
+	// Spreadsheets often save CSV with a byte order mark.
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.ReplaceAll(name, " ", "_")
+		if !isColumn(name) {
+			return nil, fmt.Errorf("line 1: unknown column %q, expected some of %s", header[i], strings.Join(Columns, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("line 1: column %q appears twice", name)
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	if !seen["account_name"] {
+		return nil, errors.New("line 1: the account_name column is required")
+	}
+
+	var entries []Entry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlank(record) {
+			continue
+		}
+		if len(record) > len(columns) {
+			return nil, fmt.Errorf("line %d: %d fields but only %d columns", line, len(record), len(columns))
+		}
+
+		e := Entry{Line: line}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch columns[i] {
+			case "account_name":
+				e.AccountName = value
+			case "realm":
+				e.Realm = value
+			case "player":
+				e.Player = value
+			case "character_name":
+				e.CharacterName = value
+			case "league":
+				e.League = value
+			case "tracked":
+				if e.Tracked, err = parseBool(value); err != nil {
+					return nil, fmt.Errorf("line %d: tracked: %w", line, err)
+				}
+			case "paused":
+				if e.Paused, err = parseBool(value); err != nil {
+					return nil, fmt.Errorf("line %d: paused: %w", line, err)
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func WriteCSV(w io.Writer, entries []models.RosterEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		record := []string{e.AccountName, e.Realm, e.Player, e.CharacterName, e.League, formatBool(e.Tracked), formatBool(e.Paused)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func isColumn(name string) bool {
+	for _, c := range Columns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseBool(value string) (*bool, error) {
+	var b bool
+	switch strings.ToLower(value) {
+	case "":
+		return nil, nil
+	case "true", "yes", "y", "1":
+		b = true
+	case "false", "no", "n", "0":
+		b = false
+	default:
+		return nil, fmt.Errorf("expected true or false, got %q", value)
+	}
+	return &b, nil
+}
+
+func formatBool(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	if *b {
+		return "true"
+	}
+	return "false"
+}
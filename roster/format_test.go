@@ -0,0 +1,173 @@
+package roster
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []Entry
+		wantErr string
+	}{
+		{
+			name: "columns in any order",
+			csv:  "character_name,account_name\nMage,Steel#1\n",
+			want: []Entry{{RosterEntry: models.RosterEntry{AccountName: "Steel#1", CharacterName: "Mage"}, Line: 2}},
+		},
+		{
+			name: "loose header names",
+			csv:  " Account Name ,PLAYER,Character Name\nSteel#1,Steel,Mage\n",
+			want: []Entry{{RosterEntry: models.RosterEntry{AccountName: "Steel#1", Player: "Steel", CharacterName: "Mage"}, Line: 2}},
+		},
+		{
+			name: "byte order mark",
+			csv:  "\xef\xbb\xbfaccount_name\nSteel#1\n",
+			want: []Entry{{RosterEntry: models.RosterEntry{AccountName: "Steel#1"}, Line: 2}},
+		},
+		{
+			name: "blank lines skipped, lines kept",
+			csv:  "account_name,realm\nSteel#1,pc\n,\n\nZiz#2,XBOX\n",
+			want: []Entry{
+				{RosterEntry: models.RosterEntry{AccountName: "Steel#1", Realm: "pc"}, Line: 2},
+				{RosterEntry: models.RosterEntry{AccountName: "Ziz#2", Realm: "XBOX"}, Line: 5},
+			},
+		},
+		{
+			name: "short lines",
+			csv:  "account_name,character_name,league\nSteel#1\n",
+			want: []Entry{{RosterEntry: models.RosterEntry{AccountName: "Steel#1"}, Line: 2}},
+		},
+		{name: "empty file", csv: "", wantErr: "empty"},
+		{name: "unknown column", csv: "account_name,level\nSteel#1,90\n", wantErr: `unknown column "level"`},
+		{name: "repeated column", csv: "account_name,Account Name\n", wantErr: "appears twice"},
+		{name: "missing account_name", csv: "character_name\nMage\n", wantErr: "account_name column is required"},
+		{name: "too many fields", csv: "account_name\nSteel#1,Mage\n", wantErr: "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCSV(strings.NewReader(tt.csv))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSVBools(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *bool
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"true", boolPtr(true), false},
+		{"Yes", boolPtr(true), false},
+		{"y", boolPtr(true), false},
+		{"1", boolPtr(true), false},
+		{"FALSE", boolPtr(false), false},
+		{"no", boolPtr(false), false},
+		{"n", boolPtr(false), false},
+		{"0", boolPtr(false), false},
+		{"maybe", nil, true},
+		{"2", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			entries, err := ReadCSV(strings.NewReader("account_name,tracked,paused\nSteel#1," + tt.value + "," + tt.value + "\n"))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "line 2: tracked") {
+					t.Fatalf("err = %v, want one naming line 2 and tracked", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := entries[0]
+			if !reflect.DeepEqual(e.Tracked, tt.want) || !reflect.DeepEqual(e.Paused, tt.want) {
+				t.Errorf("tracked, paused = %v, %v, want %v", e.Tracked, e.Paused, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	entries := []models.RosterEntry{
+		{AccountName: "Steel#1", Realm: "pc", Player: "Steel, the mage", CharacterName: "Mage", League: "Settlers", Tracked: boolPtr(true), Paused: boolPtr(false)},
+		{AccountName: "Ziz#2", Realm: "xbox"},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range got {
+		if !reflect.DeepEqual(e.RosterEntry, entries[i]) {
+			t.Errorf("entry %d = %+v, want %+v", i, e.RosterEntry, entries[i])
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	got, err := ReadJSON(strings.NewReader(`{"entries": [{"account_name": "Steel#1", "realm": "PC", "tracked": true}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{{RosterEntry: models.RosterEntry{AccountName: "Steel#1", Realm: "PC", Tracked: boolPtr(true)}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadJSON() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ReadJSON(strings.NewReader(`{"entries": [{"account": "Steel#1"}]}`)); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	entries := []Entry{
+		{RosterEntry: models.RosterEntry{AccountName: " Steel#1 ", Realm: " PC "}},
+		{RosterEntry: models.RosterEntry{AccountName: "Ziz#2", Realm: "Xbox", CharacterName: "Mage"}},
+		{RosterEntry: models.RosterEntry{AccountName: "", Realm: "mars", League: "Settlers", Tracked: boolPtr(true)}, Line: 4},
+		{RosterEntry: models.RosterEntry{AccountName: "Ziz#2", CharacterName: "Mage", Tracked: boolPtr(false), Paused: boolPtr(true)}},
+	}
+	errs := Validate(entries)
+
+	if entries[0].AccountName != "Steel#1" || entries[0].Realm != "pc" {
+		t.Errorf("entry 0 = %q in %q, want trimmed and lower cased", entries[0].AccountName, entries[0].Realm)
+	}
+	if entries[1].Realm != "xbox" {
+		t.Errorf("entry 1 realm = %q, want xbox", entries[1].Realm)
+	}
+
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	want := []string{"line 4.account_name", "line 4.realm", "line 4.league", "line 4.tracked", "entries[3].paused"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("invalid fields = %v, want %v", fields, want)
+	}
+}
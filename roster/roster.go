@@ -0,0 +1,224 @@
+// Package roster imports and exports the list of tracked players: accounts
+// with their friendly player names, their characters and whether those are
+// fetched. It is what a spreadsheet of players maps to, one line per
+// character, and reads and writes it as JSON or CSV.
+package roster
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/poeclient"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+)
+
+// Report counts what Import changed. Lines matching what is stored change
+// nothing, so importing the same list twice reports zeros the second time.
+type Report struct {
+	AccountsCreated   int `json:"accounts_created"`
+	AccountsUpdated   int `json:"accounts_updated"`
+	CharactersCreated int `json:"characters_created"`
+	CharactersUpdated int `json:"characters_updated"`
+	// Tracked and Untracked are characters enrolled in or removed from the
+	// fetcher, Paused and Resumed the enrolled ones whose fetching was
+	// turned off or on.
+	Tracked   int `json:"tracked"`
+	Untracked int `json:"untracked"`
+	Paused    int `json:"paused"`
+	Resumed   int `json:"resumed"`
+}
+
+// Entry is a line of an imported list. Line is where it was read from in a
+// CSV file, 0 for JSON.
+type Entry struct {
+	models.RosterEntry
+	Line int `json:"-"`
+}
+
+// Validate checks every entry before anything is imported, trimming names
+// and normalizing the realm. Fields are named after the entry, "entries[2].realm" for
+// the third JSON entry or "line 4.realm" for a CSV line.
+func Validate(entries []Entry) []apierror.FieldError {
+	var errs []apierror.FieldError
+	for i := range entries {
+		e := &entries[i]
+		prefix := fmt.Sprintf("entries[%d]", i)
+		if e.Line > 0 {
+			prefix = fmt.Sprintf("line %d", e.Line)
+		}
+		fail := func(field string, format string, args ...any) {
+			errs = append(errs, apierror.FieldError{Field: prefix + "." + field, Message: fmt.Sprintf(format, args...)})
+		}
+
+		e.AccountName = strings.TrimSpace(e.AccountName)
+		e.CharacterName = strings.TrimSpace(e.CharacterName)
+		e.League = strings.TrimSpace(e.League)
+		if e.AccountName == "" {
+			fail("account_name", "must not be empty")
+		}
+		e.Realm = strings.ToLower(strings.TrimSpace(e.Realm))
+		if e.Realm == "" {
+			e.Realm = poeclient.RealmPC
+		}
+		if !poeclient.IsValidRealm(e.Realm) {
+			fail("realm", "must be one of %v", poeclient.Realms)
+		}
+		if e.CharacterName == "" {
+			if e.League != "" {
+				fail("league", "needs a character_name")
+			}
+			if e.Tracked != nil {
+				fail("tracked", "needs a character_name")
+			}
+			if e.Paused != nil {
+				fail("paused", "needs a character_name")
+			}
+		}
+		if e.Paused != nil && *e.Paused && e.Tracked != nil && !*e.Tracked {
+			fail("paused", "only applies to tracked characters")
+		}
+	}
+	return errs
+}
+
+// Import creates the accounts and characters of entries that don't exist
+// yet and brings the others in line with them. Empty fields are left as
+// stored: a line without a player keeps the player of the account, one
+// without tracked doesn't enroll or remove the character. Entries must have
+// gone through Validate.
+//
+// The list is imported in one transaction, nothing is kept when an entry
+// fails.
+func Import(repo *repository.Repository, entries []Entry) (Report, error) {
+	var report Report
+	err := repo.InTx(func(tx *repository.Repository) error {
+		var err error
+		report, err = importEntries(tx, entries)
+		return err
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+func importEntries(repo *repository.Repository, entries []Entry) (Report, error) {
+	var report Report
+	accounts := map[string]models.Account{}
+
+	for _, e := range entries {
+		key := e.AccountName + "\x00" + e.Realm
+		acc, ok := accounts[key]
+		if !ok {
+			var created bool
+			var err error
+			acc, created, err = repo.UpsertAccount(e.AccountName, e.Player, e.Realm)
+			if err != nil {
+				return report, fmt.Errorf("account %s: %w", e.AccountName, err)
+			}
+			if created {
+				report.AccountsCreated++
+			}
+		}
+		if e.Player != "" && e.Player != utils.StringValue(acc.Player) {
+			err := repo.UpdateAccount(repository.UpdateAccountParams{
+				ID:          acc.ID,
+				AccountName: acc.AccountName,
+				Player:      e.Player,
+				Realm:       acc.Realm,
+				UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+			})
+			if err != nil {
+				return report, fmt.Errorf("account %s: %w", e.AccountName, err)
+			}
+			player := e.Player
+			acc.Player = &player
+			report.AccountsUpdated++
+		}
+		accounts[key] = acc
+
+		if e.CharacterName == "" {
+			continue
+		}
+		if err := importCharacter(repo, acc, e, &report); err != nil {
+			return report, fmt.Errorf("character %s of %s: %w", e.CharacterName, e.AccountName, err)
+		}
+	}
+	return report, nil
+}
+
+func importCharacter(repo *repository.Repository, acc models.Account, e Entry, report *Report) error {
+	if e.League != "" {
+		if _, err := repo.EnsureLeague(e.League, acc.Realm); err != nil {
+			return err
+		}
+	}
+	c, created, err := repo.UpsertCharacter(acc.ID, e.CharacterName, e.League, acc.Realm)
+	if err != nil {
+		return err
+	}
+	if created {
+		report.CharactersCreated++
+	} else if e.League != "" && e.League != utils.StringValue(c.CurrentLeague) {
+		err := repo.UpdateCharacter(repository.UpdateCharacterParams{
+			ID:            c.ID,
+			CharacterName: c.CharacterName,
+			Died:          c.Died,
+			CurrentLeague: e.League,
+			Realm:         c.Realm,
+			UpdatedAt:     time.Now().UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		report.CharactersUpdated++
+	}
+
+	if e.Tracked != nil && !*e.Tracked {
+		removed, err := repo.RemoveCharacterToFetch(c.ID)
+		if err != nil {
+			return err
+		}
+		if removed {
+			report.Untracked++
+		}
+		return nil
+	}
+	if e.Tracked != nil {
+		added, err := repo.EnsureCharacterToFetch(c.ID)
+		if err != nil {
+			return err
+		}
+		if added {
+			report.Tracked++
+		}
+	}
+	if e.Paused == nil {
+		return nil
+	}
+	ctf, err := repo.GetCharacterToFetch(c.ID)
+	if err != nil {
+		// Pausing a character that isn't tracked has nothing to change.
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if ctf.ShouldSkip == *e.Paused {
+		return nil
+	}
+	if err := repo.SetShouldSkip(*e.Paused, ctf.Id); err != nil {
+		return err
+	}
+	if *e.Paused {
+		report.Paused++
+	} else {
+		report.Resumed++
+	}
+	return nil
+}
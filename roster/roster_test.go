@@ -0,0 +1,161 @@
+package roster
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestRepository opens an in-memory database with every migration
+// applied.
+func newTestRepository(t *testing.T) (*repository.Repository, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("migration %s: %v", filepath.Base(file), err)
+		}
+	}
+	return repository.NewRepository(db), db
+}
+
+func count(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func readValid(t *testing.T, csv string) []Entry {
+	t.Helper()
+	entries, err := ReadCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(entries); len(errs) > 0 {
+		t.Fatalf("invalid entries: %v", errs)
+	}
+	return entries
+}
+
+const rosterCSV = `account_name,realm,player,character_name,league,tracked,paused
+Steel#1,PC,Steel,Mage,Settlers,yes,
+Steel#1,pc,,Witch,Settlers,yes,yes
+Steel#1,pc,,Retired,,no,
+Ziz#2,xbox,Ziz,,,,
+`
+
+func TestImport(t *testing.T) {
+	repo, db := newTestRepository(t)
+
+	tests := []struct {
+		name string
+		csv  string
+		want Report
+	}{
+		{
+			name: "new roster",
+			csv:  rosterCSV,
+			want: Report{AccountsCreated: 2, CharactersCreated: 3, Tracked: 2, Paused: 1},
+		},
+		{
+			name: "same roster again",
+			csv:  rosterCSV,
+			want: Report{},
+		},
+		{
+			name: "changes",
+			csv: `account_name,player,character_name,league,tracked,paused
+Steel#1,Steel the Mage,Mage,Standard,,
+Steel#1,,Witch,,,no
+Steel#1,,Mage,,no,
+Steel#1,,Retired,,yes,
+`,
+			want: Report{AccountsUpdated: 1, CharactersUpdated: 1, Untracked: 1, Tracked: 1, Resumed: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Import(repo, readValid(t, tt.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report != tt.want {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+
+	checks := []struct {
+		query string
+		want  int
+	}{
+		{`SELECT COUNT(*) FROM accounts`, 2},
+		{`SELECT COUNT(*) FROM accounts WHERE account_name = 'Steel#1' AND realm = 'pc' AND player = 'Steel the Mage'`, 1},
+		{`SELECT COUNT(*) FROM characters WHERE character_name = 'Mage' AND current_league = 'Standard'`, 1},
+		{`SELECT COUNT(*) FROM leagues`, 2},
+		{`SELECT COUNT(*) FROM characters_to_fetch f JOIN characters c ON c.id = f.character_id WHERE c.character_name IN ('Witch', 'Retired') AND NOT f.should_skip`, 2},
+		{`SELECT COUNT(*) FROM characters_to_fetch`, 2},
+	}
+	for _, c := range checks {
+		if got := count(t, db, c.query); got != c.want {
+			t.Errorf("%s = %d, want %d", c.query, got, c.want)
+		}
+	}
+}
+
+func TestImportRollsBack(t *testing.T) {
+	repo, db := newTestRepository(t)
+	if _, err := db.Exec(`CREATE TRIGGER fail_broken BEFORE INSERT ON characters
+		WHEN NEW.character_name = 'Broken'
+		BEGIN SELECT RAISE(ABORT, 'broken character'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []Entry{
+		{RosterEntry: models.RosterEntry{AccountName: "Steel#1", CharacterName: "Mage"}},
+		{RosterEntry: models.RosterEntry{AccountName: "Ziz#2", CharacterName: "Broken"}},
+	}
+	if errs := Validate(entries); len(errs) > 0 {
+		t.Fatalf("invalid entries: %v", errs)
+	}
+	report, err := Import(repo, entries)
+	if err == nil || !strings.Contains(err.Error(), "Broken") {
+		t.Fatalf("err = %v, want the broken character to fail", err)
+	}
+	if report != (Report{}) {
+		t.Errorf("report = %+v, want nothing reported", report)
+	}
+	for _, table := range []string{"accounts", "characters"} {
+		if got := count(t, db, `SELECT COUNT(*) FROM `+table); got != 0 {
+			t.Errorf("%s has %d rows after a failed import, want none", table, got)
+		}
+	}
+}
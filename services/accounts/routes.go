@@ -1,15 +1,16 @@
 package accounts
 
 import (
-	"database/sql"
-	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/ByChanderZap/exile-tracker/apierror"
 	apimodels "github.com/ByChanderZap/exile-tracker/models/api"
+	"github.com/ByChanderZap/exile-tracker/poeclient"
 	"github.com/ByChanderZap/exile-tracker/repository"
 	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/ByChanderZap/exile-tracker/utils/query"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 )
@@ -34,9 +35,14 @@ func (h *Handler) RegisterRoutes(router *chi.Mux) {
 }
 
 func (h *Handler) handleGetAllAccounts(w http.ResponseWriter, r *http.Request) {
-	accounts, err := h.repository.GetAllAccounts()
+	params, err := query.AccountListParams(r)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+	accounts, err := h.repository.ListAccounts(params)
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, accounts)
@@ -46,11 +52,7 @@ func (h *Handler) handleGetAccountByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	account, err := h.repository.GetAccountByID(id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.RespondWithError(w, http.StatusNotFound, fmt.Errorf("account not found"))
-			return
-		}
-		utils.RespondWithError(w, http.StatusNotFound, err)
+		apierror.Write(w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, account)
@@ -59,7 +61,16 @@ func (h *Handler) handleGetAccountByID(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	var payload apimodels.CreateAccountInput
 	if err := utils.ParseJson(r, &payload); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, err)
+		apierror.Write(w, r, apierror.BadRequest(err))
+		return
+	}
+
+	realm := payload.Realm
+	if realm == "" {
+		realm = poeclient.RealmPC
+	}
+	if !poeclient.IsValidRealm(realm) {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("invalid realm %q, expected one of %v", realm, poeclient.Realms)))
 		return
 	}
 
@@ -67,43 +78,64 @@ func (h *Handler) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	if payload.Player != nil {
 		player = *payload.Player
 	}
-	err := h.repository.CreateAccount(payload.AccountName, player)
+	acc, created, err := h.repository.UpsertAccount(payload.AccountName, player, realm)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, map[string]interface{}{
-		"message": "Account created",
-	})
+	location := "/api/v1/accounts/" + acc.ID
+	if created {
+		utils.WriteCreated(w, location, acc)
+		return
+	}
+	// Creating an account that already exists answers it, unless the
+	// request asks for another player.
+	w.Header().Set("Location", location)
+	if payload.Player != nil && *payload.Player != utils.StringValue(acc.Player) {
+		apierror.Write(w, r, apierror.Conflict("account %q already exists in realm %s with another player", acc.AccountName, acc.Realm))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, acc)
 }
 
 func (h *Handler) handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	var payload apimodels.UpdateAccountInput
 	if err := utils.ParseJson(r, &payload); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, err)
+		apierror.Write(w, r, apierror.BadRequest(err))
 		return
 	}
 
 	acc, err := h.repository.GetAccountByID(id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.RespondWithError(w, http.StatusNotFound, fmt.Errorf("the character that you are trying to update does not exists"))
-			return
-		}
-		utils.RespondWithError(w, http.StatusBadRequest, err)
+		apierror.Write(w, r, err)
 		return
 	}
 
+	realm := payload.Realm
+	if realm == "" {
+		realm = acc.Realm
+	}
+	if !poeclient.IsValidRealm(realm) {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("invalid realm %q, expected one of %v", realm, poeclient.Realms)))
+		return
+	}
+
+	// The player is kept when left out of the payload.
+	player := acc.Player
+	if payload.Player != nil {
+		player = payload.Player
+	}
 	err = h.repository.UpdateAccount(repository.UpdateAccountParams{
 		ID:          acc.ID,
 		AccountName: payload.AccountName,
-		Player:      *payload.Player,
+		Player:      utils.StringValue(player),
+		Realm:       realm,
 		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
 	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -0,0 +1,167 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/analytics"
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/ByChanderZap/exile-tracker/utils/query"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultLimit      = 25
+	defaultWindowDays = 7
+)
+
+type Handler struct {
+	repository *repository.Repository
+	trees      *passivetree.Registry
+	log        zerolog.Logger
+}
+
+func NewHandler(db *repository.Repository, trees *passivetree.Registry, logger zerolog.Logger) *Handler {
+	return &Handler{
+		repository: db,
+		trees:      trees,
+		log:        logger,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *chi.Mux) {
+	router.Get("/analytics/leagues", h.handleGetLeagues)
+	router.Get("/analytics/overview", h.handleGetOverview)
+	router.Get("/analytics/trends/{category}", h.handleGetTrends)
+	router.Get("/analytics/characters/{characterId}/upgrades", h.handleGetUpgrades)
+}
+
+type overviewResponse struct {
+	Filter analytics.Filter `json:"filter"`
+	analytics.Overview
+}
+
+type trendsResponse struct {
+	Filter   analytics.Filter   `json:"filter"`
+	Category analytics.Category `json:"category"`
+	Periods  []periodResponse   `json:"periods"`
+	Trends   []analytics.Trend  `json:"trends"`
+}
+
+type periodResponse struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Characters int       `json:"characters"`
+}
+
+func (h *Handler) handleGetLeagues(w http.ResponseWriter, r *http.Request) {
+	summaries, ok := h.loadSummaries(w, r)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, analytics.Leagues(summaries))
+}
+
+func (h *Handler) handleGetOverview(w http.ResponseWriter, r *http.Request) {
+	limit, err := utils.QueryInt(r, "limit", defaultLimit)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+
+	summaries, ok := h.loadSummaries(w, r)
+	if !ok {
+		return
+	}
+	filter := query.AnalyticsFilter(r)
+	utils.WriteJSON(w, http.StatusOK, overviewResponse{
+		Filter:   filter,
+		Overview: analytics.Latest(summaries, filter).Top(limit),
+	})
+}
+
+func (h *Handler) handleGetTrends(w http.ResponseWriter, r *http.Request) {
+	category, ok := analytics.ParseCategory(chi.URLParam(r, "category"))
+	if !ok {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("unknown category %q", chi.URLParam(r, "category"))))
+		return
+	}
+	limit, err := utils.QueryInt(r, "limit", 10)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+	days, err := utils.QueryInt(r, "window_days", defaultWindowDays)
+	if err != nil || days <= 0 {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("window_days must be a positive number")))
+		return
+	}
+
+	summaries, ok := h.loadSummaries(w, r)
+	if !ok {
+		return
+	}
+	filter := query.AnalyticsFilter(r)
+	periods := analytics.Timeline(summaries, filter, time.Duration(days)*24*time.Hour)
+
+	res := trendsResponse{
+		Filter:   filter,
+		Category: category,
+		Periods:  make([]periodResponse, 0, len(periods)),
+		Trends:   analytics.Trends(periods, category, limit),
+	}
+	for _, p := range periods {
+		res.Periods = append(res.Periods, periodResponse{Start: p.Start, End: p.End, Characters: p.Characters})
+	}
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+func (h *Handler) handleGetUpgrades(w http.ResponseWriter, r *http.Request) {
+	params, err := query.UpgradeParams(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+	limit, err := utils.QueryInt(r, "limit", defaultLimit)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+
+	characterId := chi.URLParam(r, "characterId")
+	if _, err := h.repository.GetCharacterByID(characterId); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	report, err := analytics.BuildUpgradeReport(h.repository, h.trees, characterId, params)
+	if err != nil {
+		if errors.Is(err, analytics.ErrNoSnapshots) || errors.Is(err, analytics.ErrNoReferenceStat) {
+			apierror.Write(w, r, apierror.NotFound("%s", err))
+			return
+		}
+		h.log.Error().Err(err).Msg("Failed to build upgrade report")
+		apierror.Write(w, r, err)
+		return
+	}
+	if limit > 0 && len(report.Upgrades) > limit {
+		report.Upgrades = report.Upgrades[:limit]
+	}
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+func (h *Handler) loadSummaries(w http.ResponseWriter, r *http.Request) ([]analytics.Summary, bool) {
+	summaries, err := analytics.LoadSummaries(h.repository, h.trees)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		apierror.Write(w, r, err)
+		return nil, false
+	}
+	return summaries, true
+}
@@ -1,16 +1,18 @@
 package characters
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/ByChanderZap/exile-tracker/apierror"
 	apimodels "github.com/ByChanderZap/exile-tracker/models/api"
 	models "github.com/ByChanderZap/exile-tracker/models/api"
+	"github.com/ByChanderZap/exile-tracker/poeclient"
 	"github.com/ByChanderZap/exile-tracker/repository"
 	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/ByChanderZap/exile-tracker/utils/query"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 )
@@ -36,39 +38,43 @@ func (h *Handler) RegisterRoutes(router *chi.Mux) {
 	router.Patch("/characters/{id}/kill", h.handleKillCharacter)
 	router.Post("/characters/to-fetch", h.handleAddCharactersToFetch)
 	router.Get("/characters/to-fetch", h.handleGetAllCharactersToFetch)
+	router.Get("/characters/to-fetch/{characterId}", h.handleGetCharacterToFetch)
 	// router.Delete("/characters/{id}", h.handleDeleteCharacter)
 }
 
 func (h *Handler) handleGetAllCharacters(w http.ResponseWriter, r *http.Request) {
-	characters, err := h.repository.GetAllCharacters()
+	params, err := query.CharacterListParams(r)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, apierror.InvalidParameter(err))
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, characters)
+	h.respondWithCharacters(w, r, params)
 }
 
 func (h *Handler) handleGetCharacterByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	character, err := h.repository.GetCharacterByID(id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.WriteJSON(w, http.StatusNotFound, map[string]interface{}{
-				"Message": "Character not found",
-			})
-			return
-		}
-		utils.RespondWithError(w, http.StatusNotFound, err)
+		apierror.Write(w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, character)
 }
 
 func (h *Handler) handleGetCharactersByAccount(w http.ResponseWriter, r *http.Request) {
-	accountId := chi.URLParam(r, "accountId")
-	characters, err := h.repository.GetCharactersByAccountId(accountId)
+	params, err := query.CharacterListParams(r)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+	params.AccountId = chi.URLParam(r, "accountId")
+	h.respondWithCharacters(w, r, params)
+}
+
+func (h *Handler) respondWithCharacters(w http.ResponseWriter, r *http.Request, params repository.ListCharactersParams) {
+	characters, err := h.repository.ListCharacters(params)
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, characters)
@@ -77,47 +83,97 @@ func (h *Handler) handleGetCharactersByAccount(w http.ResponseWriter, r *http.Re
 func (h *Handler) handleCreateCharacter(w http.ResponseWriter, r *http.Request) {
 	var payload apimodels.CreateCharacterInput
 	if err := utils.ParseJson(r, &payload); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, err)
+		apierror.Write(w, r, apierror.BadRequest(err))
 		return
 	}
-	err := h.repository.CreateCharacter(payload.AccountId, payload.CharacterName, payload.CurrentLeague)
+
+	acc, err := h.repository.GetAccountByID(payload.AccountId)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.InvalidReference("account %s does not exist", payload.AccountId))
+			return
+		}
+		apierror.Write(w, r, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, map[string]interface{}{
-		"message": "Character created",
-	})
+	// Characters live in the realm of their account unless told otherwise.
+	realm := payload.Realm
+	if realm == "" {
+		realm = acc.Realm
+	}
+	if !poeclient.IsValidRealm(realm) {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("invalid realm %q, expected one of %v", realm, poeclient.Realms)))
+		return
+	}
+
+	if payload.CurrentLeague != "" {
+		if _, err := h.repository.EnsureLeague(payload.CurrentLeague, realm); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+	}
+	c, created, err := h.repository.UpsertCharacter(payload.AccountId, payload.CharacterName, payload.CurrentLeague, realm)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	location := "/api/v1/characters/" + c.ID
+	if created {
+		utils.WriteCreated(w, location, c)
+		return
+	}
+	// Creating a character that already exists answers it, unless the
+	// request asks for another league or realm.
+	w.Header().Set("Location", location)
+	if c.Realm != realm || (payload.CurrentLeague != "" && payload.CurrentLeague != utils.StringValue(c.CurrentLeague)) {
+		apierror.Write(w, r, apierror.Conflict("character %q already exists in this account with another league or realm", c.CharacterName))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, c)
 }
 
 func (h *Handler) handleUpdateCharacter(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	var payload apimodels.UpdateCharacterInput
 	if err := utils.ParseJson(r, &payload); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, err)
+		apierror.Write(w, r, apierror.BadRequest(err))
 		return
 	}
 
 	c, err := h.repository.GetCharacterByID(id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.RespondWithError(w, http.StatusNotFound, fmt.Errorf("the character that you are trying to update does not exists"))
+		apierror.Write(w, r, err)
+		return
+	}
+
+	realm := payload.Realm
+	if realm == "" {
+		realm = c.Realm
+	}
+	if !poeclient.IsValidRealm(realm) {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("invalid realm %q, expected one of %v", realm, poeclient.Realms)))
+		return
+	}
+
+	if payload.CurrentLeague != "" {
+		if _, err := h.repository.EnsureLeague(payload.CurrentLeague, realm); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
-		utils.RespondWithError(w, http.StatusBadRequest, err)
-		return
 	}
 
 	err = h.repository.UpdateCharacter(repository.UpdateCharacterParams{
 		ID:            c.ID,
 		CharacterName: payload.CharacterName,
 		CurrentLeague: payload.CurrentLeague,
+		Realm:         realm,
 		Died:          c.Died,
 		UpdatedAt:     time.Now().UTC().Format(time.RFC3339),
 	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -130,7 +186,7 @@ func (h *Handler) handleKillCharacter(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	err := h.repository.KillCharacter(id)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -143,37 +199,62 @@ func (h *Handler) handleAddCharactersToFetch(w http.ResponseWriter, r *http.Requ
 	var payload models.AddCharacterToFetchInput
 	if err := utils.ParseJson(r, &payload); err != nil {
 		h.log.Error().Err(err).Msg("Error decoding add characters to fetch input")
-		utils.RespondWithError(w, http.StatusBadRequest, err)
+		apierror.Write(w, r, apierror.BadRequest(err))
 		return
 	}
 
-	err := h.repository.AddCharacterToFetch(repository.AddCharactersToFetchParams{
-		CharacterId: payload.CharacterId,
-	})
+	if _, err := h.repository.GetCharacterByID(payload.CharacterId); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.InvalidReference("character %s does not exist", payload.CharacterId))
+			return
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Characters already tracked, through a watchlist or the ladder import,
+	// aren't enrolled twice: the existing enrollment is answered with 200.
+	added, err := h.repository.EnsureCharacterToFetch(payload.CharacterId)
 	if err != nil {
 		h.log.Error().Err(err).Msg("Error while trying to add character to fetch")
-		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Errorf("couldnt add character to fetch"))
+		apierror.Write(w, r, err)
 		return
 	}
-	utils.WriteJSON(w, http.StatusCreated, map[string]interface{}{
-		"Message": fmt.Sprintf("Character %s is now tracked", payload.CharacterId),
-	})
+	ctf, err := h.repository.GetCharacterToFetch(payload.CharacterId)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if !added {
+		utils.WriteJSON(w, http.StatusOK, ctf)
+		return
+	}
+	utils.WriteCreated(w, "/api/v1/characters/to-fetch/"+ctf.CharacterId, ctf)
+}
+
+func (h *Handler) handleGetCharacterToFetch(w http.ResponseWriter, r *http.Request) {
+	ctf, err := h.repository.GetCharacterToFetch(chi.URLParam(r, "characterId"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, ctf)
 }
 
 func (h *Handler) handleGetAllCharactersToFetch(w http.ResponseWriter, r *http.Request) {
-	ctf, err := h.repository.GetCharactersToFetch()
+	ctf, err := h.repository.GetCharactersToFetch(r.URL.Query().Get("league"))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, err)
+		apierror.Write(w, r, err)
 		return
 	}
-	utils.WriteJSON(w, http.StatusAccepted, ctf)
+	utils.WriteJSON(w, http.StatusOK, ctf)
 }
 
 // func (h *Handler) handleDeleteCharacter(w http.ResponseWriter, r *http.Request) {
 // 	id := chi.URLParam(r, "id")
 // 	err := h.repository.DeleteCharacter(id)
 // 	if err != nil {
-// 		utils.RespondWithError(w, http.StatusInternalServerError, err)
+// 		apierror.Write(w, r, err)
 // 		return
 // 	}
 
@@ -5,15 +5,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ByChanderZap/exile-tracker/buildsSitesClient"
-	"github.com/ByChanderZap/exile-tracker/config"
 	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/pob"
 	"github.com/ByChanderZap/exile-tracker/poeclient"
 	"github.com/ByChanderZap/exile-tracker/repository"
 	"github.com/ByChanderZap/exile-tracker/utils"
@@ -23,24 +21,40 @@ import (
 type FetcherService struct {
 	repo      *repository.Repository
 	poeClient *poeclient.POEClient
+	pobPool   *pob.Pool
 	log       zerolog.Logger
 	ticker    *time.Ticker
 	done      chan bool
+
+	mu      sync.Mutex
+	started bool
 }
 
-func NewFetcherService(repo *repository.Repository, poeClient *poeclient.POEClient, interval time.Duration) *FetcherService {
+func NewFetcherService(repo *repository.Repository, poeClient *poeclient.POEClient, pobPool *pob.Pool, interval time.Duration) *FetcherService {
 	return &FetcherService{
 		repo:      repo,
 		poeClient: poeClient,
+		pobPool:   pobPool,
 		log:       utils.ChildLogger("fetcher"),
 		ticker:    time.NewTicker(interval),
 		done:      make(chan bool),
 	}
 }
 
-func (fs *FetcherService) Start(ctx context.Context) {
+// Start starts the PoB worker pool, runs a fetch cycle and then one every
+// interval. Without workers snapshots can't be computed, so it fails
+// instead of running without them.
+func (fs *FetcherService) Start(ctx context.Context) error {
 	fs.log.Info().Msg("Starting fetcher service")
 
+	if err := fs.pobPool.Start(); err != nil {
+		return fmt.Errorf("failed to start PoB worker pool: %w", err)
+	}
+
+	fs.mu.Lock()
+	fs.started = true
+	fs.mu.Unlock()
+
 	// Run once first
 	go fs.fetchAllData()
 
@@ -54,20 +68,32 @@ func (fs *FetcherService) Start(ctx context.Context) {
 				fs.fetchAllData()
 			case <-ctx.Done():
 				fs.log.Info().Msg("Context cancelled, stopping fetcher service")
+				return
 			}
 		}
 	}()
+	return nil
 }
 
+// Stop stops the fetch loop and the worker pool. It is safe to call when
+// Start failed or more than once.
 func (fs *FetcherService) Stop() {
 	fs.ticker.Stop()
-	fs.done <- true
+	fs.mu.Lock()
+	if fs.started {
+		close(fs.done)
+		fs.started = false
+	}
+	fs.mu.Unlock()
+	fs.pobPool.Close()
 }
 
 func (fs *FetcherService) fetchAllData() {
 	fs.log.Info().Msg("Starting fetch cycle")
 
-	charactersToFetch, err := fs.repo.GetCharactersToFetch()
+	fs.syncLeagues()
+
+	charactersToFetch, err := fs.repo.GetCharactersToFetch("")
 	if err != nil {
 		fs.log.Error().Err(err).Msg("Faile to get characters to fetch from database")
 		return
@@ -75,7 +101,14 @@ func (fs *FetcherService) fetchAllData() {
 
 	fs.log.Info().Int("characters_to_fetch", len(charactersToFetch)).Msg("Found characters to process")
 
+	// Older databases can hold the same character more than once, it only
+	// needs fetching once per cycle. Paused and dead characters are skipped.
+	fetched := make(map[string]bool, len(charactersToFetch))
 	for _, ctf := range charactersToFetch {
+		if fetched[ctf.CharacterId] || ctf.ShouldSkip {
+			continue
+		}
+		fetched[ctf.CharacterId] = true
 		fs.FetchCharacterData(ctf)
 		time.Sleep(2 * time.Second)
 	}
@@ -101,17 +134,19 @@ func (fs *FetcherService) FetchCharacterData(ctf models.CharactersToFetch) {
 
 	if c.Died {
 		log.Warn().Msg("Character is dead, skipping fetch")
-		fs.repo.SetShouldSkip(true, ctf.Id)
+		if err := fs.repo.SetShouldSkip(true, ctf.Id); err != nil {
+			log.Error().Err(err).Msg("Failed to stop fetching dead character")
+		}
 		return
 	}
 
-	items, err := fs.poeClient.GetItemsJson(acc.AccountName, c.CharacterName, "pc")
+	items, err := fs.poeClient.GetItemsJson(acc.AccountName, c.CharacterName, c.Realm)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch items")
 		return
 	}
 
-	passives, err := fs.poeClient.GetPassiveSkillsJson(acc.AccountName, c.CharacterName, "pc")
+	passives, err := fs.poeClient.GetPassiveSkillsJson(acc.AccountName, c.CharacterName, c.Realm)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch passive skills")
 		return
@@ -129,6 +164,21 @@ func (fs *FetcherService) FetchCharacterData(ctf models.CharactersToFetch) {
 		return
 	}
 
+	fs.syncCharacterLeague(c, itemsResponse.Character.League, log)
+
+	// Level and experience are kept on every fetch, even when the build
+	// didn't change and no snapshot is created.
+	err = fs.repo.CreateExperienceSample(repository.CreateExperienceSampleParams{
+		CharacterId: c.ID,
+		League:      itemsResponse.Character.League,
+		Level:       itemsResponse.Character.Level,
+		Experience:  itemsResponse.Character.Experience,
+		RecordedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to store experience sample")
+	}
+
 	err = fs.CreateSnapshot(c.ID, itemsResponse, passivesResponse)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create snapshot")
@@ -136,51 +186,96 @@ func (fs *FetcherService) FetchCharacterData(ctf models.CharactersToFetch) {
 	}
 }
 
-func (fs *FetcherService) CreateSnapshot(characterId string, items models.ItemsResponse, passives models.PassiveSkillsResponse) error {
-	currentDir, err := os.Getwd()
+// syncLeagues makes sure every league a character is in is known and
+// refreshes league dates from the PoE API. Failing to reach the API only
+// means dates are not updated this cycle.
+func (fs *FetcherService) syncLeagues() {
+	characters, err := fs.repo.GetAllCharacters("")
 	if err != nil {
-		return errors.Join(err, errors.New("failed to get current directory"))
+		fs.log.Error().Err(err).Msg("Failed to get characters to sync leagues")
+		return
+	}
+	realms := make(map[string]bool)
+	for _, c := range characters {
+		realms[c.Realm] = true
+		if name := utils.StringValue(c.CurrentLeague); name != "" {
+			if _, err := fs.repo.EnsureLeague(name, c.Realm); err != nil {
+				fs.log.Error().Err(err).Str("league", name).Str("realm", c.Realm).Msg("Failed to store league")
+			}
+		}
 	}
 
-	dirPath := filepath.Join(currentDir, characterId)
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return errors.Join(err, errors.New("failed to create character directory"))
+	for realm := range realms {
+		fs.syncLeagueDates(realm)
 	}
+}
 
-	itemsPath := filepath.Join(dirPath, "items.json")
-	file, err := os.OpenFile(itemsPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+func (fs *FetcherService) syncLeagueDates(realm string) {
+	apiLeagues, err := fs.poeClient.GetLeagues(realm)
 	if err != nil {
-		return errors.Join(err, errors.New("error trying to open items file"))
+		fs.log.Warn().Err(err).Str("realm", realm).Msg("Failed to fetch leagues from the PoE API")
+		return
+	}
+	for _, l := range apiLeagues {
+		league, err := fs.repo.GetLeagueByName(l.ID, realm)
+		if err != nil {
+			continue
+		}
+		params := repository.UpdateLeagueDatesParams{
+			ID:      league.ID,
+			StartAt: league.StartAt,
+			EndAt:   league.EndAt,
+		}
+		if l.StartAt != nil {
+			params.StartAt = l.StartAt
+		}
+		if l.EndAt != nil {
+			params.EndAt = l.EndAt
+		}
+		if err := fs.repo.UpdateLeagueDates(params); err != nil {
+			fs.log.Error().Err(err).Str("league", l.ID).Msg("Failed to update league dates")
+		}
 	}
-	defer file.Close()
+}
 
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(items)
-	if err != nil {
-		return errors.Join(err, errors.New("something went wrong while encoding json items"))
+// syncCharacterLeague records the league the PoE API reports for a
+// character. When GGG moves a character to a permanent league at the end of
+// a challenge league, the old league is marked as ended.
+func (fs *FetcherService) syncCharacterLeague(c models.Character, league string, log zerolog.Logger) {
+	if league == "" {
+		return
+	}
+	if _, err := fs.repo.EnsureLeague(league, c.Realm); err != nil {
+		log.Error().Err(err).Str("league", league).Msg("Failed to store league")
+		return
 	}
 
-	passivesPath := filepath.Join(dirPath, "passives.json")
-	passivesFile, err := os.OpenFile(passivesPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return errors.Join(err, errors.New("error trying to open passives file"))
+	previous := utils.StringValue(c.CurrentLeague)
+	if previous == league {
+		return
+	}
+	if previous != "" && repository.IsPermanentLeague(league) && !repository.IsPermanentLeague(previous) {
+		log.Info().Str("from", previous).Str("to", league).Msg("Character was moved to a permanent league")
+		old, err := fs.repo.EnsureLeague(previous, c.Realm)
+		if err == nil {
+			err = fs.repo.EndLeague(old.ID, time.Now())
+		}
+		if err != nil {
+			log.Error().Err(err).Str("league", previous).Msg("Failed to mark league as ended")
+		}
 	}
-	defer passivesFile.Close()
 
-	encoder2 := json.NewEncoder(passivesFile)
-	err = encoder2.Encode(passives)
-	if err != nil {
-		return errors.Join(err, errors.New("something went wrong while encoding json passives"))
+	if err := fs.repo.SetCharacterLeague(c.ID, league); err != nil {
+		log.Error().Err(err).Msg("Failed to update character league")
 	}
+}
 
-	result, err := fs.generatePoBBin(itemsPath, passivesPath)
+func (fs *FetcherService) CreateSnapshot(characterId string, items models.ItemsResponse, passives models.PassiveSkillsResponse) error {
+	result, pobStats, err := fs.generatePoBExport(items, passives)
 	if err != nil {
 		return errors.Join(err, errors.New("failed to execute PoB"))
 	}
 
-	// Clean up after execution
-	os.RemoveAll(dirPath)
-
 	dbSnapshot, err := fs.repo.GetLatestSnapshotByCharacter(characterId)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
@@ -193,9 +288,22 @@ func (fs *FetcherService) CreateSnapshot(characterId string, items models.ItemsR
 		return errors.New("no changes detected between latest and current snapshot")
 	}
 
-	err = fs.repo.CreatePOBSnapshot(repository.CreatePoBSnapshotParams{
+	itemsJson, err := json.Marshal(items)
+	if err != nil {
+		return errors.Join(err, errors.New("something went wrong while encoding json items"))
+	}
+	passivesJson, err := json.Marshal(passives)
+	if err != nil {
+		return errors.Join(err, errors.New("something went wrong while encoding json passives"))
+	}
+
+	_, err = fs.repo.CreatePOBSnapshot(repository.CreatePoBSnapshotParams{
 		CharacterId:  characterId,
 		ExportString: result,
+		League:       items.Character.League,
+		Stats:        snapshotStatsFromPoB(items.Character.Level, pobStats),
+		ItemsJson:    itemsJson,
+		PassivesJson: passivesJson,
 	})
 	if err != nil {
 		return errors.Join(err, errors.New("something went wrong while trying to store snapshot"))
@@ -204,34 +312,52 @@ func (fs *FetcherService) CreateSnapshot(characterId string, items models.ItemsR
 	return nil
 }
 
-func (fs *FetcherService) generatePoBBin(itemsPath string, passivesPath string) (string, error) {
-	fs.log.Info().Msg("Executing Path of Building in headless mode")
-	pobRoot := config.Envs.POBRoot
-
-	srcDir := filepath.Join(pobRoot, "src")
+func (fs *FetcherService) generatePoBExport(items models.ItemsResponse, passives models.PassiveSkillsResponse) (string, map[string]float64, error) {
+	fs.log.Info().Msg("Sending build to Path of Building worker")
 
-	runtimeLua := filepath.Join(pobRoot, "runtime", "lua")
-	runtime := filepath.Join(pobRoot, "runtime")
-
-	os.Setenv("LUA_PATH", runtimeLua+"/?.lua;"+runtimeLua+"/?/init.lua;;")
-	os.Setenv("LUA_CPATH", runtime+"/?.so;"+runtime+"/?.dll;;")
-
-	// Use absolute paths for JSON files to avoid any path resolution issues
-	cmd := exec.Command("/usr/bin/luajit", "HeadlessWrapper.lua", itemsPath, passivesPath)
-	cmd.Dir = srcDir // Set working directory for this command only
-
-	output, err := cmd.CombinedOutput()
+	res, err := fs.pobPool.Submit(context.Background(), pob.Job{
+		Items:    items,
+		Passives: passives,
+	})
 	if err != nil {
-		return "", errors.Join(err, errors.New("the command execution failed"))
+		return "", nil, err
 	}
 
-	lines := strings.Split(string(output), "\n")
-	last := lines[len(lines)-2]
-
-	uploadedBuild, err := buildsSitesClient.UploadBuild(last, buildsSitesClient.SitesUrl.PoeNinja)
+	uploadedBuild, err := buildsSitesClient.UploadBuild(res.Code, buildsSitesClient.SitesUrl.PoeNinja)
 	if err != nil {
-		return "", errors.Join(err, errors.New("failed when uploading build"))
+		return "", nil, errors.Join(err, errors.New("failed when uploading build"))
 	}
 	fs.log.Debug().Msg(uploadedBuild)
-	return uploadedBuild, nil
+	return uploadedBuild, res.Stats, nil
+}
+
+// snapshotStatsFromPoB maps the PlayerStat outputs reported by the PoB worker
+// to the columns stored in snapshot_stats.
+func snapshotStatsFromPoB(level int, stats map[string]float64) *models.SnapshotStats {
+	stat := func(key string) *float64 {
+		v, ok := stats[key]
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+
+	return &models.SnapshotStats{
+		Level:           level,
+		CombinedDPS:     stat("CombinedDPS"),
+		Life:            stat("Life"),
+		EnergyShield:    stat("EnergyShield"),
+		Mana:            stat("Mana"),
+		TotalEHP:        stat("TotalEHP"),
+		PhysicalMaxHit:  stat("PhysicalMaximumHitTaken"),
+		FireMaxHit:      stat("FireMaximumHitTaken"),
+		ColdMaxHit:      stat("ColdMaximumHitTaken"),
+		LightningMaxHit: stat("LightningMaximumHitTaken"),
+		ChaosMaxHit:     stat("ChaosMaximumHitTaken"),
+		FireResist:      stat("FireResist"),
+		ColdResist:      stat("ColdResist"),
+		LightningResist: stat("LightningResist"),
+		ChaosResist:     stat("ChaosResist"),
+		MovementSpeed:   stat("EffectiveMovementSpeedMod"),
+	}
 }
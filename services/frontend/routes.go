@@ -3,129 +3,726 @@ package frontend
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/ByChanderZap/exile-tracker/analytics"
+	"github.com/ByChanderZap/exile-tracker/auth"
 	"github.com/ByChanderZap/exile-tracker/cmd/web/templates"
+	"github.com/ByChanderZap/exile-tracker/history"
 	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
 	"github.com/ByChanderZap/exile-tracker/repository"
 	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/ByChanderZap/exile-tracker/utils/query"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 )
 
 type Handler struct {
-	repository *repository.Repository
-	log        zerolog.Logger
+	repository        *repository.Repository
+	trees             *passivetree.Registry
+	allowRegistration bool
+	requireLogin      bool
+	watchFetchLimit   int
+	log               zerolog.Logger
 }
 
-func NewHandler(db *repository.Repository, logger zerolog.Logger) *Handler {
+// NewHandler serves the web UI. With requireLogin every page showing
+// tracked data needs a logged in user, the counterpart of API keys being
+// required for API reads. Watching enrolls up to watchFetchLimit characters
+// of each user for fetching.
+func NewHandler(db *repository.Repository, trees *passivetree.Registry, allowRegistration bool, requireLogin bool, watchFetchLimit int, logger zerolog.Logger) *Handler {
 	return &Handler{
-		repository: db,
-		log:        logger,
+		repository:        db,
+		trees:             trees,
+		allowRegistration: allowRegistration,
+		requireLogin:      requireLogin,
+		watchFetchLimit:   watchFetchLimit,
+		log:               logger,
 	}
 }
 
 func (h *Handler) RegisterRoutes(router *chi.Mux) {
 	router.Use(utils.ZerologMiddleware(h.log))
+	router.Use(auth.SessionMiddleware(h.repository, h.log))
 	fs := http.FileServer(http.Dir("cmd/web/static"))
 	router.Handle("/static/*", http.StripPrefix("/static/", fs))
 
-	router.Get("/", h.handleHomePage)
-	router.Get("/search", h.handleSearchAccounts)
-	// show characters by accound and search characters within an account
-	router.Get("/accounts/{accountId}/characters", h.handleCharactersByAccount)
-	router.Get("/accounts/{accountId}/characters/search", h.handleCharactersSearchByAccount)
-	router.Get("/snapshots/{characterId}", h.handleLoadedSnapshotsByCharacter)
+	router.Group(func(r chi.Router) {
+		if h.requireLogin {
+			r.Use(auth.RequireUser)
+		}
+		r.Get("/", h.handleHomePage)
+		r.Get("/search", h.handleSearchAccounts)
+		// show characters by accound and search characters within an account
+		r.Get("/accounts/{accountId}/characters", h.handleCharactersByAccount)
+		r.Get("/accounts/{accountId}/characters/search", h.handleCharactersSearchByAccount)
+		r.Get("/snapshots/{characterId}", h.handleLoadedSnapshotsByCharacter)
+		r.Get("/snapshots/{characterId}/rows", h.handleSnapshotRows)
+		r.Get("/snapshots/{characterId}/tree", h.handleSnapshotTree)
+		r.Get("/characters/{characterId}/progression", h.handleCharacterProgression)
+		r.Get("/characters/{characterId}/items", h.handleCharacterItems)
+		r.Get("/characters/{characterId}/skills", h.handleCharacterSkills)
+		r.Get("/characters/{characterId}/passives", h.handleCharacterPassives)
+		r.Get("/characters/{characterId}/jewels", h.handleCharacterJewels)
+		r.Get("/characters/{characterId}/flasks", h.handleCharacterFlasks)
+		r.Get("/characters/{characterId}/upgrades", h.handleCharacterUpgrades)
+		r.Get("/characters/{characterId}/leveling", h.handleCharacterLeveling)
+		r.Get("/analytics", h.handleAnalytics)
+	})
+
+	router.Get("/login", h.handleLoginPage)
+	router.Post("/login", h.handleLogin)
+	router.Get("/register", h.handleRegisterPage)
+	router.Post("/register", h.handleRegister)
+	router.Post("/logout", h.handleLogout)
+	router.Group(func(r chi.Router) {
+		r.Use(auth.RequireUser)
+		r.Get("/watchlist", h.handleWatchlist)
+		r.Post("/watchlist/accounts/{accountId}", h.handleWatchAccount)
+		r.Post("/watchlist/accounts/{accountId}/unwatch", h.handleUnwatchAccount)
+		r.Post("/watchlist/characters/{characterId}", h.handleWatchCharacter)
+		r.Post("/watchlist/characters/{characterId}/unwatch", h.handleUnwatchCharacter)
+	})
 }
 
 func (h *Handler) handleHomePage(w http.ResponseWriter, r *http.Request) {
 	// templ.Handler(templates.Main("alex"))
 
-	accounts, err := h.repository.GetAllAccounts()
+	params, err := query.AccountListParams(r)
 	if err != nil {
-		h.log.Error().Err(err).Msg("Query to get accounts failed")
-		http.Error(w, "Filed to load accounts", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	scope := r.URL.Query().Get("scope")
+	accounts, ok := h.listAccounts(w, r, params, scope)
+	if !ok {
+		return
+	}
+
+	leagues, ok := h.loadLeagues(w)
+	if !ok {
 		return
 	}
 
-	templates.Main(accounts, utils.StringValue).Render(r.Context(), w)
+	if _, ok := auth.UserFromContext(r.Context()); !ok {
+		scope = "all"
+	}
+	templates.Main(accounts, moreURL(r, "/search", accounts.NextCursor), leagues, scope, utils.StringValue).Render(r.Context(), w)
 }
 
+// handleSearchAccounts answers the account table filters with the whole
+// table, and its load more button with the next rows only.
 func (h *Handler) handleSearchAccounts(w http.ResponseWriter, r *http.Request) {
-	searchTerm := r.URL.Query().Get("q")
+	params, err := query.AccountListParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	accounts, ok := h.listAccounts(w, r, params, r.URL.Query().Get("scope"))
+	if !ok {
+		return
+	}
 
-	var accounts []models.Account
-	var err error
+	more := moreURL(r, "/search", accounts.NextCursor)
+	if params.Cursor != "" {
+		templates.AccountRows(accounts.Items, more, utils.StringValue).Render(r.Context(), w)
+		return
+	}
+	templates.AccountsTable(accounts, more, utils.StringValue).Render(r.Context(), w)
+}
 
-	if searchTerm == "" {
-		accounts, err = h.repository.GetAllAccounts()
-	} else {
-		accounts, err = h.repository.SearchAccounts(searchTerm)
+// listAccounts lists the accounts the logged in user watches, or every
+// account for anonymous visitors and when scope is "all".
+func (h *Handler) listAccounts(w http.ResponseWriter, r *http.Request, params repository.ListAccountsParams, scope string) (models.Page[models.Account], bool) {
+	if user, ok := auth.UserFromContext(r.Context()); ok && scope != "all" {
+		params.WatchedBy = user.ID
+	}
+	accounts, err := h.repository.ListAccounts(params)
+	if err != nil {
+		h.respondListError(w, err, "accounts")
+		return models.Page[models.Account]{}, false
 	}
+	return accounts, true
+}
+
+func (h *Handler) handleCharactersByAccount(w http.ResponseWriter, r *http.Request) {
+	accId := chi.URLParam(r, "accountId")
 
+	params, err := query.CharacterListParams(r)
 	if err != nil {
-		h.log.Error().Err(err).Msg("Query to search accounts failed")
-		http.Error(w, "Failed to search accounts", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params.AccountId = accId
+	cs, err := h.repository.ListCharacters(params)
+	if err != nil {
+		h.respondListError(w, err, "characters")
 		return
 	}
 
-	templates.AccountsTable(accounts, utils.StringValue).Render(r.Context(), w)
+	leagues, ok := h.loadLeagues(w)
+	if !ok {
+		return
+	}
+	watchedAccounts, watchedCharacters, err := h.watchedIDs(r)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get watchlist failed")
+		http.Error(w, "Failed to load watchlist", http.StatusInternalServerError)
+		return
+	}
+	more := moreURL(r, fmt.Sprintf("/accounts/%s/characters/search", accId), cs.NextCursor)
+	templates.CharactersByAccountId(cs, more, accId, leagues, watchedAccounts[accId], watchedCharacters, utils.StringValue).Render(r.Context(), w)
 }
 
-func (h *Handler) handleCharactersByAccount(w http.ResponseWriter, r *http.Request) {
+// handleCharactersSearchByAccount answers the character table filters with
+// the whole table, and its load more button with the next rows only.
+func (h *Handler) handleCharactersSearchByAccount(w http.ResponseWriter, r *http.Request) {
 	accId := chi.URLParam(r, "accountId")
 
-	cs, err := h.repository.GetCharactersByAccountId(accId)
+	params, err := query.CharacterListParams(r)
 	if err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "No characters to show", http.StatusNotFound)
-			return
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params.AccountId = accId
+	characters, err := h.repository.ListCharacters(params)
+	if err != nil {
+		h.respondListError(w, err, "characters")
+		return
+	}
+
+	_, watched, err := h.watchedIDs(r)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get watchlist failed")
+		http.Error(w, "Failed to load watchlist", http.StatusInternalServerError)
+		return
+	}
+	more := moreURL(r, fmt.Sprintf("/accounts/%s/characters/search", accId), characters.NextCursor)
+	if params.Cursor != "" {
+		templates.CharacterRows(characters.Items, watched, more, utils.StringValue).Render(r.Context(), w)
+		return
+	}
+	templates.CharactersTable(characters, watched, more, utils.StringValue).Render(r.Context(), w)
+}
+
+func (h *Handler) handleLoadedSnapshotsByCharacter(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+	league := r.URL.Query().Get("league")
+
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
+
+	params, ok := snapshotListParams(w, r, cId)
+	if !ok {
+		return
+	}
+	page, err := h.repository.ListSnapshots(params)
+	if err != nil {
+		h.respondListError(w, err, "snapshots")
+		return
+	}
+
+	leagues, ok := h.loadLeagues(w)
+	if !ok {
+		return
+	}
+
+	more := moreURL(r, fmt.Sprintf("/snapshots/%s/rows", cId), page.NextCursor)
+	templates.SnapshotsPage(c, acc.AccountName, page, more, leagues, league, params.Desc, utils.StringValue).Render(r.Context(), w)
+}
+
+// handleSnapshotRows answers the load more button of the snapshots table.
+func (h *Handler) handleSnapshotRows(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+	params, ok := snapshotListParams(w, r, cId)
+	if !ok {
+		return
+	}
+	page, err := h.repository.ListSnapshots(params)
+	if err != nil {
+		h.respondListError(w, err, "snapshots")
+		return
+	}
+	templates.SnapshotRows(page.Items, moreURL(r, fmt.Sprintf("/snapshots/%s/rows", cId), page.NextCursor)).Render(r.Context(), w)
+}
+
+// snapshotListParams reads the snapshot table filters. Unlike the API the
+// table shows the newest snapshots first unless asked otherwise.
+func snapshotListParams(w http.ResponseWriter, r *http.Request, characterId string) (repository.ListSnapshotsParams, bool) {
+	params, err := query.SnapshotListParams(r, characterId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return repository.ListSnapshotsParams{}, false
+	}
+	if r.URL.Query().Get("order") == "" {
+		params.Desc = true
+	}
+	return params, true
+}
+
+func (h *Handler) respondListError(w http.ResponseWriter, err error, what string) {
+	if repository.IsInvalidPage(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.log.Error().Err(err).Msgf("Query to list %s failed", what)
+	http.Error(w, "Failed to load "+what, http.StatusInternalServerError)
+}
+
+// moreURL is the address of the page after the current one, path being the
+// endpoint rendering only rows. It is empty on the last page.
+func moreURL(r *http.Request, path string, nextCursor string) string {
+	if nextCursor == "" {
+		return ""
+	}
+	q := r.URL.Query()
+	q.Set("cursor", nextCursor)
+	return path + "?" + q.Encode()
+}
+
+func (h *Handler) loadLeagues(w http.ResponseWriter) ([]models.League, bool) {
+	leagues, err := h.repository.GetAllLeagues()
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get leagues failed")
+		http.Error(w, "Failed to load leagues", http.StatusInternalServerError)
+		return nil, false
+	}
+	return leagues, true
+}
+
+// loadCharacter fetches a character and its account, answering the request
+// with an error when either can't be loaded.
+func (h *Handler) loadCharacter(w http.ResponseWriter, characterId string) (models.Character, models.Account, bool) {
+	c, err := h.repository.GetCharacterByID(characterId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Character not found", http.StatusNotFound)
+			return models.Character{}, models.Account{}, false
 		}
-		h.log.Error().Err(err).Msg("Query to get all characters by account id failed")
-		http.Error(w, "Failed to load characters", http.StatusBadRequest)
+		h.log.Error().Err(err).Msg("Query to get character failed")
+		http.Error(w, "Failed to load character", http.StatusInternalServerError)
+		return models.Character{}, models.Account{}, false
+	}
+
+	acc, err := h.repository.GetAccountByID(c.AccountId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get account failed")
+		http.Error(w, "Failed to load account", http.StatusInternalServerError)
+		return models.Character{}, models.Account{}, false
+	}
+	return c, acc, true
+}
+
+func (h *Handler) handleCharacterProgression(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
+
+	stats, err := h.repository.GetSnapshotStatsByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot stats failed")
+		http.Error(w, "Failed to load snapshot stats", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.repository.GetSnapshotDataByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
+	}
+
+	ladder, err := h.repository.GetLadderEntriesByCharacter(cId, "")
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get ladder entries failed")
+		http.Error(w, "Failed to load ladder ranks", http.StatusInternalServerError)
 		return
 	}
-	templates.CharactersByAccountId(cs, accId, utils.StringValue).Render(r.Context(), w)
+
+	annotations := majorChangeAnnotations(data)
+	charts := append(progressionCharts(stats, data, annotations), ladderCharts(ladder, data, annotations)...)
+	templates.ProgressionPage(c, acc.AccountName, charts).Render(r.Context(), w)
 }
 
-func (h *Handler) handleCharactersSearchByAccount(w http.ResponseWriter, r *http.Request) {
-	accId := chi.URLParam(r, "accountId")
-	searchTerm := r.URL.Query().Get("q")
+func (h *Handler) handleCharacterItems(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
 
-	var characters []models.Character
-	var err error
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
 
-	if searchTerm == "" {
-		characters, err = h.repository.GetCharactersByAccountId(accId)
-	} else {
-		characters, err = h.repository.SearchCharactersInAccount(repository.SearchCharactersInAccountParams{
-			AccountId: accId,
-			Query:     searchTerm,
-		})
+	data, err := h.repository.GetSnapshotDataByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
 	}
 
+	templates.ItemTimelinePage(c, acc.AccountName, history.ItemTimeline(data)).Render(r.Context(), w)
+}
+
+func (h *Handler) handleCharacterLeveling(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
+
+	samples, err := h.repository.GetExperienceSamplesByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get experience samples failed")
+		http.Error(w, "Failed to load experience samples", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.repository.GetSnapshotDataByCharacter(cId)
 	if err != nil {
-		h.log.Error().Err(err).Msg("Query to search characters in account failed")
-		http.Error(w, "Failed to search characters by account", http.StatusInternalServerError)
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
 		return
 	}
 
-	templates.CharactersTable(characters, utils.StringValue).Render(r.Context(), w)
+	chart := experienceChart(samples, data, majorChangeAnnotations(data))
+	templates.LevelingPage(c, acc.AccountName, history.Leveling(samples), chart, changesByLevel(data)).Render(r.Context(), w)
 }
 
-func (h *Handler) handleLoadedSnapshotsByCharacter(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleCharacterFlasks(w http.ResponseWriter, r *http.Request) {
 	cId := chi.URLParam(r, "characterId")
 
-	snaps, err := h.repository.GetSnapshotsByCharacterWithExtras(repository.GetSnapshotsByCharacterWithExtras{
-		CharacterId: cId,
-	})
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
+
+	data, err := h.repository.GetSnapshotDataByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
+	}
+
+	templates.FlaskTimelinePage(c, acc.AccountName, history.FlaskTimeline(data)).Render(r.Context(), w)
+}
+
+func (h *Handler) handleCharacterUpgrades(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+
+	params, err := query.UpgradeParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
 
+	var missing string
+	report, err := analytics.BuildUpgradeReport(h.repository, h.trees, cId, params)
+	switch {
+	case errors.Is(err, analytics.ErrNoSnapshots):
+		missing = "No snapshot data stored for this character yet"
+	case errors.Is(err, analytics.ErrNoReferenceStat):
+		missing = fmt.Sprintf("No %s stored for the latest snapshot of this character", strings.ReplaceAll(params.Stat, "_", " "))
+	case err != nil:
+		h.log.Error().Err(err).Msg("Failed to build upgrade report")
+		http.Error(w, "Failed to build upgrade report", http.StatusInternalServerError)
+		return
+	}
+
+	templates.UpgradesPage(c, acc.AccountName, params, report, missing).Render(r.Context(), w)
+}
+
+func (h *Handler) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	filter := query.AnalyticsFilter(r)
+	trend, ok := analytics.ParseCategory(r.URL.Query().Get("trend"))
+	if !ok {
+		trend = analytics.Uniques
+	}
+
+	summaries, err := analytics.LoadSummaries(h.repository, h.trees)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
+	}
+
+	periods := analytics.Timeline(summaries, filter, 7*24*time.Hour)
+	templates.AnalyticsPage(templates.AnalyticsView{
+		Filter:   filter,
+		Leagues:  analytics.Leagues(summaries),
+		Overview: analytics.Latest(summaries, filter).Top(15),
+		Trend:    trend,
+		Periods:  periods,
+		Trends:   analytics.Trends(periods, trend, 10),
+	}).Render(r.Context(), w)
+}
+
+func (h *Handler) handleCharacterSkills(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
+
+	data, err := h.repository.GetSnapshotDataByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
+	}
+
+	templates.SkillHistoryPage(c, acc.AccountName, history.SkillHistory(data)).Render(r.Context(), w)
+}
+
+func (h *Handler) handleCharacterPassives(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
+
+	data, err := h.repository.GetSnapshotDataByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
+	}
+
+	templates.PassiveHistoryPage(c, acc.AccountName, history.PassiveHistory(data, h.trees)).Render(r.Context(), w)
+}
+
+func (h *Handler) handleCharacterJewels(w http.ResponseWriter, r *http.Request) {
+	cId := chi.URLParam(r, "characterId")
+
+	c, acc, ok := h.loadCharacter(w, cId)
+	if !ok {
+		return
+	}
+
+	data, err := h.repository.GetSnapshotDataByCharacter(cId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
+	}
+
+	templates.JewelsPage(c, acc.AccountName, history.JewelsHistory(data, h.trees)).Render(r.Context(), w)
+}
+
+// handleSnapshotTree renders the passive tree of ?snapshot=. With ?compare=
+// the nodes gained and lost since that other snapshot are highlighted.
+func (h *Handler) handleSnapshotTree(w http.ResponseWriter, r *http.Request) {
+	snapshotId := r.URL.Query().Get("snapshot")
+	compareId := r.URL.Query().Get("compare")
+
+	d, err := h.repository.GetSnapshotData(snapshotId)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "No snapshots found", http.StatusNotFound)
+			templates.PassiveTreeSVG(passivetree.Drawing{}, false).Render(r.Context(), w)
+			return
+		}
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		http.Error(w, "Failed to load snapshot data", http.StatusInternalServerError)
+		return
+	}
+
+	tree := h.trees.ForLeague(d.Items.Character.League).WithSubgraphs(d.Passives.JewelData)
+	allocated := passivetree.AllocatedHashes(d.Passives)
+
+	var previous map[int]bool
+	if compareId != "" && compareId != snapshotId {
+		other, err := h.repository.GetSnapshotData(compareId)
+		if err != nil {
+			h.log.Error().Err(err).Msg("Query to get snapshot data to compare failed")
+			http.Error(w, "Failed to load snapshot to compare", http.StatusBadRequest)
+			return
+		}
+		tree = tree.WithSubgraphs(other.Passives.JewelData)
+		previous = passivetree.AllocatedHashes(other.Passives)
+		// Always show the change going forward in time
+		if other.CreatedAt.After(d.CreatedAt) {
+			allocated, previous = previous, allocated
+		}
+	}
+
+	templates.PassiveTreeSVG(tree.Draw(allocated, previous), previous != nil).Render(r.Context(), w)
+}
+
+// majorChangeAnnotations labels the snapshots where major item or gem
+// changes happened.
+func majorChangeAnnotations(data []models.SnapshotData) []templates.ChartAnnotation {
+	var annotations []templates.ChartAnnotation
+	for i := 1; i < len(data); i++ {
+		major := history.MajorChanges(history.Diff(data[i-1].Items, data[i].Items))
+		if len(major) == 0 {
+			continue
+		}
+		labels := make([]string, len(major))
+		for j, c := range major {
+			labels[j] = c.String()
+		}
+		annotations = append(annotations, templates.ChartAnnotation{
+			Key:   data[i].SnapshotId,
+			Label: strings.Join(labels, "\n"),
+		})
+	}
+	return annotations
+}
+
+// progressionCharts builds one chart per tracked stat, annotated with the
+// snapshots where major item or gem changes happened.
+func progressionCharts(stats []models.SnapshotStats, data []models.SnapshotData, annotations []templates.ChartAnnotation) []templates.LineChart {
+	takenAt := make(map[string]time.Time, len(data))
+	for _, d := range data {
+		takenAt[d.SnapshotId] = d.CreatedAt
+	}
+
+	keys := make([]string, len(stats))
+	times := make([]time.Time, len(stats))
+	level := make([]*float64, len(stats))
+	for i, s := range stats {
+		keys[i] = s.SnapshotId
+		t, ok := takenAt[s.SnapshotId]
+		if !ok {
+			t = s.CreatedAt
+		}
+		times[i] = t
+		lvl := float64(s.Level)
+		level[i] = &lvl
+	}
+
+	series := func(title string, value func(models.SnapshotStats) *float64) templates.LineChart {
+		values := make([]*float64, len(stats))
+		for i, s := range stats {
+			values[i] = value(s)
+		}
+		return templates.NewLineChart(templates.ChartSeries{Title: title, Keys: keys, Times: times, Values: values}, annotations)
+	}
+
+	return []templates.LineChart{
+		templates.NewLineChart(templates.ChartSeries{Title: "Level", Keys: keys, Times: times, Values: level}, annotations),
+		series("Life", func(s models.SnapshotStats) *float64 { return s.Life }),
+		series("Energy Shield", func(s models.SnapshotStats) *float64 { return s.EnergyShield }),
+		series("Combined DPS", func(s models.SnapshotStats) *float64 { return s.CombinedDPS }),
+		series("Effective Hit Pool", func(s models.SnapshotStats) *float64 { return s.TotalEHP }),
+	}
+}
+
+// experienceChart charts experience over every fetch, marking the level the
+// character had when major changes happened.
+func experienceChart(samples []models.ExperienceSample, data []models.SnapshotData, annotations []templates.ChartAnnotation) templates.LineChart {
+	keys := make([]string, len(samples))
+	times := make([]time.Time, len(samples))
+	values := make([]*float64, len(samples))
+	for i, s := range samples {
+		keys[i] = s.ID
+		times[i] = s.RecordedAt
+		xp := float64(s.Experience)
+		values[i] = &xp
+	}
+	moved := moveAnnotations(keys, times, data, annotations, func(i int) string {
+		return fmt.Sprintf("Level %d", samples[i].Level)
+	})
+	return templates.NewLineChart(templates.ChartSeries{Title: "Experience", Keys: keys, Times: times, Values: values}, moved)
+}
+
+// changesByLevel groups major item and gem changes by the level the
+// character was at when they happened.
+func changesByLevel(data []models.SnapshotData) map[int][]string {
+	changes := make(map[int][]string)
+	for i := 1; i < len(data); i++ {
+		level := data[i].Items.Character.Level
+		for _, c := range history.MajorChanges(history.Diff(data[i-1].Items, data[i].Items)) {
+			changes[level] = append(changes[level], c.String())
 		}
-		http.Error(w, "something went wrong", http.StatusBadRequest)
+	}
+	return changes
+}
+
+// ladderCharts charts the ladder rank of a character, and its delve depth
+// when the league has a Delve ladder. Snapshot annotations show where on the
+// ladder the character was when the change happened.
+func ladderCharts(entries []models.LadderEntry, data []models.SnapshotData, annotations []templates.ChartAnnotation) []templates.LineChart {
+	if len(entries) == 0 {
+		return nil
 	}
 
-	templates.SnapshotsPage(snaps, utils.StringValue).Render(r.Context(), w)
+	keys := make([]string, len(entries))
+	times := make([]time.Time, len(entries))
+	ranks := make([]*float64, len(entries))
+	depths := make([]*float64, len(entries))
+	hasDepth := false
+	for i, e := range entries {
+		keys[i] = e.ID
+		times[i] = e.RecordedAt
+		rank := float64(e.Rank)
+		ranks[i] = &rank
+		if e.Depth != nil {
+			depth := float64(*e.Depth)
+			depths[i] = &depth
+			hasDepth = true
+		}
+	}
+
+	rankAnnotations := moveAnnotations(keys, times, data, annotations, func(i int) string {
+		return fmt.Sprintf("Rank %d", entries[i].Rank)
+	})
+
+	charts := []templates.LineChart{
+		templates.NewLineChart(templates.ChartSeries{Title: "Ladder rank", Keys: keys, Times: times, Values: ranks, Inverted: true}, rankAnnotations),
+	}
+	if hasDepth {
+		charts = append(charts, templates.NewLineChart(templates.ChartSeries{Title: "Delve depth", Keys: keys, Times: times, Values: depths}, rankAnnotations))
+	}
+	return charts
+}
+
+// moveAnnotations attaches snapshot annotations to the last sample of
+// another series taken at or before the snapshot. Annotations landing on the
+// same sample are merged under the header returned for it.
+func moveAnnotations(keys []string, times []time.Time, data []models.SnapshotData, annotations []templates.ChartAnnotation, header func(i int) string) []templates.ChartAnnotation {
+	takenAt := make(map[string]time.Time, len(data))
+	for _, d := range data {
+		takenAt[d.SnapshotId] = d.CreatedAt
+	}
+
+	labels := make(map[int][]string)
+	var order []int
+	for _, a := range annotations {
+		t, ok := takenAt[a.Key]
+		if !ok {
+			continue
+		}
+		idx := sort.Search(len(times), func(i int) bool { return times[i].After(t) }) - 1
+		if idx < 0 {
+			continue
+		}
+		if _, seen := labels[idx]; !seen {
+			order = append(order, idx)
+			labels[idx] = []string{header(idx)}
+		}
+		labels[idx] = append(labels[idx], a.Label)
+	}
+
+	moved := make([]templates.ChartAnnotation, 0, len(order))
+	for _, idx := range order {
+		moved = append(moved, templates.ChartAnnotation{Key: keys[idx], Label: strings.Join(labels[idx], "\n")})
+	}
+	return moved
 }
@@ -0,0 +1,282 @@
+package frontend
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/auth"
+	"github.com/ByChanderZap/exile-tracker/cmd/web/templates"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	templates.LoginPage("", "", safeNext(r.URL.Query().Get("next")), h.allowRegistration).Render(r.Context(), w)
+}
+
+func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	next := safeNext(r.FormValue("next"))
+
+	user, err := h.repository.GetUserByUsername(username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.log.Error().Err(err).Msg("Query to get user failed")
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !auth.CheckPassword(user.PasswordHash, password) {
+		w.WriteHeader(http.StatusUnauthorized)
+		templates.LoginPage("Wrong username or password", username, next, h.allowRegistration).Render(r.Context(), w)
+		return
+	}
+
+	if err := auth.StartSession(w, r, h.repository, user.ID); err != nil {
+		h.log.Error().Err(err).Msg("Failed to create session")
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (h *Handler) handleRegisterPage(w http.ResponseWriter, r *http.Request) {
+	if !h.allowRegistration {
+		http.Error(w, "Registration is closed", http.StatusForbidden)
+		return
+	}
+	templates.RegisterPage("", "").Render(r.Context(), w)
+}
+
+func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if !h.allowRegistration {
+		http.Error(w, "Registration is closed", http.StatusForbidden)
+		return
+	}
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+
+	if err := auth.ValidateCredentials(username, password); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		templates.RegisterPage(err.Error(), username).Render(r.Context(), w)
+		return
+	}
+
+	_, err := h.repository.GetUserByUsername(username)
+	if err == nil {
+		w.WriteHeader(http.StatusConflict)
+		templates.RegisterPage("Username is already taken", username).Render(r.Context(), w)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		h.log.Error().Err(err).Msg("Query to get user failed")
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Failed to hash password")
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+	user, err := h.repository.CreateUser(username, hash)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to create user failed")
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+
+	if err := auth.StartSession(w, r, h.repository, user.ID); err != nil {
+		h.log.Error().Err(err).Msg("Failed to create session")
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if err := auth.EndSession(w, r, h.repository); err != nil {
+		h.log.Error().Err(err).Msg("Failed to delete session")
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *Handler) handleWatchlist(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	entries, err := h.repository.GetWatchlist(user.ID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get watchlist failed")
+		http.Error(w, "Failed to load watchlist", http.StatusInternalServerError)
+		return
+	}
+	templates.WatchlistPage(entries, utils.StringValue).Render(r.Context(), w)
+}
+
+func (h *Handler) handleWatchAccount(w http.ResponseWriter, r *http.Request) {
+	h.setAccountWatched(w, r, true)
+}
+
+func (h *Handler) handleUnwatchAccount(w http.ResponseWriter, r *http.Request) {
+	h.setAccountWatched(w, r, false)
+}
+
+func (h *Handler) handleWatchCharacter(w http.ResponseWriter, r *http.Request) {
+	h.setCharacterWatched(w, r, true)
+}
+
+func (h *Handler) handleUnwatchCharacter(w http.ResponseWriter, r *http.Request) {
+	h.setCharacterWatched(w, r, false)
+}
+
+// setAccountWatched watches or unwatches a whole account. Watching it
+// enrolls its living characters for fetching, within the user's limit.
+func (h *Handler) setAccountWatched(w http.ResponseWriter, r *http.Request, watched bool) {
+	user, _ := auth.UserFromContext(r.Context())
+	accId := chi.URLParam(r, "accountId")
+
+	if _, err := h.repository.GetAccountByID(accId); err != nil {
+		h.respondLoadError(w, err, "Account")
+		return
+	}
+
+	params := repository.WatchParams{UserId: user.ID, AccountId: accId}
+	if !watched {
+		if err := h.repository.RemoveFromWatchlist(params); err != nil {
+			h.log.Error().Err(err).Msg("Query to unwatch account failed")
+			http.Error(w, "Failed to update watchlist", http.StatusInternalServerError)
+			return
+		}
+		h.respondWatched(w, r, fmt.Sprintf("/watchlist/accounts/%s", accId), false)
+		return
+	}
+
+	if err := h.repository.AddToWatchlist(params); err != nil {
+		h.log.Error().Err(err).Msg("Query to watch account failed")
+		http.Error(w, "Failed to update watchlist", http.StatusInternalServerError)
+		return
+	}
+	characters, err := h.repository.GetCharactersByAccountId(accId, "")
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get characters by account id failed")
+		http.Error(w, "Failed to update watchlist", http.StatusInternalServerError)
+		return
+	}
+	var ids []string
+	for _, c := range characters {
+		if !c.Died {
+			ids = append(ids, c.ID)
+		}
+	}
+	if err := h.enroll(user.ID, ids); err != nil {
+		h.log.Error().Err(err).Msg("Failed to enroll watched characters")
+		http.Error(w, "Failed to update watchlist", http.StatusInternalServerError)
+		return
+	}
+	h.respondWatched(w, r, fmt.Sprintf("/watchlist/accounts/%s", accId), true)
+}
+
+// setCharacterWatched watches or unwatches one character, enrolling it for
+// fetching like setAccountWatched.
+func (h *Handler) setCharacterWatched(w http.ResponseWriter, r *http.Request, watched bool) {
+	user, _ := auth.UserFromContext(r.Context())
+	cId := chi.URLParam(r, "characterId")
+
+	c, err := h.repository.GetCharacterByID(cId)
+	if err != nil {
+		h.respondLoadError(w, err, "Character")
+		return
+	}
+
+	params := repository.WatchParams{UserId: user.ID, AccountId: c.AccountId, CharacterId: &c.ID}
+	if watched {
+		err = h.repository.AddToWatchlist(params)
+	} else {
+		err = h.repository.RemoveFromWatchlist(params)
+	}
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to update watchlist failed")
+		http.Error(w, "Failed to update watchlist", http.StatusInternalServerError)
+		return
+	}
+	if watched && !c.Died {
+		if err := h.enroll(user.ID, []string{c.ID}); err != nil {
+			h.log.Error().Err(err).Msg("Failed to enroll watched character")
+			http.Error(w, "Failed to update watchlist", http.StatusInternalServerError)
+			return
+		}
+	}
+	h.respondWatched(w, r, fmt.Sprintf("/watchlist/characters/%s", c.ID), watched)
+}
+
+// enroll makes sure watched characters are fetched while the user has fewer
+// than watchFetchLimit watched characters tracked, fetching costs PoE API
+// calls and PoB time every cycle. Characters already tracked by another
+// user or the ladder import are left alone, and unwatching never stops
+// fetching since others may rely on it.
+func (h *Handler) enroll(userId string, characterIds []string) error {
+	if h.watchFetchLimit <= 0 {
+		return nil
+	}
+	tracked, err := h.repository.CountWatchedTracked(userId)
+	if err != nil {
+		return err
+	}
+	for _, id := range characterIds {
+		if tracked >= h.watchFetchLimit {
+			h.log.Info().Str("user_id", userId).Int("limit", h.watchFetchLimit).Msg("Watch fetch limit reached, not enrolling more characters")
+			return nil
+		}
+		added, err := h.repository.EnsureCharacterToFetch(id)
+		if err != nil {
+			return err
+		}
+		if added {
+			tracked++
+			h.log.Info().Str("character_id", id).Msg("Watched character enrolled for fetching")
+		}
+	}
+	return nil
+}
+
+// respondWatched answers htmx requests with the updated watch button and
+// sends plain form posts back where they came from.
+func (h *Handler) respondWatched(w http.ResponseWriter, r *http.Request, path string, watched bool) {
+	if r.Header.Get("HX-Request") == "true" {
+		templates.WatchButton(path, watched).Render(r.Context(), w)
+		return
+	}
+	http.Redirect(w, r, "/watchlist", http.StatusSeeOther)
+}
+
+func (h *Handler) respondLoadError(w http.ResponseWriter, err error, what string) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, what+" not found", http.StatusNotFound)
+		return
+	}
+	h.log.Error().Err(err).Msgf("Query to get %s failed", strings.ToLower(what))
+	http.Error(w, "Failed to load "+strings.ToLower(what), http.StatusInternalServerError)
+}
+
+// watchedIDs returns what the request's user watches, empty for anonymous
+// visitors.
+func (h *Handler) watchedIDs(r *http.Request) (map[string]bool, map[string]bool, error) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		return map[string]bool{}, map[string]bool{}, nil
+	}
+	return h.repository.GetWatchedIDs(user.ID)
+}
+
+// safeNext only allows redirects to paths on this site after logging in.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,166 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/history"
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+type Handler struct {
+	repository *repository.Repository
+	trees      *passivetree.Registry
+	log        zerolog.Logger
+}
+
+func NewHandler(db *repository.Repository, trees *passivetree.Registry, logger zerolog.Logger) *Handler {
+	return &Handler{
+		repository: db,
+		trees:      trees,
+		log:        logger,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *chi.Mux) {
+	router.Get("/history/characters/{characterId}/items", h.handleGetItemTimeline)
+	router.Get("/history/characters/{characterId}/skills", h.handleGetSkillHistory)
+	router.Get("/history/characters/{characterId}/passives", h.handleGetPassiveHistory)
+	router.Get("/history/characters/{characterId}/jewels", h.handleGetJewelHistory)
+	router.Get("/history/characters/{characterId}/flasks", h.handleGetFlaskTimeline)
+	router.Get("/history/characters/{characterId}/leveling", h.handleGetLeveling)
+	router.Get("/history/leveling", h.handleCompareLeveling)
+	router.Get("/history/snapshots/{snapshotId}/jewels", h.handleGetSnapshotJewels)
+}
+
+func (h *Handler) handleGetLeveling(w http.ResponseWriter, r *http.Request) {
+	characterId := chi.URLParam(r, "characterId")
+	if _, err := h.repository.GetCharacterByID(characterId); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	samples, err := h.repository.GetExperienceSamplesByCharacter(characterId)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history.Leveling(samples))
+}
+
+type characterLeveling struct {
+	CharacterId   string                 `json:"character_id"`
+	CharacterName string                 `json:"character_name"`
+	Leveling      history.LevelingReport `json:"leveling"`
+}
+
+// handleCompareLeveling returns the leveling report of several characters,
+// given as a comma separated ?characters= list.
+func (h *Handler) handleCompareLeveling(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("characters"), ",")
+	var reports []characterLeveling
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		c, err := h.repository.GetCharacterByID(id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				apierror.Write(w, r, apierror.NotFound("character %s not found", id))
+				return
+			}
+			apierror.Write(w, r, err)
+			return
+		}
+		samples, err := h.repository.GetExperienceSamplesByCharacter(id)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		reports = append(reports, characterLeveling{
+			CharacterId:   c.ID,
+			CharacterName: c.CharacterName,
+			Leveling:      history.Leveling(samples),
+		})
+	}
+	if len(reports) == 0 {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("characters is required")))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, reports)
+}
+
+func (h *Handler) handleGetItemTimeline(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.loadSnapshotData(w, r, chi.URLParam(r, "characterId"))
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history.ItemTimeline(data))
+}
+
+func (h *Handler) handleGetFlaskTimeline(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.loadSnapshotData(w, r, chi.URLParam(r, "characterId"))
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history.FlaskTimeline(data))
+}
+
+func (h *Handler) handleGetSkillHistory(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.loadSnapshotData(w, r, chi.URLParam(r, "characterId"))
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history.SkillHistory(data))
+}
+
+func (h *Handler) handleGetPassiveHistory(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.loadSnapshotData(w, r, chi.URLParam(r, "characterId"))
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history.PassiveHistory(data, h.trees))
+}
+
+func (h *Handler) handleGetJewelHistory(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.loadSnapshotData(w, r, chi.URLParam(r, "characterId"))
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history.JewelsHistory(data, h.trees))
+}
+
+func (h *Handler) handleGetSnapshotJewels(w http.ResponseWriter, r *http.Request) {
+	d, err := h.repository.GetSnapshotData(chi.URLParam(r, "snapshotId"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history.Jewels(d, h.trees))
+}
+
+// loadSnapshotData returns the raw data of every snapshot of a character,
+// answering the request with an error when it can't be loaded.
+func (h *Handler) loadSnapshotData(w http.ResponseWriter, r *http.Request, characterId string) ([]models.SnapshotData, bool) {
+	if _, err := h.repository.GetCharacterByID(characterId); err != nil {
+		apierror.Write(w, r, err)
+		return nil, false
+	}
+
+	data, err := h.repository.GetSnapshotDataByCharacter(characterId)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Query to get snapshot data failed")
+		apierror.Write(w, r, err)
+		return nil, false
+	}
+	return data, true
+}
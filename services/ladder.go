@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/history"
+	"github.com/ByChanderZap/exile-tracker/models"
+	"github.com/ByChanderZap/exile-tracker/poeclient"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/rs/zerolog"
+)
+
+var ErrImportRunning = errors.New("a ladder import is already running")
+
+// skillChecksPerMatch bounds how many characters' gear an import with a
+// skill filter looks at, skillChecksPerMatch times Top. Each lookup waits
+// two seconds, so an unbounded run down a deep ladder would take hours.
+const skillChecksPerMatch = 5
+
+// LadderImportParams picks which ladder characters get tracked. Class
+// matches either the ascendancy or the base class, Skill the main skill of
+// the character's current gear.
+type LadderImportParams struct {
+	League string `json:"league"`
+	Realm  string `json:"realm"`
+	Top    int    `json:"top"`
+	Class  string `json:"class,omitempty"`
+	Skill  string `json:"skill,omitempty"`
+}
+
+type LadderImportResult struct {
+	Scanned        int      `json:"scanned"`
+	Matched        int      `json:"matched"`
+	Imported       int      `json:"imported"`
+	AlreadyTracked int      `json:"already_tracked"`
+	RanksRecorded  int      `json:"ranks_recorded"`
+	Characters     []string `json:"characters"`
+	// SkillChecks is how many characters' gear was fetched to match the
+	// skill filter. SkillCheckLimitReached is set when matching stopped
+	// early because of skillChecksPerMatch.
+	SkillChecks            int  `json:"skill_checks"`
+	SkillCheckLimitReached bool `json:"skill_check_limit_reached"`
+}
+
+// LadderImportStatus is the state of the last import.
+type LadderImportStatus struct {
+	Params     LadderImportParams  `json:"params"`
+	Running    bool                `json:"running"`
+	StartedAt  *time.Time          `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at"`
+	Result     *LadderImportResult `json:"result"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// ladderClient is the part of the PoE client the importer needs.
+type ladderClient interface {
+	GetLadder(league string, realm string, offset int, limit int) (poeclient.Ladder, error)
+	GetItemsJson(acName string, character string, realm string) ([]byte, error)
+}
+
+type LadderImporter struct {
+	repo      *repository.Repository
+	poeClient ladderClient
+	log       zerolog.Logger
+	done      chan bool
+	// delay is the pause between two requests to the PoE API.
+	delay time.Duration
+
+	mu     sync.Mutex
+	status LadderImportStatus
+}
+
+func NewLadderImporter(repo *repository.Repository, poeClient *poeclient.POEClient) *LadderImporter {
+	return &LadderImporter{
+		repo:      repo,
+		poeClient: poeClient,
+		log:       utils.ChildLogger("ladder"),
+		done:      make(chan bool),
+		delay:     2 * time.Second,
+	}
+}
+
+// Start imports the ladder with params now and then every interval.
+func (li *LadderImporter) Start(ctx context.Context, params LadderImportParams, interval time.Duration) {
+	li.log.Info().Str("league", params.League).Int("top", params.Top).Msg("Starting ladder importer")
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		li.run(params)
+		for {
+			select {
+			case <-li.done:
+				li.log.Info().Msg("Ladder importer stopped")
+				return
+			case <-ticker.C:
+				li.run(params)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (li *LadderImporter) Stop() {
+	close(li.done)
+}
+
+func (li *LadderImporter) run(params LadderImportParams) {
+	if _, err := li.Import(params); err != nil {
+		li.log.Error().Err(err).Msg("Ladder import failed")
+	}
+}
+
+// Status returns the state of the last or running import.
+func (li *LadderImporter) Status() LadderImportStatus {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	return li.status
+}
+
+// ImportAsync starts an import in the background. It fails with
+// ErrImportRunning when another import hasn't finished yet.
+func (li *LadderImporter) ImportAsync(params LadderImportParams) error {
+	params, err := params.validate()
+	if err != nil {
+		return err
+	}
+	if err := li.begin(params); err != nil {
+		return err
+	}
+	go func() {
+		result, err := li.importLadder(params)
+		li.finish(result, err)
+		if err != nil {
+			li.log.Error().Err(err).Msg("Ladder import failed")
+		}
+	}()
+	return nil
+}
+
+// Import walks the ladder of a league and tracks the first params.Top
+// living characters matching the filters, creating their accounts and
+// characters when needed. The rank of every living character stored for the
+// league is recorded in ladder_entries, so paging goes on past the matches
+// while one of them can still be further down the ladder.
+func (li *LadderImporter) Import(params LadderImportParams) (LadderImportResult, error) {
+	params, err := params.validate()
+	if err != nil {
+		return LadderImportResult{}, err
+	}
+	if err := li.begin(params); err != nil {
+		return LadderImportResult{}, err
+	}
+	result, err := li.importLadder(params)
+	li.finish(result, err)
+	return result, err
+}
+
+func (li *LadderImporter) begin(params LadderImportParams) error {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	if li.status.Running {
+		return ErrImportRunning
+	}
+	now := time.Now().UTC()
+	li.status = LadderImportStatus{Params: params, Running: true, StartedAt: &now}
+	return nil
+}
+
+func (li *LadderImporter) finish(result LadderImportResult, err error) {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	now := time.Now().UTC()
+	li.status.Running = false
+	li.status.FinishedAt = &now
+	li.status.Result = &result
+	if err != nil {
+		li.status.Error = err.Error()
+	}
+}
+
+// validate defaults the realm to pc and checks the required fields.
+func (p LadderImportParams) validate() (LadderImportParams, error) {
+	if p.League == "" {
+		return p, errors.New("league is required")
+	}
+	if p.Realm == "" {
+		p.Realm = poeclient.RealmPC
+	}
+	if !poeclient.IsValidRealm(p.Realm) {
+		return p, fmt.Errorf("invalid realm %q, expected one of %v", p.Realm, poeclient.Realms)
+	}
+	if p.Top <= 0 {
+		return p, errors.New("top must be greater than 0")
+	}
+	return p, nil
+}
+
+func (li *LadderImporter) importLadder(params LadderImportParams) (LadderImportResult, error) {
+	var result LadderImportResult
+	log := li.log.With().Str("league", params.League).Str("realm", params.Realm).Logger()
+	log.Info().Int("top", params.Top).Str("class", params.Class).Str("skill", params.Skill).Msg("Importing ladder")
+
+	if _, err := li.repo.EnsureLeague(params.League, params.Realm); err != nil {
+		return result, err
+	}
+
+	known, err := li.knownCharacters(params)
+	if err != nil {
+		return result, err
+	}
+
+	seen := make(map[string]bool, len(known))
+	for offset := 0; offset < poeclient.LadderMaxDepth; offset += poeclient.LadderPageSize {
+		ladder, err := li.poeClient.GetLadder(params.League, params.Realm, offset, poeclient.LadderPageSize)
+		if err != nil {
+			return result, err
+		}
+		polledAt := time.Now()
+
+		for _, entry := range ladder.Entries {
+			result.Scanned++
+			key := ladderKey(entry.Account.Name, entry.Character.Name)
+
+			if result.Matched < params.Top && li.matches(entry, params, &result, log) {
+				result.Matched++
+				characterId, created, err := li.track(entry, params)
+				if err != nil {
+					return result, err
+				}
+				known[key] = repository.LadderCandidate{CharacterId: characterId}
+				if created {
+					result.Imported++
+					result.Characters = append(result.Characters, entry.Character.Name)
+				} else {
+					result.AlreadyTracked++
+				}
+			}
+
+			if c, ok := known[key]; ok {
+				seen[key] = true
+				if err := li.recordRank(c.CharacterId, entry, params, polledAt); err != nil {
+					log.Error().Err(err).Str("character", entry.Character.Name).Msg("Failed to record ladder rank")
+					continue
+				}
+				result.RanksRecorded++
+			}
+		}
+
+		if len(ladder.Entries) < poeclient.LadderPageSize || offset+len(ladder.Entries) >= ladder.Total {
+			break
+		}
+		matching := result.Matched < params.Top && !result.SkillCheckLimitReached
+		lowest := ladder.Entries[len(ladder.Entries)-1].Character.Experience
+		if !matching && !anyLeft(known, seen, lowest) {
+			break
+		}
+		time.Sleep(li.delay)
+	}
+
+	log.Info().
+		Int("scanned", result.Scanned).
+		Int("imported", result.Imported).
+		Int("already_tracked", result.AlreadyTracked).
+		Int("ranks_recorded", result.RanksRecorded).
+		Int("skill_checks", result.SkillChecks).
+		Msg("Ladder import completed")
+	return result, nil
+}
+
+// matches applies the import filters to a ladder entry. Skill lookups are
+// counted in result and stop matching anything once the limit is reached.
+func (li *LadderImporter) matches(entry poeclient.LadderEntry, params LadderImportParams, result *LadderImportResult, log zerolog.Logger) bool {
+	if entry.Dead || entry.Retired || !classMatches(entry.Character.Class, params.Class) {
+		return false
+	}
+	if params.Skill == "" {
+		return true
+	}
+	if result.SkillChecks >= params.Top*skillChecksPerMatch {
+		if !result.SkillCheckLimitReached {
+			log.Warn().Int("skill_checks", result.SkillChecks).Int("matched", result.Matched).Msg("Skill check limit reached, not matching further characters")
+		}
+		result.SkillCheckLimitReached = true
+		return false
+	}
+	result.SkillChecks++
+	ok, err := li.usesSkill(entry, params)
+	if err != nil {
+		log.Warn().Err(err).Str("character", entry.Character.Name).Msg("Failed to check main skill")
+		return false
+	}
+	return ok
+}
+
+// knownCharacters maps the living characters stored for the league by
+// account and character name, so ladder positions can be recorded for them.
+func (li *LadderImporter) knownCharacters(params LadderImportParams) (map[string]repository.LadderCandidate, error) {
+	candidates, err := li.repo.GetLadderCandidates(params.League, params.Realm)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]repository.LadderCandidate, len(candidates))
+	for _, c := range candidates {
+		known[ladderKey(c.AccountName, c.CharacterName)] = c
+	}
+	return known, nil
+}
+
+// anyLeft reports whether a known character not seen yet can still be on
+// the pages after one ending at experience lowest. The ladder is sorted by
+// experience, which only grows, so one that had more than lowest would
+// already have been seen. Characters never sampled could be anywhere.
+func anyLeft(known map[string]repository.LadderCandidate, seen map[string]bool, lowest int64) bool {
+	for key, c := range known {
+		if seen[key] {
+			continue
+		}
+		if c.Experience == nil || *c.Experience <= lowest {
+			return true
+		}
+	}
+	return false
+}
+
+func (li *LadderImporter) recordRank(characterId string, entry poeclient.LadderEntry, params LadderImportParams, at time.Time) error {
+	var depth *int
+	if entry.Character.Depth != nil {
+		depth = &entry.Character.Depth.Default
+	}
+	return li.repo.CreateLadderEntry(repository.CreateLadderEntryParams{
+		CharacterId: characterId,
+		League:      params.League,
+		Realm:       params.Realm,
+		Rank:        entry.Rank,
+		Level:       entry.Character.Level,
+		Experience:  entry.Character.Experience,
+		Depth:       depth,
+		Dead:        entry.Dead,
+		RecordedAt:  at,
+	})
+}
+
+func ladderKey(accountName string, characterName string) string {
+	return strings.ToLower(accountName) + "/" + characterName
+}
+
+// usesSkill fetches the character's gear and compares its main skill.
+func (li *LadderImporter) usesSkill(entry poeclient.LadderEntry, params LadderImportParams) (bool, error) {
+	defer time.Sleep(li.delay)
+
+	data, err := li.poeClient.GetItemsJson(entry.Account.Name, entry.Character.Name, params.Realm)
+	if err != nil {
+		return false, err
+	}
+	var items models.ItemsResponse
+	if err := json.Unmarshal(data, &items); err != nil {
+		return false, err
+	}
+	return strings.EqualFold(history.MainSkillName(items), params.Skill), nil
+}
+
+// track makes sure the ladder character exists and is enrolled in
+// characters_to_fetch. It reports whether the character wasn't tracked yet.
+func (li *LadderImporter) track(entry poeclient.LadderEntry, params LadderImportParams) (string, bool, error) {
+	acc, _, err := li.repo.UpsertAccount(entry.Account.Name, "", params.Realm)
+	if err != nil {
+		return "", false, err
+	}
+
+	c, _, err := li.repo.UpsertCharacter(acc.ID, entry.Character.Name, params.League, params.Realm)
+	if err != nil {
+		return "", false, err
+	}
+
+	added, err := li.repo.EnsureCharacterToFetch(c.ID)
+	return c.ID, added, err
+}
+
+func classMatches(class string, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	base, _ := history.SplitClass(class)
+	return strings.EqualFold(class, filter) || strings.EqualFold(base, filter)
+}
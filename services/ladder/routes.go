@@ -0,0 +1,76 @@
+package ladder
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	apimodels "github.com/ByChanderZap/exile-tracker/models/api"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/services"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+type Handler struct {
+	repository *repository.Repository
+	importer   *services.LadderImporter
+	log        zerolog.Logger
+}
+
+func NewHandler(db *repository.Repository, importer *services.LadderImporter, logger zerolog.Logger) *Handler {
+	return &Handler{
+		repository: db,
+		importer:   importer,
+		log:        logger,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *chi.Mux) {
+	router.Get("/ladder/import", h.handleGetImportStatus)
+	router.Post("/ladder/import", h.handleImport)
+	router.Get("/ladder/characters/{characterId}", h.handleGetCharacterRanks)
+}
+
+func (h *Handler) handleGetCharacterRanks(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.repository.GetLadderEntriesByCharacter(chi.URLParam(r, "characterId"), r.URL.Query().Get("league"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, entries)
+}
+
+func (h *Handler) handleGetImportStatus(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, h.importer.Status())
+}
+
+// handleImport starts a ladder import in the background. Checking the main
+// skill of every candidate can take a while, so the result is read from
+// GET /ladder/import.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	var payload apimodels.LadderImportInput
+	if err := utils.ParseJson(r, &payload); err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err))
+		return
+	}
+
+	err := h.importer.ImportAsync(services.LadderImportParams{
+		League: payload.League,
+		Realm:  payload.Realm,
+		Top:    payload.Top,
+		Class:  payload.Class,
+		Skill:  payload.Skill,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrImportRunning) {
+			apierror.Write(w, r, apierror.Conflict("%s", err))
+			return
+		}
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, h.importer.Status())
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/exile-tracker/poeclient"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog"
+)
+
+// newTestRepository opens an in-memory database with every migration
+// applied.
+func newTestRepository(t *testing.T) (*repository.Repository, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("migration %s: %v", filepath.Base(file), err)
+		}
+	}
+	return repository.NewRepository(db), db
+}
+
+// fakeLadder serves a ladder of size entries, rank n having n*1000 less
+// experience than the first, and remembers which offsets were read.
+type fakeLadder struct {
+	entries []poeclient.LadderEntry
+	offsets []int
+}
+
+func newFakeLadder(size int) *fakeLadder {
+	f := &fakeLadder{}
+	for rank := 1; rank <= size; rank++ {
+		f.entries = append(f.entries, poeclient.LadderEntry{
+			Rank: rank,
+			Character: poeclient.LadderCharacter{
+				Name:       fmt.Sprintf("Char%d", rank),
+				Level:      95,
+				Class:      "Necromancer",
+				Experience: ladderExperience(rank),
+			},
+			Account: poeclient.LadderAccount{Name: fmt.Sprintf("Player#%d", rank)},
+		})
+	}
+	return f
+}
+
+func ladderExperience(rank int) int64 {
+	return 4_000_000_000 - int64(rank)*1000
+}
+
+func (f *fakeLadder) GetLadder(league string, realm string, offset int, limit int) (poeclient.Ladder, error) {
+	f.offsets = append(f.offsets, offset)
+	end := min(offset+limit, len(f.entries))
+	if offset > end {
+		offset = end
+	}
+	return poeclient.Ladder{Total: len(f.entries), Entries: f.entries[offset:end]}, nil
+}
+
+func (f *fakeLadder) GetItemsJson(acName string, character string, realm string) ([]byte, error) {
+	return nil, errors.New("not on this fake")
+}
+
+func TestImportLadderStopsEarly(t *testing.T) {
+	const league = "Settlers"
+	page := poeclient.LadderPageSize
+
+	tests := []struct {
+		name  string
+		setup []string
+		// offsets are the ladder pages expected to be read.
+		offsets []int
+		ranks   int
+	}{
+		{
+			name:    "nothing stored",
+			offsets: []int{0},
+		},
+		{
+			name: "stored character further down",
+			setup: []string{
+				`INSERT INTO characters (id, account_id, character_name, died, current_league, realm, created_at, updated_at) VALUES
+					('c1', 'a1', 'Char450', false, 'Settlers', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+				fmt.Sprintf(`INSERT INTO experience_samples (id, character_id, league, level, experience, recorded_at) VALUES
+					('x1', 'c1', 'Settlers', 95, %d, '2025-01-01T00:00:00Z')`, ladderExperience(460)),
+			},
+			offsets: []int{0, page, 2 * page},
+			ranks:   1,
+		},
+		{
+			name: "stored character that dropped off the ladder",
+			setup: []string{
+				`INSERT INTO characters (id, account_id, character_name, died, current_league, realm, created_at, updated_at) VALUES
+					('c1', 'a1', 'Private', false, 'Settlers', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+				fmt.Sprintf(`INSERT INTO ladder_entries (id, character_id, league, realm, rank, level, experience, recorded_at) VALUES
+					('l1', 'c1', 'Settlers', 'pc', 30, 95, %d, '2025-01-01T00:00:00Z')`, ladderExperience(30)),
+			},
+			offsets: []int{0},
+		},
+		{
+			name: "dead and other league characters",
+			setup: []string{
+				`INSERT INTO characters (id, account_id, character_name, died, current_league, realm, created_at, updated_at) VALUES
+					('c1', 'a1', 'Dead', true, 'Settlers', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z'),
+					('c2', 'a1', 'Standard', false, 'Standard', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+			},
+			offsets: []int{0},
+		},
+		{
+			name: "never sampled character",
+			setup: []string{
+				`INSERT INTO characters (id, account_id, character_name, died, current_league, realm, created_at, updated_at) VALUES
+					('c1', 'a1', 'Fresh', false, 'Settlers', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+			},
+			offsets: []int{0, page, 2 * page, 3 * page, 4 * page},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, db := newTestRepository(t)
+			stmts := append([]string{
+				`INSERT INTO accounts (id, account_name, realm, created_at, updated_at) VALUES
+					('a1', 'Player#450', 'pc', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+			}, tt.setup...)
+			for _, stmt := range stmts {
+				if _, err := db.Exec(stmt); err != nil {
+					t.Fatalf("%s: %v", stmt, err)
+				}
+			}
+
+			client := newFakeLadder(5 * page)
+			li := &LadderImporter{repo: repo, poeClient: client, log: zerolog.Nop(), done: make(chan bool)}
+			result, err := li.Import(LadderImportParams{League: league, Top: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(client.offsets, tt.offsets) {
+				t.Errorf("read offsets %v, want %v", client.offsets, tt.offsets)
+			}
+			if result.Matched != 2 || result.Imported != 2 {
+				t.Errorf("matched %d and imported %d, want 2 and 2", result.Matched, result.Imported)
+			}
+			if want := tt.ranks + 2; result.RanksRecorded != want {
+				t.Errorf("recorded %d ranks, want %d", result.RanksRecorded, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package leagues
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	apimodels "github.com/ByChanderZap/exile-tracker/models/api"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+type Handler struct {
+	repository *repository.Repository
+	log        zerolog.Logger
+}
+
+func NewHandler(db *repository.Repository, logger zerolog.Logger) *Handler {
+	return &Handler{
+		repository: db,
+		log:        logger,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *chi.Mux) {
+	router.Get("/leagues", h.handleGetAllLeagues)
+	router.Get("/leagues/{id}", h.handleGetLeagueByID)
+	router.Put("/leagues/{id}", h.handleUpdateLeague)
+}
+
+func (h *Handler) handleGetAllLeagues(w http.ResponseWriter, r *http.Request) {
+	leagues, err := h.repository.GetAllLeagues()
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, leagues)
+}
+
+func (h *Handler) handleGetLeagueByID(w http.ResponseWriter, r *http.Request) {
+	league, err := h.repository.GetLeagueByID(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, league)
+}
+
+// handleUpdateLeague sets the start and end dates of a league, for leagues
+// the PoE API doesn't list.
+func (h *Handler) handleUpdateLeague(w http.ResponseWriter, r *http.Request) {
+	var payload apimodels.UpdateLeagueInput
+	if err := utils.ParseJson(r, &payload); err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err))
+		return
+	}
+	if payload.StartAt != nil && payload.EndAt != nil && payload.EndAt.Before(*payload.StartAt) {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("end_at must be after start_at")))
+		return
+	}
+
+	league, err := h.repository.GetLeagueByID(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	err = h.repository.UpdateLeagueDates(repository.UpdateLeagueDatesParams{
+		ID:      league.ID,
+		StartAt: payload.StartAt,
+		EndAt:   payload.EndAt,
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "League updated",
+	})
+}
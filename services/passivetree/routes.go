@@ -0,0 +1,53 @@
+package passivetree
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/passivetree"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	trees *passivetree.Registry
+}
+
+func NewHandler(trees *passivetree.Registry) *Handler {
+	return &Handler{
+		trees: trees,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *chi.Mux) {
+	router.Get("/passivetree/versions", h.handleGetVersions)
+	router.Get("/passivetree/nodes/{hash}", h.handleGetNode)
+}
+
+func (h *Handler) handleGetVersions(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, h.trees.Versions())
+}
+
+// handleGetNode resolves a node hash using the tree of ?league= (or ?version=),
+// defaulting to the latest bundled tree.
+func (h *Handler) handleGetNode(w http.ResponseWriter, r *http.Request) {
+	hash, err := strconv.Atoi(chi.URLParam(r, "hash"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("node hash must be a number")))
+		return
+	}
+
+	tree := h.trees.ForLeague(r.URL.Query().Get("league"))
+	if version := r.URL.Query().Get("version"); version != "" {
+		tree = h.trees.Version(version)
+	}
+
+	node := tree.Resolve(hash)
+	if !node.Known {
+		apierror.Write(w, r, apierror.NotFound("node %d not found in tree %s", hash, tree.Version))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, node)
+}
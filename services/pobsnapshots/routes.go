@@ -1,10 +1,13 @@
 package pobsnapshots
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/ByChanderZap/exile-tracker/apierror"
 	"github.com/ByChanderZap/exile-tracker/repository"
 	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/ByChanderZap/exile-tracker/utils/query"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -21,14 +24,20 @@ func NewHandler(db *repository.Repository) *Handler {
 func (h *Handler) RegisterRoutes(router *chi.Mux) {
 	router.Get("/pobsnapshots/character/{characterId}", h.handleGetSnapshotsByCharacter)
 	router.Get("/pobsnapshots/character/{characterId}/latest", h.handleGetLatestSnapshot)
+	router.Get("/pobsnapshots/character/{characterId}/stats", h.handleGetStatsByCharacter)
 	router.Get("/pobsnapshots/{id}", h.handleGetSnapshotByID)
+	router.Get("/pobsnapshots/{id}/stats", h.handleGetSnapshotStats)
 }
 
 func (h *Handler) handleGetSnapshotsByCharacter(w http.ResponseWriter, r *http.Request) {
-	characterId := chi.URLParam(r, "characterId")
-	snapshots, err := h.repository.GetSnapshotsByCharacter(characterId)
+	params, err := query.SnapshotListParams(r, chi.URLParam(r, "characterId"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+	snapshots, err := h.repository.ListSnapshots(params)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err)
+		apierror.Write(w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, snapshots)
@@ -38,7 +47,7 @@ func (h *Handler) handleGetLatestSnapshot(w http.ResponseWriter, r *http.Request
 	characterId := chi.URLParam(r, "characterId")
 	snapshot, err := h.repository.GetLatestSnapshotByCharacter(characterId)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, err)
+		apierror.Write(w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, snapshot)
@@ -48,8 +57,51 @@ func (h *Handler) handleGetSnapshotByID(w http.ResponseWriter, r *http.Request)
 	id := chi.URLParam(r, "id")
 	snapshot, err := h.repository.GetSnapshotByID(id)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, err)
+		apierror.Write(w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, snapshot)
 }
+
+// handleGetStatsByCharacter lists the computed stats of every snapshot of a
+// character. With ?dropped=<stat> only snapshots where that stat went down
+// compared to the previous snapshot are returned.
+func (h *Handler) handleGetStatsByCharacter(w http.ResponseWriter, r *http.Request) {
+	characterId := chi.URLParam(r, "characterId")
+
+	dropped := r.URL.Query().Get("dropped")
+	if dropped == "" {
+		stats, err := h.repository.GetSnapshotStatsByCharacter(characterId)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, stats)
+		return
+	}
+
+	if _, ok := repository.StatColumns[dropped]; !ok {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("unknown stat '%s'", dropped)))
+		return
+	}
+
+	stats, err := h.repository.GetStatDrops(repository.GetStatDropsParams{
+		CharacterId: characterId,
+		Stat:        dropped,
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, stats)
+}
+
+func (h *Handler) handleGetSnapshotStats(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	stats, err := h.repository.GetSnapshotStats(id)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, stats)
+}
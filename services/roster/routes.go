@@ -0,0 +1,106 @@
+package roster
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ByChanderZap/exile-tracker/apierror"
+	"github.com/ByChanderZap/exile-tracker/poeclient"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/roster"
+	"github.com/ByChanderZap/exile-tracker/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+// maxImportSize caps imported lists, the same as the bodies the OpenAPI
+// validator reads.
+const maxImportSize = 1 << 20
+
+type Handler struct {
+	repository *repository.Repository
+	log        zerolog.Logger
+}
+
+func NewHandler(db *repository.Repository, logger zerolog.Logger) *Handler {
+	return &Handler{
+		repository: db,
+		log:        logger,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *chi.Mux) {
+	router.Post("/import", h.handleImport)
+	router.Get("/export", h.handleExport)
+}
+
+// handleImport reads a JSON body, or a CSV one when sent as text/csv. The
+// whole list is checked before anything is imported.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	format := roster.FormatJSON
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		format = roster.FormatCSV
+	}
+
+	entries, err := roster.Read(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Write(w, r, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "request body is larger than %d bytes", maxImportSize))
+			return
+		}
+		apierror.Write(w, r, apierror.BadRequest(err))
+		return
+	}
+	if errs := roster.Validate(entries); len(errs) > 0 {
+		e := apierror.ValidationFailed(errs)
+		e.Message = "list has invalid entries, nothing was imported"
+		apierror.Write(w, r, e)
+		return
+	}
+
+	report, err := roster.Import(h.repository, entries)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	h.log.Info().Int("entries", len(entries)).Interface("report", report).Msg("Imported roster")
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// handleExport answers the list as JSON, or as CSV with ?format=csv or an
+// Accept: text/csv header. ?realm= keeps the accounts of one realm.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	format, err := roster.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter(err))
+		return
+	}
+	if r.URL.Query().Get("format") == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = roster.FormatCSV
+	}
+	realm := r.URL.Query().Get("realm")
+	if realm != "" && !poeclient.IsValidRealm(realm) {
+		apierror.Write(w, r, apierror.InvalidParameter(fmt.Errorf("invalid realm %q", realm)))
+		return
+	}
+
+	entries, err := h.repository.GetRoster(realm)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if format == roster.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="roster.csv"`)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err := roster.Write(w, format, entries); err != nil {
+		h.log.Error().Err(err).Msg("Error writing roster export")
+	}
+}
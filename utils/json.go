@@ -16,18 +16,11 @@ func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
-func RespondWithError(w http.ResponseWriter, code int, err error) {
-	if code > 499 {
-		log.Println("Responded with 5XX error: ", err.Error())
-	}
-
-	type errResponse struct {
-		Error string `json:"error"`
-	}
-
-	WriteJSON(w, code, errResponse{
-		Error: err.Error(),
-	})
+// WriteCreated answers 201 with the created resource and, in the Location
+// header, where to get it.
+func WriteCreated(w http.ResponseWriter, location string, v interface{}) error {
+	w.Header().Set("Location", location)
+	return WriteJSON(w, http.StatusCreated, v)
 }
 
 func ParseJson(r *http.Request, payload any) error {
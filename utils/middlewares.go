@@ -3,6 +3,7 @@ package utils
 import (
 	"net/http"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
 )
 
@@ -19,3 +20,12 @@ func ZerologMiddleware(logger zerolog.Logger) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// RequestIDMiddleware gives every request an ID, the client's X-Request-Id
+// when it sent one, and echoes it back in the response headers.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
+		next.ServeHTTP(w, r)
+	}))
+}
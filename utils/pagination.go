@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ByChanderZap/exile-tracker/models"
+)
+
+// ParsePageParams reads the limit, cursor, sort and order query parameters
+// shared by every list endpoint. order is "asc" (the default) or "desc".
+func ParsePageParams(r *http.Request) (models.PageParams, error) {
+	q := r.URL.Query()
+	page := models.PageParams{
+		Cursor: q.Get("cursor"),
+		Sort:   q.Get("sort"),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return models.PageParams{}, fmt.Errorf("invalid limit %q, expected a positive number", v)
+		}
+		page.Limit = limit
+	}
+	switch order := q.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return models.PageParams{}, fmt.Errorf("invalid order %q, expected asc or desc", order)
+	}
+	return page, nil
+}
+
+// QueryBool reads an optional true/false query parameter, nil when it is
+// missing.
+func QueryBool(r *http.Request, key string) (*bool, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, expected true or false", key, v)
+	}
+	return &b, nil
+}
+
+// QueryTime reads an optional RFC3339 time or YYYY-MM-DD date query
+// parameter, nil when it is missing. Dates are midnight UTC.
+func QueryTime(r *http.Request, key string) (*time.Time, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, expected an RFC3339 time or a YYYY-MM-DD date", key, v)
+	}
+	return &t, nil
+}
+
+// QueryInt reads an optional number query parameter, def when it is
+// missing.
+func QueryInt(r *http.Request, key string, def int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q, expected a number", key, v)
+	}
+	return n, nil
+}
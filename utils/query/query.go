@@ -0,0 +1,135 @@
+// Package query reads the list filters and report options shared by the API
+// and the web UI from request query parameters. It sits apart from utils
+// because it needs packages that import utils themselves.
+package query
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ByChanderZap/exile-tracker/analytics"
+	"github.com/ByChanderZap/exile-tracker/history"
+	"github.com/ByChanderZap/exile-tracker/poeclient"
+	"github.com/ByChanderZap/exile-tracker/repository"
+	"github.com/ByChanderZap/exile-tracker/utils"
+)
+
+// AccountListParams reads the paging parameters and the league, realm, q,
+// created_after and created_before filters of an account list.
+func AccountListParams(r *http.Request) (repository.ListAccountsParams, error) {
+	page, err := utils.ParsePageParams(r)
+	if err != nil {
+		return repository.ListAccountsParams{}, err
+	}
+	q := r.URL.Query()
+	params := repository.ListAccountsParams{
+		PageParams: page,
+		League:     q.Get("league"),
+		Realm:      q.Get("realm"),
+		Query:      q.Get("q"),
+	}
+	if params.Realm != "" && !poeclient.IsValidRealm(params.Realm) {
+		return repository.ListAccountsParams{}, fmt.Errorf("invalid realm %q", params.Realm)
+	}
+	if params.CreatedAfter, err = utils.QueryTime(r, "created_after"); err != nil {
+		return repository.ListAccountsParams{}, err
+	}
+	if params.CreatedBefore, err = utils.QueryTime(r, "created_before"); err != nil {
+		return repository.ListAccountsParams{}, err
+	}
+	return params, nil
+}
+
+// CharacterListParams reads the paging parameters and the league, realm, q,
+// died, tracked, class, created_after and created_before filters of a
+// character list. class matches a base class or an ascendancy.
+func CharacterListParams(r *http.Request) (repository.ListCharactersParams, error) {
+	page, err := utils.ParsePageParams(r)
+	if err != nil {
+		return repository.ListCharactersParams{}, err
+	}
+	q := r.URL.Query()
+	params := repository.ListCharactersParams{
+		PageParams: page,
+		League:     q.Get("league"),
+		Realm:      q.Get("realm"),
+		Query:      q.Get("q"),
+	}
+	if params.Realm != "" && !poeclient.IsValidRealm(params.Realm) {
+		return repository.ListCharactersParams{}, fmt.Errorf("invalid realm %q", params.Realm)
+	}
+	if class := q.Get("class"); class != "" {
+		params.Classes = history.ClassNames(class)
+	}
+	if params.Died, err = utils.QueryBool(r, "died"); err != nil {
+		return repository.ListCharactersParams{}, err
+	}
+	if params.Tracked, err = utils.QueryBool(r, "tracked"); err != nil {
+		return repository.ListCharactersParams{}, err
+	}
+	if params.CreatedAfter, err = utils.QueryTime(r, "created_after"); err != nil {
+		return repository.ListCharactersParams{}, err
+	}
+	if params.CreatedBefore, err = utils.QueryTime(r, "created_before"); err != nil {
+		return repository.ListCharactersParams{}, err
+	}
+	return params, nil
+}
+
+// SnapshotListParams reads the paging parameters and the league,
+// created_after and created_before filters of a character's snapshot list.
+func SnapshotListParams(r *http.Request, characterId string) (repository.ListSnapshotsParams, error) {
+	page, err := utils.ParsePageParams(r)
+	if err != nil {
+		return repository.ListSnapshotsParams{}, err
+	}
+	params := repository.ListSnapshotsParams{
+		PageParams:  page,
+		CharacterId: characterId,
+		League:      r.URL.Query().Get("league"),
+	}
+	if params.CreatedAfter, err = utils.QueryTime(r, "created_after"); err != nil {
+		return repository.ListSnapshotsParams{}, err
+	}
+	if params.CreatedBefore, err = utils.QueryTime(r, "created_before"); err != nil {
+		return repository.ListSnapshotsParams{}, err
+	}
+	return params, nil
+}
+
+// UpgradeParams reads the match_by, stat and levels query parameters of an
+// upgrade report.
+func UpgradeParams(r *http.Request) (analytics.UpgradeParams, error) {
+	var params analytics.UpgradeParams
+	q := r.URL.Query()
+	switch by := analytics.MatchBy(q.Get("match_by")); by {
+	case "":
+		params.MatchBy = analytics.MatchMainSkill
+	case analytics.MatchMainSkill, analytics.MatchAscendancy:
+		params.MatchBy = by
+	default:
+		return params, fmt.Errorf("match_by must be '%s' or '%s'", analytics.MatchMainSkill, analytics.MatchAscendancy)
+	}
+
+	params.Stat = q.Get("stat")
+	if _, ok := repository.StatColumns[params.Stat]; params.Stat != "" && !ok {
+		return params, fmt.Errorf("unknown stat '%s'", params.Stat)
+	}
+
+	levels, err := utils.QueryInt(r, "levels", 5)
+	if err != nil || levels <= 0 {
+		return params, fmt.Errorf("levels must be a positive number")
+	}
+	params.Levels = levels
+	return params, nil
+}
+
+// AnalyticsFilter reads the league, class and ascendancy query parameters.
+func AnalyticsFilter(r *http.Request) analytics.Filter {
+	q := r.URL.Query()
+	return analytics.Filter{
+		League:     q.Get("league"),
+		Class:      q.Get("class"),
+		Ascendancy: q.Get("ascendancy"),
+	}
+}